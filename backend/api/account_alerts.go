@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+)
+
+func (e *Env) GetAccountAlerts(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	_, apierr := e.Admin(c)
+	if apierr != nil {
+		return apierr
+	}
+
+	var data models.QueryBase
+	err := DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	if data.Sort == "" {
+		data.Sort = "-id"
+	}
+
+	modifier, apierr := e.list(c, r, "account_alert", data,
+		[]string{
+			"kind", "game_id", "user_id", "related_user_id", "key", "value",
+			"resolved_at",
+		},
+		[]string{"id", "created_at", "resolved_at"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	alerts, err := e.M.GetAccountAlerts(modifier)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(alerts, c, w)
+}
+
+type patchAccountAlertBody struct {
+	Action string
+}
+
+func (e *Env) PatchAccountAlert(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	me, apierr := e.Admin(c)
+	if apierr != nil {
+		return apierr
+	}
+
+	var data patchAccountAlertBody
+	err := Decode(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	alert, err := e.M.GetAccountAlertById(c.URLParams["id"])
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	if data.Action != "resolve" {
+		return invalid("action", CodeEnum, "bad action")
+	} else if alert.ResolvedAt != nil {
+		return &Error{
+			C: http.StatusBadRequest, M: "this alert is already resolved",
+		}
+	}
+
+	now := time.Now()
+	alert.ResolvedAt = &now
+	alert.ResolvedBy = &me.Id
+	err = e.M.UpdateAccountAlert(alert)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(alert, c, w)
+}
+
+// GetUserRelatedAccounts lists whoever has game data in common with the user,
+// and what exactly, including pairs that have been nullified since.
+func (e *Env) GetUserRelatedAccounts(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	_, apierr := e.Admin(c)
+	if apierr != nil {
+		return apierr
+	}
+
+	related, err := e.M.GetRelatedAccounts(c.URLParams["id"])
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(related, c, w)
+}
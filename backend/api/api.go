@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -14,16 +15,20 @@ import (
 
 	"app/mail"
 	"app/models"
+	"app/oauth"
 	"app/slack"
+	"app/verification"
 )
 
 type Env struct {
-	StaticHost string
-	Scrypt     scrypt.Params
-	M          *models.Env
-	Mail       *mail.Env
-	Slack      *slack.Env
-	Sentry     *raven.Client
+	StaticHost   string
+	Scrypt       scrypt.Params
+	M            *models.Env
+	Mail         *mail.Env
+	Slack        *slack.Env
+	OAuth        *oauth.Env
+	Verification *verification.Env
+	Sentry       *raven.Client
 }
 
 func New(
@@ -32,17 +37,26 @@ func New(
 	modelsEnv *models.Env,
 	mailEnv *mail.Env,
 	slackEnv *slack.Env,
+	oauthEnv *oauth.Env,
+	verificationEnv *verification.Env,
 	sentry *raven.Client,
 ) *Env {
-	return &Env{staticHost, scryptParams, modelsEnv, mailEnv, slackEnv, sentry}
+	return &Env{
+		staticHost, scryptParams, modelsEnv, mailEnv, slackEnv, oauthEnv,
+		verificationEnv, sentry,
+	}
 }
 
 func Decode(r *http.Request, v interface{}) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
 
+// DecodeQuery leaves out include and fields, which are up to Shape.
 func DecodeQuery(r *http.Request, v interface{}) error {
-	return param.Parse(r.URL.Query(), v)
+	query := r.URL.Query()
+	query.Del("include")
+	query.Del("fields")
+	return param.Parse(query, v)
 }
 
 func Respond(
@@ -50,14 +64,48 @@ func Respond(
 ) *Error {
 	w.Header().Set("Request-Id", middleware.GetReqID(c))
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
+	if l, ok := c.Env["listing"].(*listing); ok && code == http.StatusOK {
+		err := l.writeHeaders(w.Header(), data)
+		if err != nil {
+			return &Error{E: err}
+		}
+	}
+
+	if tag, ok := c.Env["etag"].(string); ok && code == http.StatusOK {
+		w.Header().Set("ETag", tag)
+		if listed, _ := c.Env["etagListed"].(bool); listed {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	if s, ok := c.Env["shape"].(*shape); ok && code == http.StatusOK &&
+		data != nil {
+		var apierr *Error
+		data, apierr = s.apply(data)
+		if apierr != nil {
+			return apierr
+		}
+	}
+
+	var body bytes.Buffer
 	if data != nil {
-		err := json.NewEncoder(w).Encode(data)
+		err := json.NewEncoder(&body).Encode(data)
 		if err != nil {
 			return &Error{E: err}
 		}
 	}
 
+	if req, ok := c.Env["idempotency"].(*idempotentRequest); ok {
+		delete(c.Env, "idempotency")
+		err := req.finish(code, body.Bytes())
+		if err != nil {
+			return &Error{E: err}
+		}
+	}
+
+	w.WriteHeader(code)
+	w.Write(body.Bytes())
 	return nil
 }
 
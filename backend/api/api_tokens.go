@@ -0,0 +1,168 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+	"app/utils"
+)
+
+const (
+	ScopeRead      = "read"
+	ScopeReport    = "report"     // submitting and answering match reports
+	ScopeAdminRead = "admin-read" // seeing what admins see, not doing it
+
+	ApiTokenAgeDefault = time.Hour * 24 * 90
+	ApiTokenAgeMax     = time.Hour * 24 * 365
+)
+
+var scopes = []string{ScopeRead, ScopeReport, ScopeAdminRead}
+
+type postApiTokenBody struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+func (e *Env) PostApiToken(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	var data postApiTokenBody
+	err = Decode(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	var v Validation
+	data.Name = strings.TrimSpace(data.Name)
+	v.Required("name", data.Name)
+	if len(data.Scopes) == 0 {
+		v.Add("scopes", CodeRequired, "at least one scope is needed")
+	}
+
+	for i, scope := range data.Scopes {
+		isKnown := v.Enum(fmt.Sprintf("scopes[%d]", i), scope, scopes...)
+		if isKnown && scope == ScopeAdminRead && len(me.Roles) == 0 {
+			return &Error{
+				E: utils.ErrForbidden, M: "admin-read is for those who have a role",
+			}
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ApiTokenAgeDefault)
+	if data.ExpiresAt != nil {
+		expiresAt = *data.ExpiresAt
+	}
+
+	if expiresAt.Before(now) {
+		v.Add("expiresAt", CodeRange, "expiry is in the past")
+	} else if expiresAt.After(now.Add(ApiTokenAgeMax)) {
+		v.Add("expiresAt", CodeRange, "expiry is too far away")
+	}
+
+	apierr := v.Err()
+	if apierr != nil {
+		return apierr
+	}
+
+	apiToken := &models.ApiToken{
+		UserId:    me.Id,
+		Name:      data.Name,
+		Scopes:    data.Scopes,
+		ExpiresAt: expiresAt,
+	}
+	err = e.M.CreateApiToken(apiToken)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return Created(apiToken, c, w)
+}
+
+func (e *Env) GetApiTokens(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	var data models.QueryBase
+	err = DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	userId, _ := data.Filter["user_id"]
+	if userId == "" {
+		return invalid(
+			"filter[user_id]", CodeRequired, "user_id filter is mandatory",
+		)
+	} else if userId != me.Id && !me.Is(models.RoleAdmin) {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	modifier, apierr := e.list(c, r, "api_token", data,
+		[]string{"user_id"},
+		[]string{"id", "name", "created_at", "expires_at", "last_used_at"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	apiTokens, err := e.M.GetApiTokens(modifier)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(apiTokens, c, w)
+}
+
+func (e *Env) DeleteApiToken(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	apiToken, err := e.M.GetApiTokenById(c.URLParams["id"])
+	if err != nil {
+		return &Error{E: err}
+	} else if apiToken.UserId != me.Id && !me.Is(models.RoleAdmin) {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	err = e.M.DeleteApiToken(apiToken)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(apiToken, c, w)
+}
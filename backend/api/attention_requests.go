@@ -10,6 +10,12 @@ import (
 	"app/utils"
 )
 
+type postAttentionRequestBody struct {
+	Target   string
+	TargetId string
+	Message  string
+}
+
 func (e *Env) PostAttentionRequest(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -18,28 +24,29 @@ func (e *Env) PostAttentionRequest(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if me.IsAdmin {
-		return &Error{C: http.StatusBadRequest, M: "you're an admin yourself..."}
 	}
 
-	var data struct {
-		Target   string
-		TargetId string
-		Message  string
-	}
+	var data postAttentionRequestBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	} else if data.Target != "match" {
-		return &Error{C: http.StatusBadRequest, M: "bad target"}
+		return invalid("target", CodeEnum, "bad target")
 	}
 
 	match, err := e.M.GetMatchById(data.TargetId)
 	if err != nil {
-		return &Error{E: err, C: http.StatusBadRequest, M: "bad match id"}
+		return invalid("targetId", CodeId, "bad match id")
+	}
+
+	isReferee, apierr := e.can(me, models.RoleReferee, "match", match.Id)
+	if apierr != nil {
+		return apierr
+	} else if isReferee {
+		return &Error{C: http.StatusBadRequest, M: "you're a referee yourself..."}
 	}
 
 	var teamAmLeaderOf *string
@@ -81,7 +88,7 @@ func (e *Env) PostAttentionRequest(
 }
 
 func (e *Env) getAttentionRequestStuff(c web.C) (
-	request *models.AttentionRequest, me *models.User, err *Error,
+	request *models.AttentionRequest, me *models.User, isReferee bool, err *Error,
 ) {
 	session, ok := c.Env["session"].(*models.Session)
 	if !ok {
@@ -96,13 +103,16 @@ func (e *Env) getAttentionRequestStuff(c web.C) (
 		return
 	}
 
-	me, inerr = e.M.GetUserById(session.UserId)
+	me, inerr = e.me(c, session)
 	if inerr != nil {
 		err = &Error{E: inerr, C: http.StatusInternalServerError}
 		return
 	}
 
-	if me.IsAdmin {
+	isReferee, err = e.can(
+		me, models.RoleReferee, request.Target, request.TargetId,
+	)
+	if err != nil || isReferee {
 		return
 	}
 
@@ -132,12 +142,12 @@ func (e *Env) getAttentionRequestStuff(c web.C) (
 func (e *Env) GetAttentionRequest(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	request, me, outerr := e.getAttentionRequestStuff(c)
+	request, _, isReferee, outerr := e.getAttentionRequestStuff(c)
 	if outerr != nil {
 		return outerr
 	}
 
-	if me.IsAdmin {
+	if isReferee {
 		return OK(request, c, w)
 	}
 
@@ -152,33 +162,32 @@ func (e *Env) GetAttentionRequests(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	requests, err := e.M.GetAttentionRequests(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "attention_request", data,
 		[]string{
 			"target", "target_id", "created_by", "team_by", "claimed_by",
 			"resolved_at",
 		},
 		[]string{}, // TODO
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	requests, err := e.M.GetAttentionRequests(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if me.IsAdmin {
+	} else if me.Is(models.RoleReferee) {
 		return OK(requests, c, w)
 	}
 
@@ -208,10 +217,14 @@ func (e *Env) GetAttentionRequests(
 	return OK(public, c, w)
 }
 
+type putAttentionRequestBody struct {
+	Message *string
+}
+
 func (e *Env) PutAttentionRequest(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	request, me, outerr := e.getAttentionRequestStuff(c)
+	request, me, isReferee, outerr := e.getAttentionRequestStuff(c)
 	if outerr != nil {
 		return outerr
 	} else if request.ResolvedAt != nil {
@@ -220,9 +233,7 @@ func (e *Env) PutAttentionRequest(
 		}
 	}
 
-	var data struct {
-		Message *string
-	}
+	var data putAttentionRequestBody
 	err := Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -239,25 +250,36 @@ func (e *Env) PutAttentionRequest(
 		return OK(request, c, w)
 	}
 
-	err = e.M.UpdateAttentionRequest(request, me.Id)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		return etx.UpdateAttentionRequest(request, me.Id)
+	})
 	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
 		return &Error{E: err}
 	}
 
-	if me.IsAdmin {
+	if isReferee {
 		return OK(request, c, w)
 	}
 
 	return OK(request.AttentionRequestPublic, c, w)
 }
 
+type patchAttentionRequestBody struct {
+	Action string
+}
+
 func (e *Env) PatchAttentionRequest(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	request, me, outerr := e.getAttentionRequestStuff(c)
+	request, me, isReferee, outerr := e.getAttentionRequestStuff(c)
 	if outerr != nil {
 		return outerr
-	} else if !me.IsAdmin {
+	} else if !isReferee {
 		return &Error{E: utils.ErrUnauthorized}
 	} else if request.ResolvedAt != nil {
 		return &Error{
@@ -265,9 +287,7 @@ func (e *Env) PatchAttentionRequest(
 		}
 	}
 
-	var data struct {
-		Action string
-	}
+	var data patchAttentionRequestBody
 	err := Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -308,17 +328,20 @@ func (e *Env) PatchAttentionRequest(
 			}
 		}
 	} else {
-		return &Error{C: http.StatusBadRequest, M: "bad action"}
+		return invalid("action", CodeEnum, "bad action")
 	}
 
-	err = e.M.UpdateAttentionRequest(request, me.Id)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		return etx.UpdateAttentionRequest(request, me.Id)
+	})
 	if err != nil {
-		return &Error{E: err}
-	}
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
 
-	if me.IsAdmin {
-		return OK(request, c, w)
+		return &Error{E: err}
 	}
 
-	return OK(request.AttentionRequestPublic, c, w)
+	return OK(request, c, w)
 }
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+	"app/utils"
+)
+
+// publicAudits are the tables whose history is as public as the rows
+// themselves, as far as the columns of their public halves go. The rest, users
+// and their belongings above all, is for admins.
+var publicAudits = map[string][]string{
+	"tournament":    models.Columns(models.TournamentPublic{}),
+	"season":        models.Columns(models.SeasonPublic{}),
+	"stage":         models.Columns(models.StagePublic{}),
+	"bracket":       models.Columns(models.BracketPublic{}),
+	"bracket_round": models.Columns(models.BracketRoundPublic{}),
+	"bracket_map":   models.Columns(models.BracketMapPublic{}),
+	"match":         models.Columns(models.MatchPublic{}),
+	"match_report":  models.Columns(models.MatchReportPublic{}),
+	"team":          models.Columns(models.TeamPublic{}),
+	"team_season":   models.Columns(models.TeamSeasonPublic{}),
+	"game":          models.Columns(models.GamePublic{}),
+	"game_map":      models.Columns(models.GameMapPublic{}),
+}
+
+type getAuditsQuery struct {
+	Offset uint64            `param:"offset"`
+	Limit  uint64            `param:"count"`
+	Filter map[string]string `param:"filter"`
+	Sort   string            `param:"sort"`
+	Cursor string            `param:"cursor"`
+	Total  bool              `param:"total"`
+	Since  string            `param:"since"`
+	Until  string            `param:"until"`
+}
+
+func (e *Env) GetAudits(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	var data getAuditsQuery
+	err := DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	var me *models.User
+	session, ok := c.Env["session"].(*models.Session)
+	if ok {
+		me, err = e.me(c, session)
+		if err != nil {
+			return &Error{E: err, C: http.StatusInternalServerError}
+		}
+	}
+
+	isAdmin := me != nil && me.Is(models.RoleAdmin)
+	table := data.Filter["table_name"]
+	filterAllowed := []string{
+		"table_name", "row_id", "column_name", "changed_at",
+	}
+	if isAdmin {
+		filterAllowed = append(filterAllowed, "changed_by")
+	} else if table == "" || data.Filter["row_id"] == "" {
+		return invalid(
+			"filter", CodeRequired, "table_name and row_id filters are mandatory",
+		)
+	} else if _, ok := publicAudits[table]; !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	if !isAdmin {
+		apierr := e.auditedVisible(me, table, data.Filter["row_id"])
+		if apierr != nil {
+			return apierr
+		}
+	}
+
+	query := models.QueryBase{
+		Offset: data.Offset,
+		Limit:  data.Limit,
+		Filter: make(map[string]string),
+		Sort:   data.Sort,
+		Cursor: data.Cursor,
+		Total:  data.Total,
+	}
+
+	for key, value := range data.Filter {
+		query.Filter[key] = value
+	}
+
+	// since and until predate filter operators, and are just shorthands now
+	since, err := parseTimeParam(data.Since)
+	if err != nil {
+		return invalid("since", CodeFormat, "bad since")
+	} else if since != nil {
+		query.Filter["changed_at:gte"] = since.Format(time.RFC3339Nano)
+	}
+
+	until, err := parseTimeParam(data.Until)
+	if err != nil {
+		return invalid("until", CodeFormat, "bad until")
+	} else if until != nil {
+		query.Filter["changed_at:lt"] = until.Format(time.RFC3339Nano)
+	}
+
+	if query.Sort == "" {
+		query.Sort = "-id"
+	}
+
+	var narrow func(*models.QueryModifier)
+	if !isAdmin {
+		narrow = func(modifier *models.QueryModifier) {
+			models.NarrowAudits(modifier, publicAudits[table])
+		}
+	}
+
+	modifier, apierr := e.listNarrowed(c, r, "audit", query,
+		filterAllowed,
+		[]string{"id", "changed_at"},
+		narrow,
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	audits, err := e.M.GetAudits(modifier)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	// who changed what is as private as updated_by
+	if !isAdmin {
+		for i := range audits {
+			audits[i].ChangedBy = nil
+		}
+	}
+
+	return OK(audits, c, w)
+}
+
+// auditedVisible hides the history of rows in unpublished seasons, like the
+// rows themselves, from all but the season's admins.
+func (e *Env) auditedVisible(me *models.User, table, id string) *Error {
+	scope, err := e.M.GetScope(table, id)
+	if err != nil {
+		return &Error{E: err}
+	} else if scope.SeasonId == "" {
+		return nil
+	}
+
+	season, err := e.M.GetSeasonById(scope.SeasonId)
+	if err != nil {
+		return &Error{E: err}
+	} else if season.PublishedAt != nil && !season.PublishedAt.After(time.Now()) {
+		return nil
+	} else if me != nil && me.Can(models.RoleTournamentAdmin, scope) {
+		return nil
+	}
+
+	return &Error{E: utils.ErrNotFound}
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter.
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// markdownCurrent returns the current value of a markdown column, the starting
+// point for rebuilding its revisions, provided that me is allowed to see them.
+func (e *Env) markdownCurrent(
+	me *models.User, table, id, column string,
+) (string, *Error) {
+	switch table + "." + column {
+	case "game.summary":
+		game, err := e.M.GetGameById(id)
+		if err != nil {
+			return "", &Error{E: err}
+		}
+
+		return game.Summary, nil
+	case "tournament.description":
+		tournament, err := e.M.GetTournamentById(id)
+		if err != nil {
+			return "", &Error{E: err}
+		}
+
+		return tournament.Description, nil
+	case "season.description", "season.rules":
+		season, err := e.M.GetSeasonById(id)
+		if err != nil {
+			return "", &Error{E: err}
+		}
+
+		if season.PublishedAt == nil || season.PublishedAt.After(time.Now()) {
+			apierr := e.authorize(
+				me, models.RoleTournamentAdmin, "season", season.Id,
+			)
+			if apierr != nil {
+				return "", apierr
+			}
+		}
+
+		if column == "rules" {
+			return season.Rules, nil
+		}
+
+		return season.Description, nil
+	case "news.body":
+		newsItem, err := e.M.GetNewsItemById(id)
+		if err != nil {
+			return "", &Error{E: err}
+		}
+
+		if newsItem.PublishedAt == nil ||
+			newsItem.PublishedAt.After(time.Now()) ||
+			newsItem.IsDeleted {
+			apierr := e.authorize(me, models.RoleNewsEditor, "news", newsItem.Id)
+			if apierr != nil {
+				return "", apierr
+			}
+		}
+
+		return newsItem.Body, nil
+	case "comment.body":
+		comment, err := e.M.GetCommentById(id)
+		if err != nil {
+			return "", &Error{E: err}
+		}
+
+		// comments are edited by their authors, mostly to take something back
+		apierr := e.authorize(
+			me, models.RoleModerator, comment.Target, comment.TargetId,
+		)
+		if apierr != nil {
+			return "", apierr
+		}
+
+		return comment.Body, nil
+	}
+
+	return "", &Error{C: http.StatusBadRequest, M: "no history for this column"}
+}
+
+type getRevisionsQuery struct {
+	Filter map[string]string `param:"filter"`
+}
+
+// GetRevisions shows how a markdown column has changed over time, edit by edit.
+func (e *Env) GetRevisions(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	var data getRevisionsQuery
+	err := DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	table := data.Filter["table_name"]
+	id := data.Filter["row_id"]
+	column := data.Filter["column_name"]
+	if table == "" || id == "" || column == "" {
+		return invalid(
+			"filter", CodeRequired,
+			"table_name, row_id and column_name filters are mandatory",
+		)
+	}
+
+	var me *models.User
+	session, ok := c.Env["session"].(*models.Session)
+	if ok {
+		me, err = e.me(c, session)
+		if err != nil {
+			return &Error{E: err, C: http.StatusInternalServerError}
+		}
+	}
+
+	current, apierr := e.markdownCurrent(me, table, id, column)
+	if apierr != nil {
+		return apierr
+	}
+
+	revisions, err := e.M.GetRevisions(table, id, column, current)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(revisions, c, w)
+}
@@ -2,10 +2,12 @@ package api
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/zenazn/goji/web"
 
+	"app/models"
 	"app/utils"
 )
 
@@ -24,6 +26,10 @@ func (e *Env) Auth(
 		return nil
 	}
 
+	if strings.HasPrefix(authorization[0], models.ApiTokenPrefix) {
+		return e.authApiToken(c, r, authorization[0])
+	}
+
 	session, err := e.M.GetSessionByToken(authorization[0])
 	if err == utils.ErrNotFound {
 		return &Error{E: utils.ErrUnauthorized, M: "bad token"}
@@ -59,3 +65,36 @@ func (e *Env) Auth(
 	c.Env["session"] = session
 	return nil
 }
+
+// authApiToken stands in for a session when a personal API token is used.
+// Whatever the token is allowed to do is decided by the handler, see
+// handlerEnv.
+func (e *Env) authApiToken(c *web.C, r *http.Request, token string) *Error {
+	apiToken, err := e.M.GetApiTokenByToken(token)
+	if err == utils.ErrNotFound {
+		return &Error{E: utils.ErrUnauthorized, M: "bad token"}
+	} else if err != nil {
+		return &Error{E: err}
+	}
+
+	now := time.Now()
+	if apiToken.ExpiresAt.Before(now) {
+		return &Error{E: utils.ErrUnauthorized, M: "token expired"}
+	}
+
+	apiToken.LastUsedAt = &now
+	apiToken.LastUsedIp = &r.RemoteAddr
+	err = e.M.UpdateApiTokenLastUsed(apiToken)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	c.Env["session"] = &models.Session{
+		UserId:     apiToken.UserId,
+		CreatedAt:  apiToken.CreatedAt,
+		LastUsedAt: now,
+		LastUsedIp: r.RemoteAddr,
+	}
+	c.Env["apiToken"] = apiToken
+	return nil
+}
@@ -9,6 +9,12 @@ import (
 	"app/utils"
 )
 
+type postBracketMapBody struct {
+	BracketId string
+	GameMapId string
+	SubPool   int `json:",string"`
+}
+
 func (e *Env) PostBracketMap(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -17,23 +23,27 @@ func (e *Env) PostBracketMap(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		BracketId string
-		GameMapId string
-		SubPool   int `json:",string"`
-	}
+	var data postBracketMapBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
-	} else if data.SubPool < 0 || data.SubPool > 9 {
-		return &Error{C: http.StatusBadRequest, M: "bad sub-pool, must be [0, 9]"}
+	}
+
+	var v Validation
+	if !v.Range("subPool", data.SubPool, 0, 9) {
+		return v.Err()
+	}
+
+	apierr := e.authorize(
+		me, models.RoleTournamentAdmin, "bracket", data.BracketId,
+	)
+	if apierr != nil {
+		return apierr
 	}
 
 	bracket, err := e.M.GetBracketById(data.BracketId)
@@ -91,10 +101,17 @@ func (e *Env) GetBracketMap(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		}
+
+		can, apierr := e.can(
+			me, models.RoleTournamentAdmin, "bracket", bracketMap.BracketId,
+		)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(bracketMap, c, w)
 		}
 	}
@@ -105,32 +122,31 @@ func (e *Env) GetBracketMap(
 func (e *Env) GetBracketMaps(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	bracketMaps, err := e.M.GetBracketMaps(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "bracket_map", data,
 		[]string{"bracket_id", "game_map_id", "sub_pool", "is_enabled"},
 		[]string{"id", "bracket_id", "game_map_id", "sub_pool"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	bracketMaps, err := e.M.GetBracketMaps(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleTournamentAdmin) {
 			return OK(bracketMaps, c, w)
 		}
 	}
@@ -19,11 +19,17 @@ func (e *Env) GetBracketRound(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+
+		can, apierr := e.can(
+			me, models.RoleTournamentAdmin, "bracket", bracketRound.BracketId,
+		)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(bracketRound, c, w)
 		}
 	}
@@ -40,22 +46,26 @@ func (e *Env) GetBracketRounds(
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	bracketRounds, err := e.M.GetBracketRounds(models.NewQueryModifier(
-		data,
+	modifier, apierr := e.list(c, r, "bracket_round", data,
 		[]string{"bracket_id", "number"},
 		[]string{"id", "bracket_id", "number"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	bracketRounds, err := e.M.GetBracketRounds(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleTournamentAdmin) {
 			return OK(bracketRounds, c, w)
 		}
 	}
@@ -68,6 +78,12 @@ func (e *Env) GetBracketRounds(
 	return OK(public, c, w)
 }
 
+type putBracketRoundBody struct {
+	Name             *string
+	Description      *string
+	MapVetoProcedure *string
+}
+
 func (e *Env) PutBracketRound(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -76,11 +92,9 @@ func (e *Env) PutBracketRound(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
 	bracketRound, err := e.M.GetBracketRoundById(c.URLParams["id"])
@@ -88,11 +102,14 @@ func (e *Env) PutBracketRound(
 		return &Error{E: err}
 	}
 
-	var data struct {
-		Name             *string
-		Description      *string
-		MapVetoProcedure *string
+	apierr := e.authorize(
+		me, models.RoleTournamentAdmin, "bracket", bracketRound.BracketId,
+	)
+	if apierr != nil {
+		return apierr
 	}
+
+	var data putBracketRoundBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
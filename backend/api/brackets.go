@@ -31,10 +31,10 @@ func parseMapVetoProcedure(s string) ([]mapVetoAction, *Error) {
 		if len(as) == 0 {
 			continue // just an extra space
 		} else if len(as) > 2 {
-			return nil, &Error{
-				C: http.StatusBadRequest,
-				M: fmt.Sprintf("bad action #%d, too long", i+1),
-			}
+			return nil, invalid(
+				"mapVetoProcedure", CodeFormat,
+				fmt.Sprintf("bad action #%d, too long", i+1),
+			)
 		}
 
 		asr := []rune(as)
@@ -48,19 +48,19 @@ func parseMapVetoProcedure(s string) ([]mapVetoAction, *Error) {
 		} else if letter == 'R' {
 			a.R = true
 		} else {
-			return nil, &Error{
-				C: http.StatusBadRequest,
-				M: fmt.Sprintf("bad action #%d, bad letter '%c'", i+1, asr[0]),
-			}
+			return nil, invalid(
+				"mapVetoProcedure", CodeFormat,
+				fmt.Sprintf("bad action #%d, bad letter '%c'", i+1, asr[0]),
+			)
 		}
 
 		if len(asr) == 2 {
 			digit := asr[1]
 			if digit < '0' || digit > '9' {
-				return nil, &Error{
-					C: http.StatusBadRequest,
-					M: fmt.Sprintf("bad action #%d, bad digit '%c'", i+1, digit),
-				}
+				return nil, invalid(
+					"mapVetoProcedure", CodeFormat,
+					fmt.Sprintf("bad action #%d, bad digit '%c'", i+1, digit),
+				)
 			}
 
 			sp := int(digit - '0')
@@ -83,15 +83,15 @@ func parseWholeNumberList(s, name string) ([]int, *Error) {
 
 		num, err := strconv.Atoi(item)
 		if err != nil {
-			return nil, &Error{
-				E: err, C: http.StatusBadRequest,
-				M: "bad " + name + " item " + strconv.Itoa(i) + ", not an integer",
-			}
+			return nil, invalid(
+				fmt.Sprintf("%s[%d]", name, i), CodeFormat,
+				"bad "+name+" item "+strconv.Itoa(i)+", not an integer",
+			)
 		} else if num < 0 {
-			return nil, &Error{
-				E: err, C: http.StatusBadRequest,
-				M: "bad " + name + " item " + strconv.Itoa(i) + ", less than zero",
-			}
+			return nil, invalid(
+				fmt.Sprintf("%s[%d]", name, i), CodeRange,
+				"bad "+name+" item "+strconv.Itoa(i)+", less than zero",
+			)
 		}
 
 		r = append(r, num)
@@ -100,6 +100,21 @@ func parseWholeNumberList(s, name string) ([]int, *Error) {
 	return r, nil
 }
 
+type postBracketBody struct {
+	StageId            string
+	Slug               string
+	Name               string
+	Abbr               string
+	Order              int `json:",string"`
+	Type               string
+	Size               int `json:",string"`
+	MapVetoProcedure   string
+	StartAt            *time.Time
+	WaitDays           string
+	SameDayWaitMinutes int `json:",string"`
+	ReportMinutes      string
+}
+
 func (e *Env) PostBracket(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -108,27 +123,12 @@ func (e *Env) PostBracket(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		StageId            string
-		Slug               string
-		Name               string
-		Abbr               string
-		Order              int `json:",string"`
-		Type               string
-		Size               int `json:",string"`
-		MapVetoProcedure   string
-		StartAt            *time.Time
-		WaitDays           string
-		SameDayWaitMinutes int `json:",string"`
-		ReportMinutes      string
-	}
+	var data postBracketBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -136,6 +136,11 @@ func (e *Env) PostBracket(
 		data.Size = 0
 	}
 
+	apierr := e.authorize(me, models.RoleTournamentAdmin, "stage", data.StageId)
+	if apierr != nil {
+		return apierr
+	}
+
 	procedure, apierr := parseMapVetoProcedure(data.MapVetoProcedure)
 	if apierr != nil {
 		return apierr
@@ -147,10 +152,9 @@ func (e *Env) PostBracket(
 	if apierr != nil {
 		return apierr
 	} else if len(waitDays) == 0 {
-		return &Error{
-			E: err, C: http.StatusBadRequest,
-			M: "bad waitDays, need at least one interval",
-		}
+		return invalid(
+			"waitDays", CodeRequired, "bad waitDays, need at least one interval",
+		)
 	}
 
 	reportMinutes, apierr := parseWholeNumberList(
@@ -182,10 +186,9 @@ func (e *Env) PostBracket(
 		if data.Type == "bcl-sc16-swiss" || data.Type == "ace-pre-swiss" {
 			return nil
 		} else if data.StartAt == nil {
-			return &Error{
-				C: http.StatusBadRequest,
-				M: "first match time is required for non-swiss",
-			}
+			return invalid(
+				"startAt", CodeRequired, "first match time is required for non-swiss",
+			)
 		} else if data.Type == "bcl-s8-group-stage" {
 			return groupStageBracket(
 				etx, bracket, me, *data.StartAt, waitDays, data.SameDayWaitMinutes,
@@ -198,7 +201,7 @@ func (e *Env) PostBracket(
 			)
 		}
 
-		return &Error{E: err, C: http.StatusBadRequest, M: "bad bracket type"}
+		return invalid("type", CodeEnum, "bad bracket type")
 	})
 	if err != nil {
 		apierr, ok := err.(*Error)
@@ -222,11 +225,9 @@ func groupStageBracket(
 	eM *models.Env, bracket *models.Bracket, me *models.User, startAt time.Time,
 	waitDays []int, sameDayWaitMinutes int, reportMinutes []int,
 ) error {
-	if bracket.Size < 2 || bracket.Size > 16 {
-		return &Error{
-			C: http.StatusBadRequest,
-			M: "number of participants isn't in [2, 16] range",
-		}
+	var v Validation
+	if !v.Range("size", bracket.Size, 2, 16) {
+		return v.Err()
 	}
 
 	roundCount := 0
@@ -344,11 +345,9 @@ func playoffsBracket(
 	eM *models.Env, bracket *models.Bracket, me *models.User, startAt time.Time,
 	waitDays []int, sameDayWaitMinutes int, reportMinutes []int,
 ) error {
-	if bracket.Size < 2 || bracket.Size > 256 {
-		return &Error{
-			C: http.StatusBadRequest,
-			M: "number of participants isn't in [2, 256] range",
-		}
+	var v Validation
+	if !v.Range("size", bracket.Size, 2, 256) {
+		return v.Err()
 	}
 
 	var pps []tempPp
@@ -512,11 +511,15 @@ func (e *Env) GetBracket(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+
+		can, apierr := e.can(me, models.RoleTournamentAdmin, "bracket", bracket.Id)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(bracket, c, w)
 		}
 	}
@@ -527,33 +530,32 @@ func (e *Env) GetBracket(
 func (e *Env) GetBrackets(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	brackets, err := e.M.GetBrackets(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "bracket", data,
 		[]string{"stage_id", "slug"},
 		[]string{"id", "stage_id", "name", "order"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	brackets, err := e.M.GetBrackets(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleTournamentAdmin) {
 			return OK(brackets, c, w)
 		}
 	}
@@ -566,6 +568,14 @@ func (e *Env) GetBrackets(
 	return OK(public, c, w)
 }
 
+type putBracketBody struct {
+	Slug             *string
+	Name             *string
+	Abbr             *string
+	Order            *int `json:",string"`
+	MapVetoProcedure *string
+}
+
 func (e *Env) PutBracket(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -574,11 +584,16 @@ func (e *Env) PutBracket(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	apierr := e.authorize(
+		me, models.RoleTournamentAdmin, "bracket", c.URLParams["id"],
+	)
+	if apierr != nil {
+		return apierr
 	}
 
 	bracket, err := e.M.GetBracketById(c.URLParams["id"])
@@ -586,13 +601,7 @@ func (e *Env) PutBracket(
 		return &Error{E: err}
 	}
 
-	var data struct {
-		Slug             *string
-		Name             *string
-		Abbr             *string
-		Order            *int `json:",string"`
-		MapVetoProcedure *string
-	}
+	var data putBracketBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -639,14 +648,30 @@ func (e *Env) PutBracket(
 		return OK(bracket, c, w)
 	}
 
-	err = e.M.UpdateBracket(bracket, me.Id)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		return etx.UpdateBracket(bracket, me.Id)
+	})
 	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
 		return &Error{E: err}
 	}
 
 	return OK(bracket, c, w)
 }
 
+type patchBracketBody struct {
+	Action        string
+	Teams         []string
+	Maps          []string
+	DefaultTime   *time.Time
+	MapsPerMatch  int `json:",string"`
+	ReportMinutes int `json:",string"`
+}
+
 func (e *Env) PatchBracket(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -655,21 +680,19 @@ func (e *Env) PatchBracket(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Action        string
-		Teams         []string
-		Maps          []string
-		DefaultTime   *time.Time
-		MapsPerMatch  int `json:",string"`
-		ReportMinutes int `json:",string"`
+	apierr := e.authorize(
+		me, models.RoleTournamentAdmin, "bracket", c.URLParams["id"],
+	)
+	if apierr != nil {
+		return apierr
 	}
+
+	var data patchBracketBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -681,15 +704,14 @@ func (e *Env) PatchBracket(
 	} else if bracket.Type == "bcl-sc16-swiss" ||
 		bracket.Type == "ace-pre-swiss" {
 		if data.Action != "new-swiss-round" {
-			return &Error{C: http.StatusBadRequest, M: "bad action"}
+			return invalid("action", CodeEnum, "bad action")
 		} else if data.DefaultTime == nil {
-			return &Error{
-				C: http.StatusBadRequest,
-				M: "default time is mandatory for swiss",
-			}
+			return invalid(
+				"defaultTime", CodeRequired, "default time is mandatory for swiss",
+			)
 		}
 
-		err = e.M.Atomic(func(etx *models.Env) error {
+		err = e.conditionally(c, func(etx *models.Env) error {
 			return swissPair(
 				etx, bracket, me, data.Teams, *data.DefaultTime, data.ReportMinutes,
 			)
@@ -729,14 +751,14 @@ func (e *Env) PatchBracket(
 	// }
 
 	if data.Action != "prepare" {
-		return &Error{C: http.StatusBadRequest, M: "bad action"}
+		return invalid("action", CodeEnum, "bad action")
 	}
 
 	mapCount := len(data.Maps)
 	mapsPerMatch := data.MapsPerMatch
 	if bracket.Type == "bcl-s8-group-stage" {
 		if mapCount < mapsPerMatch {
-			return &Error{C: http.StatusBadRequest, M: "need at least two maps"}
+			return invalid("maps", CodeLength, "need at least two maps")
 		} else if mapsPerMatch != 0 && mapCount%mapsPerMatch != 0 {
 			mapCount -= mapCount % mapsPerMatch
 		}
@@ -744,11 +766,11 @@ func (e *Env) PatchBracket(
 
 	teamCount := len(data.Teams)
 	if teamCount != bracket.Size {
-		return &Error{
-			C: http.StatusBadRequest,
-			M: "bracket fits " + strconv.Itoa(bracket.Size) +
-				" teams, but got " + strconv.Itoa(teamCount),
-		}
+		return invalid(
+			"teams", CodeLength,
+			"bracket fits "+strconv.Itoa(bracket.Size)+
+				" teams, but got "+strconv.Itoa(teamCount),
+		)
 	}
 
 	matches, err := e.M.GetMatchesForBracket(bracket.Id)
@@ -761,7 +783,7 @@ func (e *Env) PatchBracket(
 	}
 
 	mapCache := make(map[string]*models.GameMap)
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		fillSeed := func(seed *int, slot **string) error {
 			if seed == nil {
 				return nil
@@ -866,7 +888,7 @@ func swissPair(
 	eM *models.Env, bracket *models.Bracket, me *models.User, teams []string,
 	defaultTime time.Time, reportMinutes int,
 ) error {
-	rounds, matches, reports, apierr := standingsStuff(eM, bracket)
+	rounds, matches, reports, apierr := standingsStuff(eM, bracket, nil)
 	if apierr != nil {
 		return apierr
 	}
@@ -895,7 +917,7 @@ func swissPair(
 	}
 
 	teamSeasons, err := eM.GetTeamSeasons(models.NewQueryModifier(
-		models.QueryBase{0, 0, map[string]string{"season_id": stage.SeasonId}, ""},
+		models.QueryBase{Filter: map[string]string{"season_id": stage.SeasonId}},
 		[]string{"season_id"},
 		[]string{},
 	))
@@ -1211,6 +1233,16 @@ type standingData struct {
 	EqualsBelow int `json:"equalsBelow"`
 }
 
+type bracketStandings struct {
+	Id        string          `json:"id"`
+	AsOf      *time.Time      `json:"asOf,omitempty"`
+	Standings []*standingData `json:"standings"`
+}
+
+type getBracketStandingsQuery struct {
+	AsOf string `param:"asOf"`
+}
+
 func (e *Env) GetBracketStandings(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -1219,7 +1251,35 @@ func (e *Env) GetBracketStandings(
 		return &Error{E: err}
 	}
 
-	rounds, matches, reports, apierr := standingsStuff(e.M, bracket)
+	var data getBracketStandingsQuery
+	err = DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	asOf, err := parseTimeParam(data.AsOf)
+	if err != nil {
+		return invalid("asOf", CodeFormat, "bad asOf")
+	} else if asOf != nil {
+		// looking back is for figuring out disputes, not for the public
+		var me *models.User
+		session, ok := c.Env["session"].(*models.Session)
+		if ok {
+			me, err = e.me(c, session)
+			if err != nil {
+				return &Error{E: err, C: http.StatusInternalServerError}
+			}
+		}
+
+		apierr := e.authorize(
+			me, models.RoleTournamentAdmin, "bracket", bracket.Id,
+		)
+		if apierr != nil {
+			return apierr
+		}
+	}
+
+	rounds, matches, reports, apierr := standingsStuff(e.M, bracket, asOf)
 	if apierr != nil {
 		return apierr
 	}
@@ -1240,13 +1300,15 @@ func (e *Env) GetBracketStandings(
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	return OK(struct {
-		Id        string          `json:"id"`
-		Standings []*standingData `json:"standings"`
-	}{bracket.Id, standings}, c, w)
+	return OK(bracketStandings{bracket.Id, asOf, standings}, c, w)
 }
 
-func standingsStuff(eM *models.Env, bracket *models.Bracket) (
+// standingsStuff loads everything the standings are computed from. If asOf is
+// given, it's all rewound to that moment, see models.GetMatchesAsOf and
+// models.GetMatchReportsAsOf.
+func standingsStuff(
+	eM *models.Env, bracket *models.Bracket, asOf *time.Time,
+) (
 	rounds []models.BracketRound,
 	matches []models.Match,
 	reports map[string]*models.MatchReport,
@@ -1254,7 +1316,10 @@ func standingsStuff(eM *models.Env, bracket *models.Bracket) (
 ) {
 	var err error
 	qm := models.NewQueryModifier(
-		models.QueryBase{0, 0, map[string]string{"bracket_id": bracket.Id}, "id"},
+		models.QueryBase{
+			Filter: map[string]string{"bracket_id": bracket.Id},
+			Sort:   "id",
+		},
 		[]string{"bracket_id"},
 		[]string{"id"},
 	)
@@ -1265,12 +1330,25 @@ func standingsStuff(eM *models.Env, bracket *models.Bracket) (
 		return
 	}
 
-	matches, err = eM.GetMatches(qm)
+	if asOf == nil {
+		matches, err = eM.GetMatches(qm)
+	} else {
+		allRounds := rounds
+		rounds = make([]models.BracketRound, 0, len(allRounds))
+		for _, round := range allRounds {
+			if !round.CreatedAt.After(*asOf) {
+				rounds = append(rounds, round)
+			}
+		}
+
+		matches, err = eM.GetMatchesAsOf(qm, *asOf)
+	}
+
 	if err != nil {
 		apierr = &Error{E: err}
 		return
-	} else if bracket.Type == "bcl-s8-group-stage" &&
-		(matches[0].TeamX == nil || matches[0].TeamY == nil) {
+	} else if bracket.Type == "bcl-s8-group-stage" && (len(matches) == 0 ||
+		matches[0].TeamX == nil || matches[0].TeamY == nil) {
 		// TODO: move this check out of here, and just send empty standings instead
 		apierr = &Error{
 			C: http.StatusBadRequest, M: "this bracket isn't prepped yet",
@@ -1279,8 +1357,30 @@ func standingsStuff(eM *models.Env, bracket *models.Bracket) (
 	}
 
 	reports = make(map[string]*models.MatchReport)
+	if asOf != nil {
+		ids := make([]string, 0, len(matches))
+		for _, match := range matches {
+			if match.MatchReportId != nil {
+				ids = append(ids, *match.MatchReportId)
+			}
+		}
+
+		var rewound []models.MatchReport
+		rewound, err = eM.GetMatchReportsAsOf(ids, *asOf)
+		if err != nil {
+			apierr = &Error{E: err, C: http.StatusInternalServerError}
+			return
+		}
+
+		for i := range rewound {
+			reports[rewound[i].Id] = &rewound[i]
+		}
+	}
+
 	for _, match := range matches {
-		if match.MatchReportId == nil {
+		// a report missing at asOf predates the audit of match_report_id, and
+		// is taken as it is now, like the scores
+		if match.MatchReportId == nil || reports[*match.MatchReportId] != nil {
 			continue
 		}
 
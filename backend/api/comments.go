@@ -16,6 +16,12 @@ const (
 	commentLenMax = 32768 // inspired by StackExchange's 30K
 )
 
+type postCommentBody struct {
+	Target   string
+	TargetId string
+	Body     string
+}
+
 func (e *Env) PostComment(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -24,24 +30,25 @@ func (e *Env) PostComment(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
 
-	var data struct {
-		Target   string
-		TargetId string
-		Body     string
-	}
+	var data postCommentBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	} else if data.Target == "news" {
 		newsItem, err := e.M.GetNewsItemById(data.TargetId)
 		if err != nil {
-			return &Error{E: err, C: http.StatusBadRequest, M: "bad target id"}
-		} else if !me.IsAdmin &&
+			return invalid("targetId", CodeId, "bad target id")
+		}
+
+		isEditor, apierr := e.can(me, models.RoleNewsEditor, "news", newsItem.Id)
+		if apierr != nil {
+			return apierr
+		} else if !isEditor &&
 			(newsItem.PublishedAt == nil ||
 				newsItem.PublishedAt.After(time.Now()) ||
 				newsItem.IsDeleted) {
@@ -50,15 +57,15 @@ func (e *Env) PostComment(
 	} else if data.Target == "match" {
 		_, err = e.M.GetMatchById(data.TargetId)
 		if err != nil {
-			return &Error{E: err, C: http.StatusBadRequest, M: "bad target id"}
+			return invalid("targetId", CodeId, "bad target id")
 		}
 	} else {
-		return &Error{C: http.StatusBadRequest, M: "bad target"}
+		return invalid("target", CodeEnum, "bad target")
 	}
 
 	data.Body = strings.TrimSpace(data.Body)
 	if len(data.Body) < commentLenMin || len(data.Body) > commentLenMax {
-		return &Error{C: http.StatusBadRequest, M: "invalid body length"}
+		return invalid("body", CodeLength, "invalid body length")
 	}
 
 	comment := &models.Comment{
@@ -89,10 +96,19 @@ func (e *Env) GetComment(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.Id == comment.CreatedBy || me.IsAdmin {
+		} else if me.Id == comment.CreatedBy {
+			return OK(comment, c, w)
+		}
+
+		can, apierr := e.can(
+			me, models.RoleModerator, comment.Target, comment.TargetId,
+		)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(comment, c, w)
 		}
 	}
@@ -113,10 +129,15 @@ func (e *Env) GetComments(
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	comments, err := e.M.GetComments(models.NewQueryModifier(data,
+	modifier, apierr := e.list(c, r, "comment", data,
 		[]string{"target", "target_id", "is_deleted", "created_by"},
 		[]string{}, // TODO
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	comments, err := e.M.GetComments(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
@@ -124,10 +145,10 @@ func (e *Env) GetComments(
 	session, ok := c.Env["session"].(*models.Session)
 	var myId string // empty string doesn't match any valid user ID
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleModerator) {
 			return OK(comments, c, w)
 		} else {
 			myId = me.Id
@@ -146,6 +167,10 @@ func (e *Env) GetComments(
 	return OK(public, c, w)
 }
 
+type putCommentBody struct {
+	Body *string
+}
+
 func (e *Env) PutComment(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -154,26 +179,31 @@ func (e *Env) PutComment(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
 
-	var data struct {
-		Body *string
-	}
+	var data putCommentBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
 	var comment *models.Comment
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		var inerr error
 		comment, inerr = etx.GetCommentById(c.URLParams["id"])
 		if inerr != nil {
 			return inerr
-		} else if me.Id != comment.CreatedBy && !me.IsAdmin {
+		}
+
+		isModerator, apierr := e.can(
+			me, models.RoleModerator, comment.Target, comment.TargetId,
+		)
+		if apierr != nil {
+			return apierr
+		} else if me.Id != comment.CreatedBy && !isModerator {
 			return &Error{E: utils.ErrUnauthorized}
 		} else if comment.IsDeleted {
 			return &Error{
@@ -187,7 +217,7 @@ func (e *Env) PutComment(
 			*data.Body = strings.TrimSpace(*data.Body)
 			if *data.Body != comment.Body {
 				if len(*data.Body) < commentLenMin || len(*data.Body) > commentLenMax {
-					return &Error{C: http.StatusBadRequest, M: "invalid body length"}
+					return invalid("body", CodeLength, "invalid body length")
 				}
 
 				inerr = etx.Diff(
@@ -221,6 +251,10 @@ func (e *Env) PutComment(
 	return OK(comment, c, w)
 }
 
+type patchCommentBody struct {
+	Action string
+}
+
 func (e *Env) PatchComment(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -229,26 +263,31 @@ func (e *Env) PatchComment(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
 
-	var data struct {
-		Action string
-	}
+	var data patchCommentBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
 	var comment *models.Comment
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		var inerr error
 		comment, inerr = etx.GetCommentById(c.URLParams["id"])
 		if inerr != nil {
 			return inerr
-		} else if me.Id != comment.CreatedBy && !me.IsAdmin {
+		}
+
+		isModerator, apierr := e.can(
+			me, models.RoleModerator, comment.Target, comment.TargetId,
+		)
+		if apierr != nil {
+			return apierr
+		} else if me.Id != comment.CreatedBy && !isModerator {
 			return &Error{E: utils.ErrUnauthorized}
 		} else if data.Action == "delete" {
 			if comment.IsDeleted {
@@ -263,15 +302,15 @@ func (e *Env) PatchComment(
 				return &Error{
 					C: http.StatusBadRequest, M: "can't undelete a non-deleted comment",
 				}
-			} else if !me.IsAdmin {
+			} else if !isModerator {
 				return &Error{
-					C: http.StatusBadRequest, M: "only admins can undelete comments",
+					C: http.StatusBadRequest, M: "only moderators can undelete comments",
 				}
 			}
 
 			comment.IsDeleted = false
 		} else {
-			return &Error{C: http.StatusBadRequest, M: "bad action"}
+			return invalid("action", CodeEnum, "bad action")
 		}
 
 		return etx.UpdateComment(comment, me.Id)
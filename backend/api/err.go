@@ -14,6 +14,7 @@ import (
 type Error struct {
 	E error       `json:"-"`
 	M string      `json:"message"`
+	P Validation  `json:"problems,omitempty"` // see Validation
 	D interface{} `json:"-"`
 	C int         `json:"-"`
 }
@@ -68,6 +69,10 @@ func (err *Error) Handle(
 	} else {
 		http.Error(w, http.StatusText(err.C), err.C)
 		packet.Level = raven.ERROR
+		if req, ok := c.Env["idempotency"].(*idempotentRequest); ok {
+			delete(c.Env, "idempotency")
+			req.finish(err.C, nil)
+		}
 	}
 
 	log.Printf("err.E: %v\n", err.E)
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+	"app/utils"
+)
+
+// versioned maps the paths of resources whose rows keep track of when they
+// were last changed to their tables.
+var versioned = map[string]string{
+	"users":              "user",
+	"teams":              "team",
+	"user_teams":         "user_team",
+	"games":              "game",
+	"game_maps":          "game_map",
+	"user_games":         "user_game",
+	"tournaments":        "tournament",
+	"seasons":            "season",
+	"team_seasons":       "team_season",
+	"stages":             "stage",
+	"brackets":           "bracket",
+	"matches":            "match",
+	"match_reports":      "match_report",
+	"comments":           "comment",
+	"attention_requests": "attention_request",
+	"news_items":         "news_item",
+}
+
+// etag is made of the row's version and whoever's asking, since what they see
+// depends on who they are.
+func etag(version time.Time, session *models.Session) string {
+	tag := strconv.FormatInt(version.UnixNano(), 36)
+	if session != nil {
+		tag += "." + session.UserId
+	}
+
+	return `"` + tag + `"`
+}
+
+// etagListed tells whether an If-Match or If-None-Match header lists tag. Only
+// the latter can use weak comparison.
+func etagListed(header, tag string, weak bool) bool {
+	for _, listed := range strings.Split(header, ",") {
+		listed = strings.TrimSpace(listed)
+		if weak {
+			listed = strings.TrimPrefix(listed, "W/")
+		}
+
+		if listed == "*" || listed == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// conditionalRow is what a PUT or PATCH with If-Match is about to change.
+type conditionalRow struct {
+	table, id, ifMatch string
+}
+
+// Preconditions is a middleware that makes requests for single resources
+// conditional, going by their versions. GETs get an ETag, and a 304 if the
+// client already has it, while PUTs and PATCHes get a 412 if If-Match doesn't
+// list the current one, so that people don't overwrite each other's changes.
+// Since the row can change after this, handlers make their changes in
+// conditionally, which checks again.
+func (e *Env) Preconditions(
+	c *web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 {
+		return nil
+	}
+
+	table, ok := versioned[parts[0]]
+	if !ok {
+		return nil
+	}
+
+	switch r.Method {
+	case "GET":
+		// included rows have versions of their own
+		if r.URL.Query().Get("include") != "" {
+			return nil
+		}
+	case "PUT", "PATCH":
+		if r.Header.Get("If-Match") == "" {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	version, err := e.M.GetVersion(table, parts[1])
+	if err == utils.ErrNotFound {
+		return nil // up to the handler
+	} else if err != nil {
+		return &Error{E: err}
+	}
+
+	session, _ := c.Env["session"].(*models.Session)
+	tag := etag(version, session)
+	if r.Method == "GET" {
+		c.Env["etag"] = tag
+		c.Env["etagListed"] = etagListed(r.Header.Get("If-None-Match"), tag, true)
+	} else if !etagListed(r.Header.Get("If-Match"), tag, false) {
+		return changed()
+	} else {
+		c.Env["conditionalRow"] = conditionalRow{
+			table, parts[1], r.Header.Get("If-Match"),
+		}
+	}
+
+	return nil
+}
+
+func changed() *Error {
+	return &Error{
+		C: http.StatusPreconditionFailed,
+		M: "this has changed in the meantime, reload it and try again",
+	}
+}
+
+// conditionally runs op in a transaction, in which, if the request has
+// If-Match, the row is locked first, and has to still be one of the versions
+// listed, or else it's a 412 and op doesn't run.
+func (e *Env) conditionally(c web.C, op func(etx *models.Env) error) error {
+	return e.M.Atomic(func(etx *models.Env) error {
+		row, ok := c.Env["conditionalRow"].(conditionalRow)
+		if !ok {
+			return op(etx)
+		}
+
+		version, err := etx.LockVersion(row.table, row.id)
+		if err == utils.ErrNotFound {
+			return op(etx) // up to the handler
+		} else if err != nil {
+			return err
+		}
+
+		session, _ := c.Env["session"].(*models.Session)
+		if !etagListed(row.ifMatch, etag(version, session), false) {
+			return changed()
+		}
+
+		return op(etx)
+	})
+}
@@ -9,6 +9,16 @@ import (
 	"app/utils"
 )
 
+type postGameMapBody struct {
+	GameId    string
+	Name      string
+	Abbr      string
+	SideX     string
+	SideXAbbr string
+	SideY     string
+	SideYAbbr string
+}
+
 func (e *Env) PostGameMap(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -17,22 +27,14 @@ func (e *Env) PostGameMap(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: utils.ErrUnauthorized}
-	} else if !me.IsAdmin {
+	} else if !me.Is(models.RoleAdmin) {
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		GameId    string
-		Name      string
-		Abbr      string
-		SideX     string
-		SideXAbbr string
-		SideY     string
-		SideYAbbr string
-	}
+	var data postGameMapBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -68,11 +70,11 @@ func (e *Env) GetGameMap(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleAdmin) {
 			return OK(gameMap, c, w)
 		}
 	}
@@ -83,33 +85,32 @@ func (e *Env) GetGameMap(
 func (e *Env) GetGameMaps(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	gameMaps, err := e.M.GetGameMaps(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "game_map", data,
 		[]string{"game_id"},
 		[]string{"id", "game_id", "name", "abbr"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	gameMaps, err := e.M.GetGameMaps(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleAdmin) {
 			return OK(gameMaps, c, w)
 		}
 	}
@@ -122,6 +123,15 @@ func (e *Env) GetGameMaps(
 	return OK(public, c, w)
 }
 
+type putGameMapBody struct {
+	Name      *string
+	Abbr      *string
+	SideX     *string
+	SideXAbbr *string
+	SideY     *string
+	SideYAbbr *string
+}
+
 func (e *Env) PutGameMap(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -130,10 +140,10 @@ func (e *Env) PutGameMap(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
+	} else if !me.Is(models.RoleAdmin) {
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
@@ -142,14 +152,7 @@ func (e *Env) PutGameMap(
 		return &Error{E: err}
 	}
 
-	var data struct {
-		Name      *string
-		Abbr      *string
-		SideX     *string
-		SideXAbbr *string
-		SideY     *string
-		SideYAbbr *string
-	}
+	var data putGameMapBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -191,8 +194,15 @@ func (e *Env) PutGameMap(
 		return OK(gameMap, c, w)
 	}
 
-	err = e.M.UpdateGameMap(gameMap, me.Id)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		return etx.UpdateGameMap(gameMap, me.Id)
+	})
 	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
 		return &Error{E: err}
 	}
 
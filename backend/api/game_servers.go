@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+)
+
+// GameServerStaleAfter is how long a server's status is trusted for after the
+// worker last checked it, since a dead worker doesn't report anything.
+const GameServerStaleAfter = time.Minute * 2
+
+type getGameServersQuery struct {
+	Offset uint64            `param:"offset"`
+	Limit  uint64            `param:"count"`
+	Filter map[string]string `param:"filter"`
+	Sort   string            `param:"sort"`
+	Cursor string            `param:"cursor"`
+	Total  bool              `param:"total"`
+	Free   bool              `param:"free"`
+}
+
+func (e *Env) GetGameServer(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	gameServer, err := e.M.GetGameServerById(c.URLParams["id"])
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	session, ok := c.Env["session"].(*models.Session)
+	if ok {
+		me, err := e.me(c, session)
+		if err != nil {
+			return &Error{E: err, C: http.StatusInternalServerError}
+		} else if me.Is(models.RoleAdmin) {
+			return OK(gameServer, c, w)
+		}
+	}
+
+	return OK(gameServer.GameServerPublic, c, w)
+}
+
+// GetGameServers lists verification servers. free is a shorthand for the
+// ones that are online, have been checked recently and have slots to spare,
+// the emptiest first, which is what the frontend sends players to.
+func (e *Env) GetGameServers(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	var data getGameServersQuery
+	err := DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	query := models.QueryBase{
+		Offset: data.Offset,
+		Limit:  data.Limit,
+		Filter: make(map[string]string),
+		Sort:   data.Sort,
+		Cursor: data.Cursor,
+		Total:  data.Total,
+	}
+
+	for key, value := range data.Filter {
+		query.Filter[key] = value
+	}
+
+	if data.Free {
+		checkedSince := time.Now().Add(-GameServerStaleAfter)
+		query.Filter["status"] = models.GameServerOnline
+		query.Filter["free_slots:gt"] = "0"
+		query.Filter["checked_at:gte"] = checkedSince.Format(time.RFC3339Nano)
+		if query.Sort == "" {
+			query.Sort = "-free_slots"
+		}
+	}
+
+	modifier, apierr := e.list(c, r, "game_server", query,
+		[]string{"game_id", "status", "free_slots", "checked_at"},
+		[]string{"id", "name", "players", "free_slots", "checked_at"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	gameServers, err := e.M.GetGameServers(modifier)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	session, ok := c.Env["session"].(*models.Session)
+	if ok {
+		me, err := e.me(c, session)
+		if err != nil {
+			return &Error{E: err, C: http.StatusInternalServerError}
+		} else if me.Is(models.RoleAdmin) {
+			return OK(gameServers, c, w)
+		}
+	}
+
+	public := make([]models.GameServerPublic, 0, len(gameServers))
+	for _, gameServer := range gameServers {
+		public = append(public, gameServer.GameServerPublic)
+	}
+
+	return OK(public, c, w)
+}
@@ -10,6 +10,16 @@ import (
 	"app/utils"
 )
 
+type postGameBody struct {
+	Slug               string
+	Name               string
+	Abbr               string
+	ReleasedAt         *time.Time
+	Cover              string
+	Summary            string
+	VerificationHandle string
+}
+
 func (e *Env) PostGame(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -18,23 +28,15 @@ func (e *Env) PostGame(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
-	if !me.IsAdmin {
+	if !me.Is(models.RoleAdmin) {
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Slug               string
-		Name               string
-		Abbr               string
-		ReleasedAt         *time.Time
-		Cover              string
-		Summary            string
-		VerificationHandle string
-	}
+	var data postGameBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -79,10 +81,10 @@ func (e *Env) GetGame(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleAdmin) {
 			return OK(game, c, w)
 		}
 	}
@@ -93,32 +95,31 @@ func (e *Env) GetGame(
 func (e *Env) GetGames(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	games, err := e.M.GetGames(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
-		[]string{"franchise_id", "slug"},
+	modifier, apierr := e.list(c, r, "game", data,
+		[]string{"franchise_id", "slug", "name:text"},
 		[]string{"id", "franchise_id", "name", "abbr", "released_at"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	games, err := e.M.GetGames(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleAdmin) {
 			return OK(games, c, w)
 		}
 	}
@@ -131,6 +132,15 @@ func (e *Env) GetGames(
 	return OK(public, c, w)
 }
 
+type putGameBody struct {
+	Slug       *string
+	Name       *string
+	Abbr       *string
+	ReleasedAt *time.Time
+	Cover      *string
+	Summary    *string
+}
+
 func (e *Env) PutGame(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -139,28 +149,21 @@ func (e *Env) PutGame(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
+	} else if !me.Is(models.RoleAdmin) {
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Slug       *string
-		Name       *string
-		Abbr       *string
-		ReleasedAt *time.Time
-		Cover      *string
-		Summary    *string
-	}
+	var data putGameBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
 	var game *models.Game
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		var inerr error
 		game, inerr = etx.GetGameById(c.URLParams["id"])
 		if inerr != nil {
@@ -4,6 +4,9 @@ import (
 	"net/http"
 
 	"github.com/zenazn/goji/web"
+
+	"app/models"
+	"app/utils"
 )
 
 type handlerFunc func(
@@ -13,8 +16,9 @@ type handlerFunc func(
 ) *Error
 
 type handlerEnv struct {
-	e *Env
-	h handlerFunc
+	e      *Env
+	h      handlerFunc
+	scopes []string
 }
 
 func (e *handlerEnv) handle(
@@ -22,9 +26,47 @@ func (e *handlerEnv) handle(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
+	apiToken, ok := c.Env["apiToken"].(*models.ApiToken)
+	if ok {
+		// The token only carries the scopes this handler cares about from here
+		// on, so that, for instance, admin-read doesn't leak into reporting.
+		granted := e.grantedScopes(apiToken, r)
+		if len(granted) == 0 {
+			(&Error{
+				E: utils.ErrForbidden, M: "token lacks the scope for this request",
+			}).Handle(e.e, c, w, r)
+			return
+		}
+
+		narrowed := *apiToken
+		narrowed.Scopes = granted
+		c.Env["apiToken"] = &narrowed
+	}
+
 	e.h(c, w, r).Handle(e.e, c, w, r)
 }
 
-func (e *Env) NewHandler(h handlerFunc) web.HandlerType {
-	return (&handlerEnv{e, h}).handle
+func (e *handlerEnv) grantedScopes(
+	apiToken *models.ApiToken, r *http.Request,
+) []string {
+	scopes := e.scopes
+	if len(scopes) == 0 && (r.Method == "GET" || r.Method == "HEAD") {
+		scopes = []string{ScopeRead, ScopeAdminRead}
+	}
+
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if apiToken.HasScope(scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	return granted
+}
+
+// NewHandler wraps h, which, besides sessions, accepts API tokens that have at
+// least one of the given scopes. Without any, reading is open to tokens with
+// read or admin-read, and writing is off-limits.
+func (e *Env) NewHandler(h handlerFunc, scopes ...string) web.HandlerType {
+	return (&handlerEnv{e, h, scopes}).handle
 }
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/zenazn/goji/web"
+	"github.com/zenazn/goji/web/middleware"
+
+	"app/models"
+	"app/utils"
+)
+
+const (
+	// IdempotencyKeyAge is how long responses are kept around for retries.
+	IdempotencyKeyAge = time.Hour * 24
+	// IdempotencyClaimAge is how long a request can take before a retry can
+	// take its key over, assuming it has died.
+	IdempotencyClaimAge  = time.Minute
+	idempotencyKeyMaxLen = 255
+)
+
+// secretResponses are the paths of POSTs whose responses carry the only copy
+// of a secret, like an API token, which mustn't sit in the database in
+// plaintext. Retries of them are answered with a conflict instead.
+var secretResponses = map[string]bool{
+	"/sessions":     true,
+	"/oauth_states": true,
+	"/api_tokens":   true,
+}
+
+// idempotentRequest is a POST that Respond has to remember the response to.
+type idempotentRequest struct {
+	eM  *models.Env
+	key *models.IdempotencyKey
+}
+
+// finish remembers successful responses, and forgets about the key
+// otherwise, so that the client can retry it.
+func (req *idempotentRequest) finish(status int, body []byte) error {
+	if status < 200 || status > 299 {
+		return req.eM.DeleteIdempotencyKey(req.key)
+	}
+
+	req.key.Status = &status
+	req.key.Body = body
+	if secretResponses[req.key.Path] {
+		req.key.Body = nil
+	}
+
+	return req.eM.UpdateIdempotencyKey(req.key)
+}
+
+// Idempotency is a middleware that makes POSTs with an Idempotency-Key header
+// safe to retry, by replaying the original response instead of running the
+// handler again. Keys are per user, so anonymous requests can't have them.
+func (e *Env) Idempotency(
+	c *web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	value := r.Header.Get("Idempotency-Key")
+	if r.Method != "POST" || value == "" {
+		return nil
+	}
+
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return nil
+	} else if len(value) > idempotencyKeyMaxLen {
+		return invalid(
+			"Idempotency-Key", CodeLength, "idempotency key is too long",
+		)
+	}
+
+	// the body is read ahead of the handler, for retries to be compared by
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	hash := utils.Blake2b256(string(body))
+	now := time.Now()
+	err = e.M.DeleteIdempotencyKeysOlderThan(now.Add(-IdempotencyKeyAge))
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	key := &models.IdempotencyKey{
+		UserId:      session.UserId,
+		Key:         value,
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		RequestHash: hash,
+	}
+	claimed, err := e.M.ClaimIdempotencyKey(key, now.Add(-IdempotencyClaimAge))
+	if err != nil {
+		return &Error{E: err}
+	} else if claimed {
+		c.Env["idempotency"] = &idempotentRequest{e.M, key}
+		return nil
+	} else if key.Method != r.Method || key.Path != r.URL.Path ||
+		!bytes.Equal(key.RequestHash, hash) {
+		return &Error{
+			C: http.StatusUnprocessableEntity,
+			M: "this idempotency key was used for another request",
+		}
+	} else if key.Status == nil {
+		return &Error{
+			C: http.StatusConflict,
+			M: "a request with this idempotency key is still in progress",
+		}
+	} else if secretResponses[key.Path] {
+		return &Error{
+			C: http.StatusConflict,
+			M: "this request has succeeded, but its response can't be replayed",
+		}
+	}
+
+	w.Header().Set("Request-Id", middleware.GetReqID(*c))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(*key.Status)
+	w.Write(key.Body)
+	return errResponded
+}
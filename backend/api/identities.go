@@ -0,0 +1,416 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+	"app/oauth"
+	"app/utils"
+)
+
+// OAuthStateAge is how long a user has to get through the provider's consent
+// screen, before the login attempt expires.
+const OAuthStateAge = time.Minute * 10
+
+func (e *Env) oauthRedirectURI(provider string) string {
+	return "https://" + e.StaticHost + "/oauth/" + provider
+}
+
+// oauthState is where the client should send the user to log in.
+type oauthState struct {
+	Provider string `json:"provider"`
+	URL      string `json:"url"`
+}
+
+type postOAuthStateBody struct {
+	Provider string
+	Remember bool
+}
+
+func (e *Env) PostOAuthState(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	var data postOAuthStateBody
+	err := Decode(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	provider, err := e.OAuth.Provider(data.Provider)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	verifier, err := oauth.NewVerifier()
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	state := &models.OAuthState{
+		Provider: data.Provider,
+		Verifier: verifier,
+		Remember: data.Remember,
+	}
+
+	// Being logged in means that the identity is going to be linked to the
+	// current user, rather than used for logging in.
+	session, ok := c.Env["session"].(*models.Session)
+	if ok {
+		state.UserId = &session.UserId
+	}
+
+	err = e.M.DeleteOAuthStatesOlderThan(time.Now().Add(-OAuthStateAge))
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	err = e.M.CreateOAuthState(state)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	authURL, err := provider.AuthURL(
+		state.Token, verifier, e.oauthRedirectURI(data.Provider),
+	)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadGateway}
+	}
+
+	return Created(oauthState{data.Provider, authURL}, c, w)
+}
+
+// exchangeOAuthState consumes the state referenced by the callback and asks the
+// provider who the user is.
+func (e *Env) exchangeOAuthState(
+	providerName string, callback map[string]string,
+) (*models.OAuthState, *oauth.Identity, *Error) {
+	provider, err := e.OAuth.Provider(providerName)
+	if err != nil {
+		return nil, nil, &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	state, err := e.M.DeleteOAuthStateByToken(callback["state"])
+	if err == utils.ErrNotFound {
+		return nil, nil, &Error{E: ErrBadToken, C: http.StatusBadRequest}
+	} else if err != nil {
+		return nil, nil, &Error{E: err}
+	} else if state.Provider != providerName {
+		return nil, nil, &Error{E: ErrBadToken, C: http.StatusBadRequest}
+	} else if state.CreatedAt.Add(OAuthStateAge).Before(time.Now()) {
+		return nil, nil, &Error{
+			C: http.StatusBadRequest, M: "login attempt expired, please, try again",
+		}
+	}
+
+	identity, err := provider.Exchange(
+		callback, state.Verifier, e.oauthRedirectURI(providerName),
+	)
+	if err == oauth.ErrDenied || err == oauth.ErrBadCallback {
+		return nil, nil, &Error{E: err, C: http.StatusBadRequest}
+	} else if err != nil {
+		return nil, nil, &Error{E: err, C: http.StatusBadGateway}
+	}
+
+	return state, identity, nil
+}
+
+// identityUser finds the user behind an external identity, creating one if the
+// provider vouches for an email address nobody has claimed yet. Existing users
+// are never matched by email, they have to link the identity themselves, or
+// else whoever controls an unverified provider account could take theirs over.
+func (e *Env) identityUser(
+	providerName string, callback map[string]string,
+) (*models.User, bool, *Error) {
+	state, identity, apierr := e.exchangeOAuthState(providerName, callback)
+	if apierr != nil {
+		return nil, false, apierr
+	} else if state.UserId != nil {
+		return nil, false, &Error{
+			C: http.StatusBadRequest, M: "this attempt was meant for linking",
+		}
+	}
+
+	var user *models.User
+	err := e.M.Atomic(func(etx *models.Env) error {
+		existing, inerr := etx.GetIdentityByProviderSubject(
+			identity.Provider, identity.Subject,
+		)
+		if inerr == nil {
+			existing.Email = identity.Email
+			existing.Name = identity.Name
+			existing.Link = identity.Link
+			inerr = etx.UpdateIdentity(existing)
+			if inerr != nil {
+				return inerr
+			}
+
+			user, inerr = etx.GetUserById(existing.UserId)
+			return inerr
+		} else if inerr != utils.ErrNotFound {
+			return inerr
+		}
+
+		if identity.Email == "" || !identity.IsEmailVerified {
+			return &Error{
+				C: http.StatusBadRequest,
+				M: "this account isn't linked to any user yet; " +
+					"log in and link it from your profile",
+			}
+		}
+
+		_, inerr = etx.GetUserByEmail(identity.Email)
+		if inerr == nil {
+			return &Error{
+				C: http.StatusBadRequest,
+				M: "email taken; log in with your password and link this account " +
+					"from your profile",
+			}
+		} else if inerr != utils.ErrNotFound {
+			return inerr
+		}
+
+		// no password, the only way in is through the identity, or an OTP
+		user = &models.User{
+			Email:           identity.Email,
+			Password:        []byte{},
+			IsEmailVerified: true,
+		}
+		inerr = etx.CreateUser(user)
+		if inerr != nil {
+			return inerr
+		}
+
+		if identity.Name != "" {
+			user.Nickname = identity.Name
+			inerr = etx.UpdateUser(user, user.Id)
+			if inerr != nil {
+				return inerr
+			}
+		}
+
+		return linkIdentity(etx, user.Id, identity)
+	})
+	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return nil, false, apierr
+		}
+
+		return nil, false, &Error{E: err}
+	}
+
+	return user, state.Remember, nil
+}
+
+func linkIdentity(eM *models.Env, userId string, identity *oauth.Identity) error {
+	err := eM.CreateIdentity(&models.Identity{
+		UserId:   userId,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		Name:     identity.Name,
+		Link:     identity.Link,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Some providers prove game ownership as a side effect, Steam being the
+	// prime example. If there's a game verified this way, the user-game pair is
+	// created right away.
+	game, err := eM.GetGameByVerificationHandle(identity.Provider)
+	if err == utils.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	_, err = eM.GetUserGameByUserGame(userId, game.Id)
+	if err == nil {
+		return nil
+	} else if err != utils.ErrNotFound {
+		return err
+	}
+
+	userGame := &models.UserGame{
+		UserGamePublic: models.UserGamePublic{
+			UserId: userId,
+			GameId: game.Id,
+		},
+		Data: models.JSONMap{identity.Provider + "Id": identity.Subject},
+	}
+	err = eM.CreateUserGame(userGame)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if identity.Name != "" {
+		userGame.Name = &identity.Name
+	}
+	if identity.Link != "" {
+		userGame.Link = &identity.Link
+	}
+	userGame.VerifiedAt = &now
+	userGame.DataUpdatedAt = &now
+	return eM.UpdateUserGame(userGame)
+}
+
+type postIdentityBody struct {
+	Provider string
+	Callback map[string]string
+}
+
+func (e *Env) PostIdentity(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	var data postIdentityBody
+	err := Decode(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	state, identity, apierr := e.exchangeOAuthState(data.Provider, data.Callback)
+	if apierr != nil {
+		return apierr
+	} else if state.UserId == nil || *state.UserId != session.UserId {
+		return &Error{E: ErrBadToken, C: http.StatusBadRequest}
+	}
+
+	err = e.M.Atomic(func(etx *models.Env) error {
+		existing, inerr := etx.GetIdentityByProviderSubject(
+			identity.Provider, identity.Subject,
+		)
+		if inerr == nil {
+			if existing.UserId == session.UserId {
+				return &Error{C: http.StatusBadRequest, M: "already linked"}
+			}
+
+			return &Error{
+				C: http.StatusBadRequest,
+				M: "this account is linked to another user",
+			}
+		} else if inerr != utils.ErrNotFound {
+			return inerr
+		}
+
+		return linkIdentity(etx, session.UserId, identity)
+	})
+	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
+		return &Error{E: err}
+	}
+
+	created, err := e.M.GetIdentityByProviderSubject(
+		identity.Provider, identity.Subject,
+	)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return Created(created, c, w)
+}
+
+func (e *Env) GetIdentities(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	var data models.QueryBase
+	err = DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	userId, _ := data.Filter["user_id"]
+	if userId == "" {
+		return invalid(
+			"filter[user_id]", CodeRequired, "user_id filter is mandatory",
+		)
+	} else if userId != me.Id && !me.Is(models.RoleAdmin) {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	modifier, apierr := e.list(c, r, "identity", data,
+		[]string{"user_id", "provider"},
+		[]string{"id", "provider", "created_at", "last_used_at"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	identities, err := e.M.GetIdentities(modifier)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(identities, c, w)
+}
+
+func (e *Env) DeleteIdentity(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	identity, err := e.M.GetIdentityById(c.URLParams["id"])
+	if err != nil {
+		return &Error{E: err}
+	} else if identity.UserId != me.Id && !me.Is(models.RoleAdmin) {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	user, err := e.M.GetUserById(identity.UserId)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	if len(user.Password) == 0 {
+		identities, err := e.M.GetIdentities(models.NewQueryModifier(
+			models.QueryBase{Filter: map[string]string{"user_id": user.Id}},
+			[]string{"user_id"}, nil,
+		))
+		if err != nil {
+			return &Error{E: err}
+		} else if len(identities) < 2 {
+			return &Error{
+				C: http.StatusBadRequest,
+				M: "set a password first, this is the only way to log in",
+			}
+		}
+	}
+
+	err = e.M.DeleteIdentity(identity)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(identity, c, w)
+}
@@ -0,0 +1,425 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+)
+
+// relation is something that include= can pull in alongside a resource. It's
+// either to-one, with key being this side's JSON key holding the other side's
+// id, or to-many, with key being the other side's JSON key holding this
+// side's id, and column being the same thing in the database.
+type relation struct {
+	resource string
+	key      string
+	column   string
+}
+
+func (rel relation) isToMany() bool {
+	return rel.column != ""
+}
+
+type resource struct {
+	// load has to return a slice of public models, since included ones don't
+	// get the same scrutiny as the ones handlers return
+	load      func(eM *models.Env, m *models.QueryModifier) (interface{}, error)
+	relations map[string]relation
+}
+
+var resources = map[string]resource{
+	"match": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			matches, err := eM.GetMatches(m)
+			public := make([]models.MatchPublic, 0, len(matches))
+			for _, match := range matches {
+				public = append(public, match.MatchPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{
+			"bracket":      {"bracket", "bracketId", ""},
+			"teamX":        {"team", "teamX", ""},
+			"teamY":        {"team", "teamY", ""},
+			"parentMatchX": {"match", "parentMatchX", ""},
+			"parentMatchY": {"match", "parentMatchY", ""},
+			"matchReport":  {"match_report", "matchReportId", ""},
+			"matchReports": {"match_report", "matchId", "match_id"},
+			"matchMaps":    {"match_map", "matchId", "match_id"},
+		},
+	},
+	"match_map": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			matchMaps, err := eM.GetMatchMaps(m)
+			public := make([]models.MatchMapPublic, 0, len(matchMaps))
+			for _, matchMap := range matchMaps {
+				public = append(public, matchMap.MatchMapPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{
+			"match":   {"match", "matchId", ""},
+			"gameMap": {"game_map", "gameMapId", ""},
+			"team":    {"team", "teamId", ""},
+		},
+	},
+	"match_report": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			reports, err := eM.GetMatchReports(m)
+			public := make([]models.MatchReportPublic, 0, len(reports))
+			for _, report := range reports {
+				public = append(public, report.MatchReportPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{
+			"match":     {"match", "matchId", ""},
+			"rounds":    {"match_round", "matchReportId", "match_report_id"},
+			"penalties": {"match_penalty", "matchReportId", "match_report_id"},
+		},
+	},
+	"match_round": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			rounds, err := eM.GetMatchRounds(m)
+			public := make([]models.MatchRoundPublic, 0, len(rounds))
+			for _, round := range rounds {
+				public = append(public, round.MatchRoundPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{
+			"matchReport": {"match_report", "matchReportId", ""},
+			"gameMap":     {"game_map", "gameMapId", ""},
+		},
+	},
+	"match_penalty": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			penalties, err := eM.GetMatchPenalties(m)
+			public := make([]models.MatchPenaltyPublic, 0, len(penalties))
+			for _, penalty := range penalties {
+				public = append(public, penalty.MatchPenaltyPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{
+			"matchReport": {"match_report", "matchReportId", ""},
+			"matchRound":  {"match_round", "matchRoundId", ""},
+		},
+	},
+	"game_map": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			gameMaps, err := eM.GetGameMaps(m)
+			public := make([]models.GameMapPublic, 0, len(gameMaps))
+			for _, gameMap := range gameMaps {
+				public = append(public, gameMap.GameMapPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{},
+	},
+	"bracket": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			brackets, err := eM.GetBrackets(m)
+			public := make([]models.BracketPublic, 0, len(brackets))
+			for _, bracket := range brackets {
+				public = append(public, bracket.BracketPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{
+			"matches": {"match", "bracketId", "bracket_id"},
+		},
+	},
+	"team": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			teams, err := eM.GetTeams(m)
+			public := make([]models.TeamPublic, 0, len(teams))
+			for _, team := range teams {
+				public = append(public, team.TeamPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{
+			"userTeams": {"user_team", "teamId", "team_id"},
+		},
+	},
+	"user_team": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			userTeams, err := eM.GetUserTeams(m)
+			public := make([]models.UserTeamPublic, 0, len(userTeams))
+			for _, userTeam := range userTeams {
+				public = append(public, userTeam.UserTeamPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{
+			"user": {"user", "userId", ""},
+			"team": {"team", "teamId", ""},
+		},
+	},
+	"user": {
+		func(eM *models.Env, m *models.QueryModifier) (interface{}, error) {
+			users, err := eM.GetUsers(m)
+			public := make([]models.UserPublic, 0, len(users))
+			for _, user := range users {
+				public = append(public, user.UserPublic)
+			}
+
+			return public, err
+		},
+		map[string]relation{},
+	},
+}
+
+// resourceTypes tells which resource a handler is responding with, going by
+// the models it hands to Respond.
+var resourceTypes = map[reflect.Type]string{
+	reflect.TypeOf(models.Match{}):              "match",
+	reflect.TypeOf(models.MatchPublic{}):        "match",
+	reflect.TypeOf(models.MatchMap{}):           "match_map",
+	reflect.TypeOf(models.MatchMapPublic{}):     "match_map",
+	reflect.TypeOf(models.MatchReport{}):        "match_report",
+	reflect.TypeOf(models.MatchReportPublic{}):  "match_report",
+	reflect.TypeOf(models.MatchRound{}):         "match_round",
+	reflect.TypeOf(models.MatchRoundPublic{}):   "match_round",
+	reflect.TypeOf(models.MatchPenalty{}):       "match_penalty",
+	reflect.TypeOf(models.MatchPenaltyPublic{}): "match_penalty",
+	reflect.TypeOf(models.GameMap{}):            "game_map",
+	reflect.TypeOf(models.GameMapPublic{}):      "game_map",
+	reflect.TypeOf(models.Bracket{}):            "bracket",
+	reflect.TypeOf(models.BracketPublic{}):      "bracket",
+	reflect.TypeOf(models.Team{}):               "team",
+	reflect.TypeOf(models.TeamPublic{}):         "team",
+	reflect.TypeOf(models.UserTeam{}):           "user_team",
+	reflect.TypeOf(models.UserTeamPublic{}):     "user_team",
+	reflect.TypeOf(models.User{}):               "user",
+	reflect.TypeOf(models.UserPublic{}):         "user",
+}
+
+// include is a tree of relations, as in include=teamX,matchMaps.gameMap.
+type include map[string]include
+
+func parseInclude(value string) include {
+	tree := make(include)
+	for _, path := range strings.Split(value, ",") {
+		node := tree
+		for _, name := range strings.Split(path, ".") {
+			if name == "" {
+				break
+			}
+
+			if _, ok := node[name]; !ok {
+				node[name] = make(include)
+			}
+
+			node = node[name]
+		}
+	}
+
+	return tree
+}
+
+// shape is what's left of include= and fields= for Respond to apply.
+type shape struct {
+	eM      *models.Env
+	include include
+	fields  []string
+}
+
+// Shape is a middleware that picks up include= and fields= on GET requests,
+// which Respond then applies to any handler's response. Included relations
+// show up under "included" in each object, and fields only trims the
+// top-level ones.
+func (e *Env) Shape(c *web.C, w http.ResponseWriter, r *http.Request) *Error {
+	if r.Method != "GET" {
+		return nil
+	}
+
+	query := r.URL.Query()
+	s := &shape{eM: e.M, include: parseInclude(query.Get("include"))}
+	if fields := query.Get("fields"); fields != "" {
+		s.fields = strings.Split(fields, ",")
+	}
+
+	if len(s.include) > 0 || len(s.fields) > 0 {
+		c.Env["shape"] = s
+	}
+
+	return nil
+}
+
+// apply turns data into plain JSON values, with relations included and
+// fields trimmed.
+func (s *shape) apply(data interface{}) (interface{}, *Error) {
+	t := reflect.TypeOf(data)
+	isList := t.Kind() == reflect.Slice
+	if isList {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var objects []map[string]interface{}
+	if isList {
+		err := remarshal(data, &objects)
+		if err != nil {
+			return nil, &Error{E: err}
+		}
+	} else {
+		var object map[string]interface{}
+		err := remarshal(data, &object)
+		if err != nil {
+			return nil, &Error{E: err}
+		}
+
+		objects = []map[string]interface{}{object}
+	}
+
+	if len(s.include) > 0 {
+		name, ok := resourceTypes[t]
+		if !ok {
+			return nil, invalid("include", CodeEnum, "nothing to include here")
+		}
+
+		apierr := s.includeInto(objects, name, s.include)
+		if apierr != nil {
+			return nil, apierr
+		}
+	}
+
+	if len(s.fields) > 0 {
+		for i, object := range objects {
+			trimmed := make(map[string]interface{})
+			for _, field := range append(s.fields, "included") {
+				if value, ok := object[field]; ok {
+					trimmed[field] = value
+				}
+			}
+
+			objects[i] = trimmed
+		}
+	}
+
+	if isList {
+		return objects, nil
+	}
+
+	return objects[0], nil
+}
+
+// includeInto loads the relations in tree for objects of the named resource,
+// one query per relation however many objects there are, and recurses into
+// what it loaded.
+func (s *shape) includeInto(
+	objects []map[string]interface{}, name string, tree include,
+) *Error {
+	names := make([]string, 0, len(tree))
+	for relName := range tree {
+		names = append(names, relName)
+	}
+
+	sort.Strings(names)
+	for _, relName := range names {
+		rel, ok := resources[name].relations[relName]
+		if !ok {
+			return invalid("include", CodeEnum, "can't include "+relName+" in "+name)
+		}
+
+		ownKey, column, otherKey := rel.key, "id", "id"
+		if rel.isToMany() {
+			ownKey, column, otherKey = "id", rel.column, rel.key
+		}
+
+		ids := make([]string, 0, len(objects))
+		seen := make(map[string]bool)
+		for _, object := range objects {
+			id, ok := object[ownKey].(string)
+			if ok && !seen[id] {
+				ids = append(ids, id)
+				seen[id] = true
+			}
+		}
+
+		var related []map[string]interface{}
+		if len(ids) > 0 {
+			modifier := &models.QueryModifier{
+				Filter: map[string]interface{}{column: ids},
+				Sort:   []string{"id ASC"},
+			}
+
+			rows, err := resources[rel.resource].load(s.eM, modifier)
+			if err != nil {
+				return &Error{E: err}
+			}
+
+			err = remarshal(rows, &related)
+			if err != nil {
+				return &Error{E: err}
+			}
+
+			apierr := s.includeInto(related, rel.resource, tree[relName])
+			if apierr != nil {
+				return apierr
+			}
+		}
+
+		byKey := make(map[string][]map[string]interface{})
+		for _, row := range related {
+			if key, ok := row[otherKey].(string); ok {
+				byKey[key] = append(byKey[key], row)
+			}
+		}
+
+		for _, object := range objects {
+			included, ok := object["included"].(map[string]interface{})
+			if !ok {
+				included = make(map[string]interface{})
+				object["included"] = included
+			}
+
+			key, _ := object[ownKey].(string)
+			if rel.isToMany() {
+				rows := byKey[key]
+				if rows == nil {
+					rows = make([]map[string]interface{}, 0)
+				}
+
+				included[relName] = rows
+			} else if rows := byKey[key]; len(rows) > 0 {
+				included[relName] = rows[0]
+			} else {
+				included[relName] = nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// remarshal converts between types by way of JSON, which is how the client
+// sees them anyway.
+func remarshal(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, to)
+}
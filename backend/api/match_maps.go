@@ -18,11 +18,15 @@ func (e *Env) GetMatchMap(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+
+		can, apierr := e.can(me, models.RoleReferee, "match", matchMap.MatchId)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(matchMap, c, w)
 		}
 	}
@@ -34,32 +38,31 @@ func (e *Env) GetMatchMaps(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
 
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	matchMaps, err := e.M.GetMatchMaps(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "match_map", data,
 		[]string{"match_id", "game_map_id", "team_id", "is_ban", "discarded_at"},
 		[]string{"id", "created_at"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	matchMaps, err := e.M.GetMatchMaps(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleReferee) {
 			return OK(matchMaps, c, w)
 		}
 	}
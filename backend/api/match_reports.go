@@ -1,7 +1,7 @@
 package api
 
 import (
-	// "fmt"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -36,6 +36,20 @@ type roundData struct {
 	RawScoreYOverride *float64 `json:",string"`
 }
 
+type postMatchReportBody struct {
+	MatchId string
+
+	Rounds    []roundData
+	Penalties []penaltyData
+
+	OverrideReason    string
+	IsPenalOverride   bool
+	ScoreXOverride    *float64 `json:",string"`
+	ScoreYOverride    *float64 `json:",string"`
+	RawScoreXOverride *float64 `json:",string"`
+	RawScoreYOverride *float64 `json:",string"`
+}
+
 func (e *Env) PostMatchReport(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -44,24 +58,12 @@ func (e *Env) PostMatchReport(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
 
-	var data struct {
-		MatchId string
-
-		Rounds    []roundData
-		Penalties []penaltyData
-
-		OverrideReason    string
-		IsPenalOverride   bool
-		ScoreXOverride    *float64 `json:",string"`
-		ScoreYOverride    *float64 `json:",string"`
-		RawScoreXOverride *float64 `json:",string"`
-		RawScoreYOverride *float64 `json:",string"`
-	}
+	var data postMatchReportBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -69,16 +71,21 @@ func (e *Env) PostMatchReport(
 
 	match, err := e.M.GetMatchById(data.MatchId)
 	if err != nil {
-		return &Error{E: err, C: http.StatusBadRequest, M: "bad match id"}
+		return invalid("matchId", CodeId, "bad match id")
 	} else if match.TeamX == nil || match.TeamY == nil {
 		return &Error{C: http.StatusBadRequest, M: "match not seeded yet"}
 	} else if !match.AreMapsReady {
 		return &Error{C: http.StatusBadRequest, M: "match maps aren't ready"}
 	}
 
+	isReferee, apierr := e.can(me, models.RoleReferee, "match", match.Id)
+	if apierr != nil {
+		return apierr
+	}
+
 	now := time.Now()
 	var teamAmLeaderOf *string
-	if !me.IsAdmin {
+	if !isReferee {
 		teamsAmLeaderOf, err, status := match.UserIsLeaderOf(e.M, me.Id)
 		if err != nil {
 			return &Error{E: err, C: status}
@@ -110,11 +117,11 @@ func (e *Env) PostMatchReport(
 	}
 
 	matchMaps, err := e.M.GetMatchMaps(models.NewQueryModifier(
-		models.QueryBase{0, 0, map[string]string{
+		models.QueryBase{Filter: map[string]string{
 			"match_id":     match.Id,
 			"is_ban":       "false",
 			"discarded_at": "\x00",
-		}, ""},
+		}},
 		[]string{"match_id", "is_ban", "discarded_at"},
 		[]string{},
 	))
@@ -136,7 +143,7 @@ func (e *Env) PostMatchReport(
 	roundsPlayed := len(data.Rounds)
 	swapSidesEveryRounds := 1
 	if roundsPlayed != mapsPlayed*roundsPerMap {
-		return &Error{C: http.StatusBadRequest, M: "invalid amount of rounds"}
+		return invalid("rounds", CodeLength, "invalid amount of rounds")
 	}
 
 	// TODO: Calculate total PLAYED rounds too, and let admins penalize based on
@@ -145,7 +152,7 @@ func (e *Env) PostMatchReport(
 
 	var isOverridden bool
 	var roundRawScoreXOverride, roundRawScoreYOverride float64
-	if !me.IsAdmin {
+	if !isReferee {
 		data.OverrideReason = ""
 		data.IsPenalOverride = false
 		data.ScoreXOverride = nil
@@ -159,9 +166,9 @@ func (e *Env) PostMatchReport(
 			data.IsPenalOverride = false
 		} else {
 			if data.OverrideReason == "" {
-				return &Error{
-					C: http.StatusBadRequest, M: "score override reason missing",
-				}
+				return invalid(
+					"overrideReason", CodeRequired, "score override reason missing",
+				)
 			}
 
 			if data.RawScoreXOverride != nil {
@@ -200,7 +207,7 @@ func (e *Env) PostMatchReport(
 		CreatedBy: me.Id,
 	}
 
-	if !me.IsAdmin {
+	if !isReferee {
 		data.Penalties = nil
 	}
 
@@ -238,30 +245,28 @@ func (e *Env) PostMatchReport(
 			i+1, j+1, (k+1)%swapSidesEveryRounds {
 			round := data.Rounds[i]
 			if round.GameMapId != matchMap.GameMapId {
-				return &Error{
-					C: http.StatusBadRequest,
-					M: "on round " + strconv.Itoa(i) +
-						" expected map " + matchMap.GameMapId +
-						", but got " + round.GameMapId,
-				}
+				return invalid(
+					fmt.Sprintf("rounds[%d].gameMapId", i), CodeEnum,
+					"on round "+strconv.Itoa(i)+
+						" expected map "+matchMap.GameMapId+
+						", but got "+round.GameMapId,
+				)
 			}
 
 			if k == 0 {
 				if j > 0 && prevSide == round.IsTeamXOnSideY {
-					return &Error{
-						C: http.StatusBadRequest,
-						M: "on round " + strconv.Itoa(i) +
-							" sides not swapped when expected",
-					}
+					return invalid(
+						fmt.Sprintf("rounds[%d].isTeamXOnSideY", i), CodeOrder,
+						"on round "+strconv.Itoa(i)+" sides not swapped when expected",
+					)
 				}
 
 				prevSide = round.IsTeamXOnSideY
 			} else if prevSide != round.IsTeamXOnSideY {
-				return &Error{
-					C: http.StatusBadRequest,
-					M: "on round " + strconv.Itoa(i) +
-						" sides swapped when not expected",
-				}
+				return invalid(
+					fmt.Sprintf("rounds[%d].isTeamXOnSideY", i), CodeOrder,
+					"on round "+strconv.Itoa(i)+" sides swapped when not expected",
+				)
 			}
 
 			if round.IsNotPlayed {
@@ -269,7 +274,7 @@ func (e *Env) PostMatchReport(
 				round.RawScoreY = 0
 			}
 
-			if !me.IsAdmin {
+			if !isReferee {
 				round.OverrideReason = ""
 				round.IsPenalOverride = false
 				round.RawScoreXOverride = nil
@@ -279,11 +284,10 @@ func (e *Env) PostMatchReport(
 					round.OverrideReason = ""
 					round.IsPenalOverride = false
 				} else if round.OverrideReason == "" {
-					return &Error{
-						C: http.StatusBadRequest,
-						M: "on round " + strconv.Itoa(i) +
-							" score override reason missing",
-					}
+					return invalid(
+						fmt.Sprintf("rounds[%d].overrideReason", i), CodeRequired,
+						"on round "+strconv.Itoa(i)+" score override reason missing",
+					)
 				} else {
 					isOverridden = true
 				}
@@ -313,7 +317,7 @@ func (e *Env) PostMatchReport(
 				CreatedBy: me.Id,
 			})
 
-			if !me.IsAdmin {
+			if !isReferee {
 				round.Penalties = nil
 			}
 
@@ -415,7 +419,7 @@ func (e *Env) PostMatchReport(
 			}
 		}
 
-		if me.IsAdmin {
+		if isReferee {
 			inerr = publishMatchReport(
 				etx, me.Id, bracket, match, report, isOverridden, len(penalties) > 0,
 			)
@@ -448,10 +452,15 @@ func (e *Env) GetMatchReport(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		}
+
+		can, apierr := e.can(me, models.RoleReferee, "match", report.MatchId)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(report, c, w)
 		}
 
@@ -474,12 +483,7 @@ func (e *Env) GetMatchReport(
 func (e *Env) GetMatchReports(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -487,30 +491,41 @@ func (e *Env) GetMatchReports(
 
 	matchId, _ := data.Filter["match_id"]
 	if matchId == "" {
-		return &Error{C: http.StatusBadRequest, M: "match_id filter is mandatory"}
+		return invalid(
+			"filter[match_id]", CodeRequired, "match_id filter is mandatory",
+		)
 	}
 
-	reports, err := e.M.GetMatchReports(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "match_report", data,
 		[]string{"match_id"},
-		[]string{"id", "match_id", "created_at"},
-	))
+		[]string{"id", "match_id"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	reports, err := e.M.GetMatchReports(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		}
+
+		can, apierr := e.can(me, models.RoleReferee, "match", matchId)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(reports, c, w)
 		}
 
 		match, err := e.M.GetMatchById(matchId)
 		if err == utils.ErrNotFound {
-			return &Error{C: http.StatusBadRequest, M: "bad match id"}
+			return invalid("filter[match_id]", CodeId, "bad match id")
 		} else if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
@@ -531,6 +546,10 @@ func (e *Env) GetMatchReports(
 	return OK(public, c, w)
 }
 
+type patchMatchReportBody struct {
+	Action string
+}
+
 func (e *Env) PatchMatchReport(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -539,7 +558,7 @@ func (e *Env) PatchMatchReport(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
@@ -554,8 +573,13 @@ func (e *Env) PatchMatchReport(
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
 
+	isReferee, apierr := e.can(me, models.RoleReferee, "match", match.Id)
+	if apierr != nil {
+		return apierr
+	}
+
 	var teamAmLeaderOf *string
-	if !me.IsAdmin {
+	if !isReferee {
 		teamsAmLeaderOf, err, status := match.UserIsLeaderOf(e.M, me.Id)
 		if err != nil {
 			return &Error{E: err, C: status}
@@ -574,9 +598,9 @@ func (e *Env) PatchMatchReport(
 	}
 
 	reports, err := e.M.GetMatchReports(models.NewQueryModifier(
-		models.QueryBase{0, 0, map[string]string{
+		models.QueryBase{Filter: map[string]string{
 			"match_id": report.MatchId,
-		}, "created_at"},
+		}, Sort: "created_at"},
 		[]string{"match_id"},
 		[]string{"created_at"},
 	))
@@ -589,14 +613,12 @@ func (e *Env) PatchMatchReport(
 		}
 	}
 
-	var data struct {
-		Action string
-	}
+	var data patchMatchReportBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	} else if data.Action != "agree" {
-		return &Error{C: http.StatusBadRequest, M: "bad action"}
+		return invalid("action", CodeEnum, "bad action")
 	}
 
 	if report.AgreedUponAt != nil {
@@ -621,8 +643,8 @@ func (e *Env) PatchMatchReport(
 	now := time.Now()
 	report.AgreedUponAt = &now
 	report.AgreedUponBy = &me.Id
-	err = e.M.Atomic(func(etx *models.Env) error {
-		inerr := etx.UpdateMatchReport(report)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		inerr := etx.UpdateMatchReport(report, me.Id)
 		if inerr != nil {
 			return inerr
 		}
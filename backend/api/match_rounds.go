@@ -19,11 +19,9 @@ func (e *Env) GetMatchRound(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
-			return OK(round, c, w)
 		}
 
 		report, err := e.M.GetMatchReportById(round.MatchReportId)
@@ -36,6 +34,13 @@ func (e *Env) GetMatchRound(
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
 
+		can, apierr := e.can(me, models.RoleReferee, "match", match.Id)
+		if apierr != nil {
+			return apierr
+		} else if can {
+			return OK(round, c, w)
+		}
+
 		teamAmLeaderOf, err, status := match.UserIsLeaderOf(e.M, me.Id)
 		if err != nil {
 			return &Error{E: err, C: status}
@@ -50,12 +55,7 @@ func (e *Env) GetMatchRound(
 func (e *Env) GetMatchRounds(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -63,32 +63,37 @@ func (e *Env) GetMatchRounds(
 
 	reportId, _ := data.Filter["match_report_id"]
 	if reportId == "" {
-		return &Error{
-			C: http.StatusBadRequest, M: "match_report_id filter is mandatory",
-		}
+		return invalid(
+			"filter[match_report_id]", CodeRequired,
+			"match_report_id filter is mandatory",
+		)
 	}
 
-	rounds, err := e.M.GetMatchRounds(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "match_round", data,
 		[]string{"match_report_id"},
-		[]string{"id", "match_id", "created_at"},
-	))
+		[]string{"id", "match_report_id"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	rounds, err := e.M.GetMatchRounds(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
-			return OK(rounds, c, w)
 		}
 
 		report, err := e.M.GetMatchReportById(reportId)
 		if err == utils.ErrNotFound {
-			return &Error{C: http.StatusBadRequest, M: "bad report id"}
+			return invalid(
+				"filter[match_report_id]", CodeId, "bad report id",
+			)
 		} else if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
@@ -98,6 +103,13 @@ func (e *Env) GetMatchRounds(
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
 
+		can, apierr := e.can(me, models.RoleReferee, "match", match.Id)
+		if apierr != nil {
+			return apierr
+		} else if can {
+			return OK(rounds, c, w)
+		}
+
 		teamAmLeaderOf, err, status := match.UserIsLeaderOf(e.M, me.Id)
 		if err != nil {
 			return &Error{E: err, C: status}
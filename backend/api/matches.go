@@ -21,11 +21,15 @@ func (e *Env) GetMatch(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+
+		can, apierr := e.can(me, models.RoleReferee, "match", match.Id)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(match, c, w)
 		}
 	}
@@ -37,34 +41,33 @@ func (e *Env) GetMatches(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
 
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	matches, err := e.M.GetMatches(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "match", data,
 		[]string{"bracket_id", "team_x", "team_y"},
-		[]string{"id", "bracket_id", "starts_at", "team_x", "team_y", "raw_score_x",
-			"raw_score_y", "comp_score_x", "comp_score_y"},
-	))
+		[]string{"id", "bracket_id", "started_at", "team_x", "team_y",
+			"raw_score_x", "raw_score_y", "score_x", "score_y"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	matches, err := e.M.GetMatches(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleReferee) {
 			return OK(matches, c, w)
 		}
 	}
@@ -77,6 +80,11 @@ func (e *Env) GetMatches(
 	return OK(public, c, w)
 }
 
+type putMatchBody struct {
+	StartedAt         *time.Time
+	ReportingClosedAt *time.Time
+}
+
 func (e *Env) PutMatch(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -85,11 +93,9 @@ func (e *Env) PutMatch(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
 	match, err := e.M.GetMatchById(c.URLParams["id"])
@@ -97,10 +103,12 @@ func (e *Env) PutMatch(
 		return &Error{E: err}
 	}
 
-	var data struct {
-		StartedAt         *time.Time
-		ReportingClosedAt *time.Time
+	apierr := e.authorize(me, models.RoleReferee, "match", match.Id)
+	if apierr != nil {
+		return apierr
 	}
+
+	var data putMatchBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -125,14 +133,27 @@ func (e *Env) PutMatch(
 		return OK(match, c, w)
 	}
 
-	err = e.M.UpdateMatch(match, me.Id)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		return etx.UpdateMatch(match, me.Id)
+	})
 	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
 		return &Error{E: err}
 	}
 
 	return OK(match, c, w)
 }
 
+// matchLeadership lists the teams of a match that the user leads.
+type matchLeadership struct {
+	Id         string   `json:"id"`
+	Leadership []string `json:"leadership"`
+}
+
 func (e *Env) GetMatchLeadership(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -141,10 +162,7 @@ func (e *Env) GetMatchLeadership(
 		return &Error{E: err}
 	}
 
-	leadership := struct {
-		Id         string   `json:"id"`
-		Leadership []string `json:"leadership"`
-	}{match.Id, make([]string, 0)}
+	leadership := matchLeadership{match.Id, make([]string, 0)}
 	session, ok := c.Env["session"].(*models.Session)
 	if !ok {
 		return OK(leadership, c, w)
@@ -164,6 +182,12 @@ func (e *Env) GetMatchLeadership(
 	return OK(leadership, c, w)
 }
 
+type patchMatchBody struct {
+	Action string
+	Maps   []string
+	Map    string
+}
+
 func (e *Env) PatchMatch(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -172,23 +196,19 @@ func (e *Env) PatchMatch(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
 
-	var data struct {
-		Action string
-		Maps   []string
-		Map    string
-	}
+	var data patchMatchBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	} else if data.Action != "prepare" &&
 		data.Action != "map-pick" &&
 		data.Action != "reset-maps" {
-		return &Error{C: http.StatusBadRequest, M: "bad action"}
+		return invalid("action", CodeEnum, "bad action")
 	}
 
 	match, err := e.M.GetMatchById(c.URLParams["id"])
@@ -197,7 +217,10 @@ func (e *Env) PatchMatch(
 	} // AreMapsReady isn't checked yet, len(matchMaps) is sufficient
 
 	matchReports, err := e.M.GetMatchReports(models.NewQueryModifier(
-		models.QueryBase{0, 0, map[string]string{"match_id": match.Id}, "id"},
+		models.QueryBase{
+			Filter: map[string]string{"match_id": match.Id},
+			Sort:   "id",
+		},
 		[]string{"match_id"}, []string{"id"},
 	))
 	if err != nil {
@@ -209,16 +232,21 @@ func (e *Env) PatchMatch(
 	}
 
 	matchMaps, err := e.M.GetMatchMaps(models.NewQueryModifier(
-		models.QueryBase{0, 0, map[string]string{
+		models.QueryBase{Filter: map[string]string{
 			"match_id":     match.Id,
 			"discarded_at": "\x00",
-		}, "id"},
+		}, Sort: "id"},
 		[]string{"match_id", "discarded_at"}, []string{"id"},
 	))
 	if err != nil {
 		return &Error{E: err}
 	}
 
+	isReferee, apierr := e.can(me, models.RoleReferee, "match", match.Id)
+	if apierr != nil {
+		return apierr
+	}
+
 	if data.Action == "map-pick" {
 		if match.AreMapsReady {
 			return &Error{
@@ -243,7 +271,7 @@ func (e *Env) PatchMatch(
 			myTeam = x
 		}
 
-		err = e.M.Atomic(func(etx *models.Env) error {
+		err = e.conditionally(c, func(etx *models.Env) error {
 			return mapPick(etx, me, match, matchMaps, myTeam, data.Map)
 		})
 		if err == nil {
@@ -253,10 +281,10 @@ func (e *Env) PatchMatch(
 		}
 
 		return &Error{E: err}
-	} else if !me.IsAdmin {
+	} else if !isReferee {
 		return &Error{E: utils.ErrUnauthorized}
 	} else if data.Action == "reset-maps" {
-		err = e.M.Atomic(func(etx *models.Env) error {
+		err = e.conditionally(c, func(etx *models.Env) error {
 			now := time.Now()
 			for _, mm := range matchMaps {
 				mm.DiscardedAt = &now
@@ -275,6 +303,11 @@ func (e *Env) PatchMatch(
 			return nil
 		})
 		if err != nil {
+			apierr, ok := err.(*Error)
+			if ok {
+				return apierr
+			}
+
 			return &Error{E: err}
 		}
 
@@ -286,7 +319,7 @@ func (e *Env) PatchMatch(
 	}
 
 	mapCache := make(map[string]*models.GameMap)
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		match.AreMapsReady = true
 		inerr := etx.UpdateMatch(match, me.Id)
 		if inerr != nil {
@@ -365,7 +398,7 @@ func mapPick(
 	}
 
 	bracketMaps, err := eM.GetBracketMaps(models.NewQueryModifier(
-		models.QueryBase{0, 0, map[string]string{"bracket_id": bracket.Id}, ""},
+		models.QueryBase{Filter: map[string]string{"bracket_id": bracket.Id}},
 		[]string{"bracket_id"}, nil,
 	))
 	if err != nil {
@@ -417,7 +450,7 @@ bmLoopUser:
 			}
 		}
 
-		return &Error{C: http.StatusBadRequest, M: "bad pick"}
+		return invalid("map", CodeEnum, "bad pick")
 	}
 
 	picked := []mapPlan{{mapId, &myTeam, action.Ban}}
@@ -12,6 +12,10 @@ type middlewareFunc func(
 	r *http.Request,
 ) *Error
 
+// errResponded is for middlewares that respond by themselves, cutting the
+// chain short without an error.
+var errResponded = &Error{}
+
 type middlewareEnv struct {
 	e  *Env
 	mw middlewareFunc
@@ -20,7 +24,9 @@ type middlewareEnv struct {
 func (e *middlewareEnv) handle(c *web.C, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		err := e.mw(c, w, r)
-		if err != nil {
+		if err == errResponded {
+			return
+		} else if err != nil {
 			err.Handle(e.e, *c, w, r)
 			return
 		}
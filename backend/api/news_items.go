@@ -10,6 +10,17 @@ import (
 	"app/utils"
 )
 
+type postNewsItemBody struct {
+	Target      string
+	TargetId    string
+	Title       string
+	Picture     string
+	Preview     string
+	Video       string
+	Body        string
+	PublishedAt *time.Time
+}
+
 func (e *Env) PostNewsItem(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -18,23 +29,12 @@ func (e *Env) PostNewsItem(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Target      string
-		TargetId    string
-		Title       string
-		Picture     string
-		Preview     string
-		Video       string
-		Body        string
-		PublishedAt *time.Time
-	}
+	var data postNewsItemBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -43,20 +43,25 @@ func (e *Env) PostNewsItem(
 	} else if data.Target == "game" {
 		_, err = e.M.GetGameById(data.TargetId)
 		if err != nil {
-			return &Error{E: err, C: http.StatusBadRequest, M: "bad target id"}
+			return invalid("targetId", CodeId, "bad target id")
 		}
 	} else if data.Target == "tournament" {
 		_, err = e.M.GetTournamentById(data.TargetId)
 		if err != nil {
-			return &Error{E: err, C: http.StatusBadRequest, M: "bad target id"}
+			return invalid("targetId", CodeId, "bad target id")
 		}
 	} else if data.Target == "season" {
 		_, err = e.M.GetSeasonById(data.TargetId)
 		if err != nil {
-			return &Error{E: err, C: http.StatusBadRequest, M: "bad target id"}
+			return invalid("targetId", CodeId, "bad target id")
 		}
 	} else {
-		return &Error{C: http.StatusBadRequest, M: "bad target"}
+		return invalid("target", CodeEnum, "bad target")
+	}
+
+	apierr := e.authorize(me, models.RoleNewsEditor, data.Target, data.TargetId)
+	if apierr != nil {
+		return apierr
 	}
 
 	newsItem := &models.NewsItem{
@@ -88,10 +93,15 @@ func (e *Env) GetNewsItem(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		}
+
+		can, apierr := e.can(me, models.RoleNewsEditor, "news", newsItem.Id)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(newsItem, c, w)
 		}
 	}
@@ -114,20 +124,25 @@ func (e *Env) GetNewsItems(
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	newsItems, err := e.M.GetNewsItems(models.NewQueryModifier(data,
+	modifier, apierr := e.list(c, r, "news_item", data,
 		[]string{"target", "target_id", "is_deleted", "created_by"},
 		[]string{}, // TODO
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	newsItems, err := e.M.GetNewsItems(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleNewsEditor) {
 			return OK(newsItems, c, w)
 		}
 	}
@@ -146,6 +161,14 @@ func (e *Env) GetNewsItems(
 	return OK(public, c, w)
 }
 
+type putNewsItemBody struct {
+	Title   *string
+	Picture *string
+	Preview *string
+	Video   *string
+	Body    *string
+}
+
 func (e *Env) PutNewsItem(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -154,27 +177,24 @@ func (e *Env) PutNewsItem(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Title   *string
-		Picture *string
-		Preview *string
-		Video   *string
-		Body    *string
+	apierr := e.authorize(me, models.RoleNewsEditor, "news", c.URLParams["id"])
+	if apierr != nil {
+		return apierr
 	}
+
+	var data putNewsItemBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
 	var newsItem *models.NewsItem
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		var inerr error
 		newsItem, inerr = etx.GetNewsItemById(c.URLParams["id"])
 		if inerr != nil {
@@ -238,6 +258,11 @@ func (e *Env) PutNewsItem(
 	return OK(newsItem, c, w)
 }
 
+type patchNewsItemBody struct {
+	Action      string
+	PublishedAt *time.Time
+}
+
 func (e *Env) PatchNewsItem(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -246,24 +271,24 @@ func (e *Env) PatchNewsItem(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Action      string
-		PublishedAt *time.Time
+	apierr := e.authorize(me, models.RoleNewsEditor, "news", c.URLParams["id"])
+	if apierr != nil {
+		return apierr
 	}
+
+	var data patchNewsItemBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
 	var newsItem *models.NewsItem
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		var inerr error
 		newsItem, inerr = etx.GetNewsItemById(c.URLParams["id"])
 		if inerr != nil {
@@ -287,7 +312,7 @@ func (e *Env) PatchNewsItem(
 
 			newsItem.IsDeleted = false
 		} else {
-			return &Error{C: http.StatusBadRequest, M: "bad action"}
+			return invalid("action", CodeEnum, "bad action")
 		}
 
 		return etx.UpdateNewsItem(newsItem, me.Id)
@@ -0,0 +1,775 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+)
+
+// operation describes a route for the OpenAPI document. query and body are
+// zero values of whatever the handler decodes, and response of whatever it
+// responds with, the non-public variant where there are two. Leave response
+// out for 204s.
+type operation struct {
+	summary  string
+	query    interface{}
+	body     interface{}
+	response interface{}
+	status   int // 200, or 201 for POST, if left out
+}
+
+// spec has to cover every route in addRoutes, which routes_test.go checks.
+var spec = map[string]operation{
+	"GET /openapi.json": {summary: "This document"},
+
+	"POST /sessions": {
+		summary: "Log in", body: postSessionBody{}, response: models.Session{},
+	},
+	"DELETE /sessions/:token": {
+		summary: "Log out", response: models.Session{}, status: http.StatusOK,
+	},
+
+	"POST /otps": {summary: "Mail a one-time password", body: postOTPBody{}},
+
+	"POST /oauth_states": {
+		summary:  "Start logging in with an OAuth provider",
+		body:     postOAuthStateBody{},
+		response: oauthState{},
+	},
+	"POST /identities": {
+		summary:  "Link an OAuth identity",
+		body:     postIdentityBody{},
+		response: models.Identity{},
+	},
+	"GET /identities": {
+		summary:  "List linked identities",
+		query:    models.QueryBase{},
+		response: []models.Identity{},
+	},
+	"DELETE /identities/:id": {
+		summary:  "Unlink an identity",
+		response: models.Identity{},
+		status:   http.StatusOK,
+	},
+
+	"POST /api_tokens": {
+		summary:  "Create an API token, the only time its secret is shown",
+		body:     postApiTokenBody{},
+		response: models.ApiToken{},
+	},
+	"GET /api_tokens": {
+		summary:  "List API tokens",
+		query:    models.QueryBase{},
+		response: []models.ApiToken{},
+	},
+	"DELETE /api_tokens/:id": {
+		summary:  "Revoke an API token",
+		response: models.ApiToken{},
+		status:   http.StatusOK,
+	},
+
+	"POST /roles": {
+		summary: "Grant a role", body: postRoleBody{}, response: models.Role{},
+	},
+	"GET /roles": {
+		summary:  "List roles",
+		query:    models.QueryBase{},
+		response: []models.Role{},
+	},
+	"DELETE /roles/:id": {
+		summary:  "Revoke a role",
+		response: models.Role{},
+		status:   http.StatusOK,
+	},
+
+	"GET /audits": {
+		summary:  "List column changes",
+		query:    getAuditsQuery{},
+		response: []models.Audit{},
+	},
+	"GET /revisions": {
+		summary:  "List the revisions of a markdown column",
+		query:    getRevisionsQuery{},
+		response: []models.Revision{},
+	},
+
+	"GET /search": {
+		summary:  "Search users, teams, tournaments, seasons and news",
+		query:    getSearchQuery{},
+		response: []models.SearchResult{},
+	},
+
+	"POST /users": {
+		summary: "Sign up", body: postUserBody{}, response: models.User{},
+	},
+	"GET /users/:id": {summary: "Get a user", response: models.User{}},
+	"GET /users": {
+		summary:  "List users",
+		query:    models.QueryBase{},
+		response: []models.User{},
+	},
+	"PUT /users/:id": {
+		summary: "Update a user", body: putUserBody{}, response: models.User{},
+	},
+	"DELETE /users/:id": {
+		summary:  "Delete and anonymise a user",
+		response: models.User{},
+		status:   http.StatusOK,
+	},
+	"POST /users/:id/verification": {
+		summary: "Resend the email verification",
+		status:  http.StatusNoContent,
+	},
+	"GET /users/:id/export": {
+		summary:  "Export everything about a user",
+		response: userExport{},
+	},
+	"GET /users/:id/related_accounts": {
+		summary:  "List users who have game data in common with a user",
+		response: []models.RelatedAccount{},
+	},
+
+	"POST /teams": {
+		summary: "Create a team", body: postTeamBody{}, response: models.Team{},
+	},
+	"GET /teams/:id": {summary: "Get a team", response: models.Team{}},
+	"GET /teams": {
+		summary:  "List teams",
+		query:    models.QueryBase{},
+		response: []models.Team{},
+	},
+	"PUT /teams/:id": {
+		summary: "Update a team", body: putTeamBody{}, response: models.Team{},
+	},
+	"PATCH /teams/:id": {
+		summary:  "Disband a team",
+		body:     patchTeamBody{},
+		response: models.Team{},
+	},
+
+	"POST /user_team_requests": {
+		summary:  "Ask to join a team, or invite somebody to one",
+		body:     postUserTeamRequestBody{},
+		response: models.UserTeamRequest{},
+	},
+	"GET /user_team_requests/:id": {
+		summary:  "Get a team membership request",
+		response: models.UserTeamRequest{},
+	},
+	"GET /user_team_requests": {
+		summary:  "List team membership requests",
+		query:    models.QueryBase{},
+		response: []models.UserTeamRequest{},
+	},
+	"PATCH /user_team_requests/:id": {
+		summary:  "Decide on a team membership request",
+		body:     patchUserTeamRequestBody{},
+		response: models.UserTeamRequest{},
+	},
+
+	"GET /user_teams": {
+		summary:  "List team memberships",
+		query:    models.QueryBase{},
+		response: []models.UserTeam{},
+	},
+	"GET /user_teams/:id": {
+		summary: "Get a team membership", response: models.UserTeam{},
+	},
+	"PATCH /user_teams/:id": {
+		summary:  "Leave a team, or kick, promote or demote a member",
+		body:     patchUserTeamBody{},
+		response: models.UserTeam{},
+	},
+
+	"POST /games": {
+		summary: "Create a game", body: postGameBody{}, response: models.Game{},
+	},
+	"GET /games/:id": {summary: "Get a game", response: models.Game{}},
+	"GET /games": {
+		summary:  "List games",
+		query:    models.QueryBase{},
+		response: []models.Game{},
+	},
+	"PUT /games/:id": {
+		summary: "Update a game", body: putGameBody{}, response: models.Game{},
+	},
+
+	"POST /game_maps": {
+		summary:  "Create a map",
+		body:     postGameMapBody{},
+		response: models.GameMap{},
+	},
+	"GET /game_maps/:id": {summary: "Get a map", response: models.GameMap{}},
+	"GET /game_maps": {
+		summary:  "List maps",
+		query:    models.QueryBase{},
+		response: []models.GameMap{},
+	},
+	"PUT /game_maps/:id": {
+		summary:  "Update a map",
+		body:     putGameMapBody{},
+		response: models.GameMap{},
+	},
+
+	"POST /user_games": {
+		summary:  "Add a game profile",
+		body:     postUserGameBody{},
+		response: models.UserGame{},
+	},
+	"GET /user_games/:id": {
+		summary: "Get a game profile", response: models.UserGame{},
+	},
+	"GET /user_games": {
+		summary:  "List game profiles",
+		query:    models.QueryBase{},
+		response: []models.UserGame{},
+	},
+	"PATCH /user_games/:id": {
+		summary:  "Update, nullify, approve or reject a game profile",
+		body:     patchUserGameBody{},
+		response: models.UserGame{},
+	},
+
+	"GET /game_servers/:id": {
+		summary: "Get a verification server", response: models.GameServer{},
+	},
+	"GET /game_servers": {
+		summary:  "List verification servers, or the ones with free slots",
+		query:    getGameServersQuery{},
+		response: []models.GameServer{},
+	},
+
+	"GET /verification_attempts": {
+		summary:  "List verification attempts",
+		query:    models.QueryBase{},
+		response: []models.VerificationAttempt{},
+	},
+	"GET /account_alerts": {
+		summary:  "List alerts about users who look like the same person",
+		query:    models.QueryBase{},
+		response: []models.AccountAlert{},
+	},
+	"PATCH /account_alerts/:id": {
+		summary:  "Resolve an account alert",
+		body:     patchAccountAlertBody{},
+		response: models.AccountAlert{},
+	},
+
+	"POST /tournaments": {
+		summary:  "Create a tournament",
+		body:     postTournamentBody{},
+		response: models.Tournament{},
+	},
+	"GET /tournaments/:id": {
+		summary: "Get a tournament", response: models.Tournament{},
+	},
+	"GET /tournaments": {
+		summary:  "List tournaments",
+		query:    models.QueryBase{},
+		response: []models.Tournament{},
+	},
+	"PUT /tournaments/:id": {
+		summary:  "Update a tournament",
+		body:     putTournamentBody{},
+		response: models.Tournament{},
+	},
+
+	"POST /seasons": {
+		summary:  "Create a season",
+		body:     postSeasonBody{},
+		response: models.Season{},
+	},
+	"GET /seasons/:id": {summary: "Get a season", response: models.Season{}},
+	"GET /seasons": {
+		summary:  "List seasons",
+		query:    models.QueryBase{},
+		response: []models.Season{},
+	},
+	"PUT /seasons/:id": {
+		summary:  "Update a season",
+		body:     putSeasonBody{},
+		response: models.Season{},
+	},
+	"PATCH /seasons/:id": {
+		summary:  "Accept signups for a season, or end it",
+		body:     patchSeasonBody{},
+		response: models.Season{},
+	},
+
+	"POST /team_season_requests": {
+		summary:  "Sign a team up for a season",
+		body:     postTeamSeasonRequestBody{},
+		response: models.TeamSeasonRequest{},
+	},
+	"GET /team_season_requests/:id": {
+		summary:  "Get a season signup",
+		response: models.TeamSeasonRequest{},
+	},
+	"GET /team_season_requests": {
+		summary:  "List season signups",
+		query:    models.QueryBase{},
+		response: []models.TeamSeasonRequest{},
+	},
+	"PATCH /team_season_requests/:id": {
+		summary:  "Decide on or cancel a season signup",
+		body:     patchTeamSeasonRequestBody{},
+		response: models.TeamSeasonRequest{},
+	},
+
+	"GET /team_seasons": {
+		summary:  "List season participations",
+		query:    models.QueryBase{},
+		response: []models.TeamSeason{},
+	},
+	"GET /team_seasons/:id": {
+		summary: "Get a season participation", response: models.TeamSeason{},
+	},
+	"PATCH /team_seasons/:id": {
+		summary:  "Leave a season, kick a team or mark it done",
+		body:     patchTeamSeasonBody{},
+		response: models.TeamSeason{},
+	},
+
+	"POST /stages": {
+		summary: "Create a stage", body: postStageBody{}, response: models.Stage{},
+	},
+	"GET /stages/:id": {summary: "Get a stage", response: models.Stage{}},
+	"GET /stages": {
+		summary:  "List stages",
+		query:    models.QueryBase{},
+		response: []models.Stage{},
+	},
+	"PUT /stages/:id": {
+		summary: "Update a stage", body: putStageBody{}, response: models.Stage{},
+	},
+
+	"POST /brackets": {
+		summary:  "Create a bracket",
+		body:     postBracketBody{},
+		response: models.Bracket{},
+	},
+	"GET /brackets/:id": {summary: "Get a bracket", response: models.Bracket{}},
+	"GET /brackets": {
+		summary:  "List brackets",
+		query:    models.QueryBase{},
+		response: []models.Bracket{},
+	},
+	"PUT /brackets/:id": {
+		summary:  "Update a bracket",
+		body:     putBracketBody{},
+		response: models.Bracket{},
+	},
+	"PATCH /brackets/:id": {
+		summary: "Prepare a bracket, or fix it",
+		body:    patchBracketBody{},
+		status:  http.StatusNoContent,
+	},
+	"GET /brackets/:id/standings": {
+		summary:  "Get a bracket's standings, optionally as of some moment",
+		query:    getBracketStandingsQuery{},
+		response: bracketStandings{},
+	},
+
+	"POST /bracket_maps": {
+		summary:  "Add a map to a bracket's pool",
+		body:     postBracketMapBody{},
+		response: models.BracketMap{},
+	},
+	"GET /bracket_maps/:id": {
+		summary: "Get a bracket's map", response: models.BracketMap{},
+	},
+	"GET /bracket_maps": {
+		summary:  "List brackets' maps",
+		query:    models.QueryBase{},
+		response: []models.BracketMap{},
+	},
+
+	"GET /bracket_rounds/:id": {
+		summary: "Get a bracket round", response: models.BracketRound{},
+	},
+	"GET /bracket_rounds": {
+		summary:  "List bracket rounds",
+		query:    models.QueryBase{},
+		response: []models.BracketRound{},
+	},
+
+	"GET /matches/:id": {summary: "Get a match", response: models.Match{}},
+	"GET /matches": {
+		summary:  "List matches",
+		query:    models.QueryBase{},
+		response: []models.Match{},
+	},
+	"PUT /matches/:id": {
+		summary: "Update a match", body: putMatchBody{}, response: models.Match{},
+	},
+	"GET /matches/:id/leadership": {
+		summary:  "List the teams of a match that the user leads",
+		response: matchLeadership{},
+	},
+	"PATCH /matches/:id": {
+		summary: "Prepare a match's maps",
+		body:    patchMatchBody{},
+		status:  http.StatusNoContent,
+	},
+
+	"GET /match_maps/:id": {
+		summary: "Get a match's map", response: models.MatchMap{},
+	},
+	"GET /match_maps": {
+		summary:  "List matches' maps",
+		query:    models.QueryBase{},
+		response: []models.MatchMap{},
+	},
+
+	"POST /match_reports": {
+		summary:  "Report a match's result",
+		body:     postMatchReportBody{},
+		response: models.MatchReport{},
+	},
+	"GET /match_reports/:id": {
+		summary: "Get a match report", response: models.MatchReport{},
+	},
+	"GET /match_reports": {
+		summary:  "List match reports",
+		query:    models.QueryBase{},
+		response: []models.MatchReport{},
+	},
+	"PATCH /match_reports/:id": {
+		summary:  "Agree upon a match report",
+		body:     patchMatchReportBody{},
+		response: models.MatchReport{},
+	},
+
+	"GET /match_rounds/:id": {
+		summary: "Get a match round", response: models.MatchRound{},
+	},
+	"GET /match_rounds": {
+		summary:  "List match rounds",
+		query:    models.QueryBase{},
+		response: []models.MatchRound{},
+	},
+	"GET /match_penalties/:id": {
+		summary: "Get a match penalty", response: models.MatchPenalty{},
+	},
+	"GET /match_penalties": {
+		summary:  "List match penalties",
+		query:    models.QueryBase{},
+		response: []models.MatchPenalty{},
+	},
+
+	"POST /comments": {
+		summary:  "Comment",
+		body:     postCommentBody{},
+		response: models.Comment{},
+	},
+	"GET /comments/:id": {summary: "Get a comment", response: models.Comment{}},
+	"GET /comments": {
+		summary:  "List comments",
+		query:    models.QueryBase{},
+		response: []models.Comment{},
+	},
+	"PUT /comments/:id": {
+		summary:  "Edit a comment",
+		body:     putCommentBody{},
+		response: models.Comment{},
+	},
+	"PATCH /comments/:id": {
+		summary:  "Delete or restore a comment",
+		body:     patchCommentBody{},
+		response: models.Comment{},
+	},
+
+	"POST /attention_requests": {
+		summary:  "Ask for an admin's attention",
+		body:     postAttentionRequestBody{},
+		response: models.AttentionRequest{},
+	},
+	"GET /attention_requests/:id": {
+		summary:  "Get an attention request",
+		response: models.AttentionRequest{},
+	},
+	"GET /attention_requests": {
+		summary:  "List attention requests",
+		query:    models.QueryBase{},
+		response: []models.AttentionRequest{},
+	},
+	"PUT /attention_requests/:id": {
+		summary:  "Edit an attention request",
+		body:     putAttentionRequestBody{},
+		response: models.AttentionRequest{},
+	},
+	"PATCH /attention_requests/:id": {
+		summary:  "Claim, unclaim, resolve or discard an attention request",
+		body:     patchAttentionRequestBody{},
+		response: models.AttentionRequest{},
+	},
+
+	"POST /news_items": {
+		summary:  "Write news",
+		body:     postNewsItemBody{},
+		response: models.NewsItem{},
+	},
+	"GET /news_items/:id": {
+		summary: "Get a news item", response: models.NewsItem{},
+	},
+	"GET /news_items": {
+		summary:  "List news",
+		query:    models.QueryBase{},
+		response: []models.NewsItem{},
+	},
+	"PUT /news_items/:id": {
+		summary:  "Edit a news item",
+		body:     putNewsItemBody{},
+		response: models.NewsItem{},
+	},
+	"PATCH /news_items/:id": {
+		summary:  "Publish, delete or restore a news item",
+		body:     patchNewsItemBody{},
+		response: models.NewsItem{},
+	},
+}
+
+// CheckSpec lists the routes, as in "GET /users/:id", that aren't in spec.
+func CheckSpec(routes []string) error {
+	missing := make([]string, 0)
+	for _, route := range routes {
+		if _, ok := spec[route]; !ok {
+			missing = append(missing, route)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("no OpenAPI spec for %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// GetOpenAPI serves an OpenAPI 3 document generated from spec.
+func (e *Env) GetOpenAPI(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	return OK(openAPIDocument(), c, w)
+}
+
+type schema map[string]interface{}
+
+var pathParamRegexp = regexp.MustCompile(`:(\w+)`)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func openAPIDocument() schema {
+	components := make(schema)
+	paths := make(map[string]schema)
+	for route, op := range spec {
+		parts := strings.SplitN(route, " ", 2)
+		method, pattern := strings.ToLower(parts[0]), parts[1]
+		path := pathParamRegexp.ReplaceAllString(pattern, "{$1}")
+		if paths[path] == nil {
+			paths[path] = make(schema)
+		}
+
+		parameters := make([]schema, 0)
+		for _, match := range pathParamRegexp.FindAllStringSubmatch(pattern, -1) {
+			parameters = append(parameters, schema{
+				"name": match[1], "in": "path", "required": true,
+				"schema": schema{"type": "string"},
+			})
+		}
+
+		if op.query != nil {
+			parameters = append(parameters, queryParameters(op.query)...)
+		}
+
+		if method == "get" {
+			parameters = append(parameters,
+				schema{
+					"name": "include", "in": "query",
+					"description": "Relations to include, like teamX,matchMaps.gameMap",
+					"schema":      schema{"type": "string"},
+				},
+				schema{
+					"name": "fields", "in": "query",
+					"description": "Top-level fields to keep, comma-separated",
+					"schema":      schema{"type": "string"},
+				},
+			)
+		}
+
+		status := op.status
+		if status == 0 {
+			status = http.StatusOK
+			if method == "post" {
+				status = http.StatusCreated
+			}
+		}
+
+		response := schema{"description": http.StatusText(status)}
+		if op.response != nil {
+			response["content"] = schema{"application/json": schema{
+				"schema": typeSchema(reflect.TypeOf(op.response), components),
+			}}
+		}
+
+		operation := schema{
+			"summary":    op.summary,
+			"parameters": parameters,
+			"responses": schema{
+				fmt.Sprint(status): response,
+				"default": schema{
+					"description": "Error",
+					"content": schema{"application/json": schema{
+						"schema": typeSchema(reflect.TypeOf(Error{}), components),
+					}},
+				},
+			},
+		}
+
+		if op.body != nil {
+			operation["requestBody"] = schema{
+				"required": true,
+				"content": schema{"application/json": schema{
+					"schema": typeSchema(reflect.TypeOf(op.body), components),
+				}},
+			}
+		}
+
+		paths[path][method] = operation
+	}
+
+	return schema{
+		"openapi": "3.0.0",
+		"info":    schema{"title": "auzom", "version": "1"},
+		"paths":   paths,
+		"components": schema{
+			"schemas": components,
+		},
+	}
+}
+
+// queryParameters describes a struct decoded by DecodeQuery. goji/param reads
+// maps from keys like filter[team_x], which OpenAPI calls deep objects.
+func queryParameters(query interface{}) []schema {
+	parameters := make([]schema, 0)
+	t := reflect.TypeOf(query)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("param")
+		if name == "" {
+			continue
+		}
+
+		parameter := schema{
+			"name": name, "in": "query",
+			"schema": typeSchema(field.Type, nil),
+		}
+
+		if field.Type.Kind() == reflect.Map {
+			parameter["style"] = "deepObject"
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	sort.Slice(parameters, func(i, j int) bool {
+		return parameters[i]["name"].(string) < parameters[j]["name"].(string)
+	})
+
+	return parameters
+}
+
+// typeSchema describes t the way encoding/json would encode it. Exported
+// named structs end up in components, by name, if given any.
+func typeSchema(t reflect.Type, components schema) schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+
+	var s schema
+	switch {
+	case t == timeType:
+		s = schema{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Bool:
+		s = schema{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = schema{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = schema{"type": "number"}
+	case t.Kind() == reflect.String:
+		s = schema{"type": "string"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s = schema{"type": "array", "items": typeSchema(t.Elem(), components)}
+	case t.Kind() == reflect.Map:
+		s = schema{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), components),
+		}
+	case t.Kind() == reflect.Struct:
+		if components != nil && t.Name() != "" && isExported(t.Name()) {
+			if _, ok := components[t.Name()]; !ok {
+				components[t.Name()] = schema{} // in case it's recursive
+				components[t.Name()] = structSchema(t, components)
+			}
+
+			s = schema{"$ref": "#/components/schemas/" + t.Name()}
+		} else {
+			s = structSchema(t, components)
+		}
+	default:
+		s = schema{}
+	}
+
+	if nullable {
+		if _, ok := s["$ref"]; ok {
+			return schema{"allOf": []schema{s}, "nullable": true}
+		}
+
+		s["nullable"] = true
+	}
+
+	return s
+}
+
+func structSchema(t reflect.Type, components schema) schema {
+	properties := make(schema)
+	addStructProperties(t, properties, components)
+	return schema{"type": "object", "properties": properties}
+}
+
+func addStructProperties(t reflect.Type, properties, components schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			addStructProperties(field.Type, properties, components)
+			continue
+		} else if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+
+		if strings.Contains(field.Tag.Get("json"), ",string") {
+			properties[name] = schema{"type": "string"}
+		} else {
+			properties[name] = typeSchema(field.Type, components)
+		}
+	}
+}
+
+func isExported(name string) bool {
+	return strings.ToUpper(name[:1]) == name[:1]
+}
@@ -5,13 +5,16 @@ import (
 
 	"github.com/zenazn/goji/web"
 
+	"app/models"
 	"app/utils"
 )
 
+type postOTPBody struct {
+	Email string
+}
+
 func (e *Env) PostOTP(c web.C, w http.ResponseWriter, r *http.Request) *Error {
-	var data struct {
-		Email string
-	}
+	var data postOTPBody
 	err := Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -25,12 +28,12 @@ func (e *Env) PostOTP(c web.C, w http.ResponseWriter, r *http.Request) *Error {
 	} else if !user.IsEmailVerified {
 		return &Error{
 			C: http.StatusBadRequest,
-			M: "this email address isn't verified yet; " +
-				"contact support@auzom.gg if you can't verify it for some reason",
+			M: "this email address isn't verified yet; log in and have the " +
+				"verification email sent again, or contact support@auzom.gg",
 		}
 	}
 
-	otp, err := e.M.CreateOTP(user.Id)
+	otp, err := e.M.CreateOTP(user.Id, models.OTPLogin, nil)
 	if err != nil {
 		return &Error{E: err}
 	}
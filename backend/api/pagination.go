@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+)
+
+// listing is what Respond needs to know about a list request to write the
+// Link and X-Total-Count headers.
+type listing struct {
+	url      url.URL
+	query    models.QueryBase
+	modifier *models.QueryModifier
+	total    *uint64
+}
+
+// list is NewQueryModifier for handlers that return lists, which, on top of
+// filtering and sorting, handles cursors and total counts. Lists are
+// paginated either by offset or by cursor, and the latter is what the next
+// link uses, unless the request already had an offset.
+func (e *Env) list(
+	c web.C, r *http.Request, table string, query models.QueryBase,
+	filterAllowed []string, sortAllowed []string,
+) (*models.QueryModifier, *Error) {
+	return e.listNarrowed(c, r, table, query, filterAllowed, sortAllowed, nil)
+}
+
+// listNarrowed is list for handlers that need more than filters can say, like
+// a full-text match, which narrow adds to the modifier before it's paginated
+// or counted.
+func (e *Env) listNarrowed(
+	c web.C, r *http.Request, table string, query models.QueryBase,
+	filterAllowed []string, sortAllowed []string,
+	narrow func(*models.QueryModifier),
+) (*models.QueryModifier, *Error) {
+	if query.Cursor != "" && query.Offset > 0 {
+		return nil, invalid("cursor", CodeFormat, "cursor and offset are exclusive")
+	}
+
+	modifier := models.NewQueryModifier(query, filterAllowed, sortAllowed)
+	if modifier == nil {
+		modifier = &models.QueryModifier{}
+	}
+
+	if narrow != nil {
+		narrow(modifier)
+	}
+
+	err := modifier.Paginate(query.Cursor)
+	if err != nil {
+		return nil, invalid(
+			"cursor", CodeFormat, "bad cursor, or it was made for a different sort",
+		)
+	}
+
+	l := &listing{url: *r.URL, query: query, modifier: modifier}
+	if query.Total {
+		total, err := e.M.Count(table, modifier)
+		if err != nil {
+			return nil, &Error{E: err}
+		}
+
+		l.total = &total
+	}
+
+	c.Env["listing"] = l
+	return modifier, nil
+}
+
+// writeHeaders fails only if the next cursor can't be made, which means that
+// the sort isn't among the columns of data, and has to be taken out of the
+// handler's sortAllowed.
+func (l *listing) writeHeaders(header http.Header, data interface{}) error {
+	if l.total != nil {
+		header.Set("X-Total-Count", strconv.FormatUint(*l.total, 10))
+	}
+
+	rows := reflect.ValueOf(data)
+	if rows.Kind() != reflect.Slice {
+		return nil
+	}
+
+	links := make([]string, 0, 3)
+	link := func(rel string, set map[string]string) {
+		u := l.url
+		q := u.Query()
+		q.Del("cursor")
+		q.Del("offset")
+		for key, value := range set {
+			q.Set(key, value)
+		}
+
+		u.RawQuery = q.Encode()
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel))
+	}
+
+	link("first", nil)
+	offset, limit := l.query.Offset, l.query.Limit
+	if offset > 0 {
+		if offset > limit {
+			link("prev", map[string]string{
+				"offset": strconv.FormatUint(offset-limit, 10),
+			})
+		} else {
+			link("prev", nil)
+		}
+	}
+
+	if limit > 0 && uint64(rows.Len()) == limit {
+		if offset > 0 {
+			link("next", map[string]string{
+				"offset": strconv.FormatUint(offset+limit, 10),
+			})
+		} else {
+			last := rows.Index(rows.Len() - 1).Interface()
+			cursor, err := models.NewCursor(l.modifier.Sort, last)
+			if err != nil {
+				return err
+			}
+
+			link("next", map[string]string{"cursor": cursor})
+		}
+	}
+
+	header.Set("Link", strings.Join(links, ", "))
+	return nil
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+	"app/utils"
+)
+
+// me loads the user behind the session, along with their roles. API tokens act
+// on behalf of their owner, but roles only come along if the token has been
+// granted admin-read, and the handler is a reading one.
+func (e *Env) me(c web.C, session *models.Session) (*models.User, error) {
+	me, err := e.M.GetUserById(session.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	apiToken, ok := c.Env["apiToken"].(*models.ApiToken)
+	if ok && !apiToken.HasScope(ScopeAdminRead) {
+		me.IsAdmin = false
+		me.Roles = []models.Role{}
+		return me, nil
+	}
+
+	me.Roles, err = e.M.GetRolesByUser(me.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return me, nil
+}
+
+// Admin is for handlers that nobody else can use, the worker's included.
+func (e *Env) Admin(c web.C) (*models.User, *Error) {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return nil, &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return nil, &Error{E: err, C: http.StatusInternalServerError}
+	} else if !me.Is(models.RoleAdmin) {
+		return nil, &Error{E: utils.ErrUnauthorized}
+	}
+
+	return me, nil
+}
+
+// can tells whether me is allowed to act as role where the target belongs. me
+// is allowed to be nil, for handlers open to the public.
+func (e *Env) can(
+	me *models.User, role, target, targetId string,
+) (bool, *Error) {
+	if me == nil || len(me.Roles) == 0 {
+		return false, nil
+	}
+
+	scope, err := e.M.GetScope(target, targetId)
+	if err != nil {
+		return false, &Error{E: err}
+	}
+
+	return me.Can(role, scope), nil
+}
+
+// authorize is can, for handlers which have nothing to offer to those who can't.
+func (e *Env) authorize(
+	me *models.User, role, target, targetId string,
+) *Error {
+	can, apierr := e.can(me, role, target, targetId)
+	if apierr != nil {
+		return apierr
+	} else if !can {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	return nil
+}
+
+// RoleScope validates the role and figures out where it applies, a season
+// implies its tournament.
+func (e *Env) RoleScope(
+	name string, tournamentId, seasonId *string,
+) (models.Scope, *Error) {
+	var scope models.Scope
+	isKnown := false
+	for _, role := range models.Roles {
+		if role == name {
+			isKnown = true
+			break
+		}
+	}
+
+	if !isKnown {
+		return scope, invalid("name", CodeEnum, "unknown role "+name)
+	}
+
+	if seasonId != nil && *seasonId != "" {
+		var err error
+		scope, err = e.M.GetScope("season", *seasonId)
+		if err == utils.ErrNotFound {
+			return scope, invalid("seasonId", CodeId, "no such season")
+		} else if err != nil {
+			return scope, &Error{E: err}
+		} else if tournamentId != nil && *tournamentId != "" &&
+			*tournamentId != scope.TournamentId {
+			return scope, &Error{
+				C: http.StatusBadRequest, M: "season is from another tournament",
+			}
+		}
+	} else if tournamentId != nil && *tournamentId != "" {
+		_, err := e.M.GetTournamentById(*tournamentId)
+		if err == utils.ErrNotFound {
+			return scope, invalid("tournamentId", CodeId, "no such tournament")
+		} else if err != nil {
+			return scope, &Error{E: err}
+		}
+
+		scope.TournamentId = *tournamentId
+	}
+
+	if name == models.RoleAdmin && scope != models.Global {
+		return scope, &Error{C: http.StatusBadRequest, M: "admin can't be scoped"}
+	}
+
+	return scope, nil
+}
+
+// canGrant tells whether me is allowed to hand out (and take away) the role.
+// Tournament admins can make referees, news editors and moderators within
+// their tournament, but only admins can make tournament admins and admins.
+func canGrant(me *models.User, name string, scope models.Scope) bool {
+	if name == models.RoleAdmin || name == models.RoleTournamentAdmin {
+		return me.Is(models.RoleAdmin)
+	}
+
+	return me.Can(models.RoleTournamentAdmin, scope)
+}
+
+type postRoleBody struct {
+	UserId       string
+	Name         string
+	TournamentId *string
+	SeasonId     *string
+}
+
+func (e *Env) PostRole(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	var data postRoleBody
+	err = Decode(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	scope, apierr := e.RoleScope(data.Name, data.TournamentId, data.SeasonId)
+	if apierr != nil {
+		return apierr
+	} else if !canGrant(me, data.Name, scope) {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	_, err = e.M.GetUserById(data.UserId)
+	if err == utils.ErrNotFound {
+		return invalid("userId", CodeId, "no such user")
+	} else if err != nil {
+		return &Error{E: err}
+	}
+
+	tournamentId, seasonId := scope.Ids()
+	_, err = e.M.GetRoleByUserNameScope(
+		data.UserId, data.Name, tournamentId, seasonId,
+	)
+	if err == nil {
+		return &Error{C: http.StatusBadRequest, M: "role already granted"}
+	} else if err != utils.ErrNotFound {
+		return &Error{E: err}
+	}
+
+	role := &models.Role{
+		UserId:       data.UserId,
+		Name:         data.Name,
+		TournamentId: tournamentId,
+		SeasonId:     seasonId,
+		CreatedBy:    &me.Id,
+	}
+	err = e.M.CreateRole(role)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return Created(role, c, w)
+}
+
+func (e *Env) GetRoles(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	var data models.QueryBase
+	err := DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	// Who's in charge isn't a secret, players should know whom to ask.
+	modifier, apierr := e.list(c, r, "role", data,
+		[]string{"user_id", "name", "tournament_id", "season_id"},
+		[]string{"id", "user_id", "name", "created_at"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	roles, err := e.M.GetRoles(modifier)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(roles, c, w)
+}
+
+func (e *Env) DeleteRole(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	role, err := e.M.GetRoleById(c.URLParams["id"])
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	if !canGrant(me, role.Name, role.Scope()) {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	err = e.M.DeleteRole(role)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(role, c, w)
+}
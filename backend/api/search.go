@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+)
+
+const (
+	SearchLimitDefault = 20
+	SearchLimitMax     = 100
+)
+
+type getSearchQuery struct {
+	Query  string `param:"q"`
+	Type   string `param:"type"` // comma-separated, all of them if empty
+	Offset uint64 `param:"offset"`
+	Limit  uint64 `param:"count"`
+	Cursor string `param:"cursor"`
+	Total  bool   `param:"total"`
+}
+
+func (e *Env) GetSearch(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	var data getSearchQuery
+	err := DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	if strings.TrimSpace(data.Query) == "" {
+		return invalid("q", CodeRequired, "q is mandatory")
+	}
+
+	targets := models.SearchTargets
+	if data.Type != "" {
+		targets = strings.Split(data.Type, ",")
+		var v Validation
+		for _, target := range targets {
+			v.Enum("type", target, models.SearchTargets...)
+		}
+
+		apierr := v.Err()
+		if apierr != nil {
+			return apierr
+		}
+	}
+
+	if data.Limit == 0 {
+		data.Limit = SearchLimitDefault
+	} else if data.Limit > SearchLimitMax {
+		data.Limit = SearchLimitMax
+	}
+
+	modifier, apierr := e.listNarrowed(c, r, "search", models.QueryBase{
+		Offset: data.Offset,
+		Limit:  data.Limit,
+		Cursor: data.Cursor,
+		Total:  data.Total,
+	}, nil, nil, func(modifier *models.QueryModifier) {
+		models.NarrowSearch(modifier, data.Query, targets)
+	})
+	if apierr != nil {
+		return apierr
+	}
+
+	results, err := e.M.Search(data.Query, modifier)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(results, c, w)
+}
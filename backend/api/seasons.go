@@ -10,6 +10,25 @@ import (
 	"app/utils"
 )
 
+type postSeasonBody struct {
+	Slug            string
+	TournamentId    string
+	Name            string
+	Abbr            string
+	Description     string
+	Rules           string
+	TeamSize        int `json:",string"`
+	TeamSizeMax     int `json:",string"`
+	Capacity        int `json:",string"`
+	Duration        int `json:",string"`
+	YoutubePlaylist string
+	Sponsors        string
+	PublishedAt     *time.Time
+	SignupsOpenedAt *time.Time
+	SignupsClosedAt *time.Time
+	EndedAt         *time.Time
+}
+
 func (e *Env) PostSeason(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -18,54 +37,19 @@ func (e *Env) PostSeason(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Slug            string
-		TournamentId    string
-		Name            string
-		Abbr            string
-		Description     string
-		Rules           string
-		TeamSize        int `json:",string"`
-		TeamSizeMax     int `json:",string"`
-		Capacity        int `json:",string"`
-		Duration        int `json:",string"`
-		YoutubePlaylist string
-		Sponsors        string
-		PublishedAt     *time.Time
-		SignupsOpenedAt *time.Time
-		SignupsClosedAt *time.Time
-		EndedAt         *time.Time
-	}
+	var data postSeasonBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
-	} else if data.TeamSize < 1 {
-		return &Error{
-			E: err, C: http.StatusBadRequest,
-			M: "team size must be 1 or more",
-		}
-	} else if data.TeamSizeMax < data.TeamSize && data.TeamSizeMax != 0 {
-		return &Error{
-			E: err, C: http.StatusBadRequest,
-			M: "max team size must be 0 for unlimited, or more than team size",
-		}
-	} else if data.Capacity < 2 && data.Capacity != 0 {
-		return &Error{
-			E: err, C: http.StatusBadRequest,
-			M: "capacity must be 0 for unlimited, or more than 1",
-		}
-	} else if data.Duration < 1 {
-		return &Error{
-			E: err, C: http.StatusBadRequest,
-			M: "duration must be 1 or more",
-		}
+	} else if !me.Can(
+		models.RoleTournamentAdmin, models.Scope{TournamentId: data.TournamentId},
+	) {
+		return &Error{E: utils.ErrUnauthorized}
 	}
 
 	season := &models.Season{
@@ -89,6 +73,12 @@ func (e *Env) PostSeason(
 		},
 		CreatedBy: me.Id,
 	}
+
+	apierr := checkSeason(&season.SeasonPublic)
+	if apierr != nil {
+		return apierr
+	}
+
 	err = e.M.CreateSeason(season)
 	if err != nil {
 		return &Error{E: err}
@@ -97,6 +87,30 @@ func (e *Env) PostSeason(
 	return Created(season, c, w)
 }
 
+// checkSeason validates a season that's about to be saved, 0 standing for
+// unlimited in teamSizeMax and capacity.
+func checkSeason(season *models.SeasonPublic) *Error {
+	var v Validation
+	v.Range("teamSize", season.TeamSize, 1, maxInt)
+	if season.TeamSizeMax != 0 {
+		v.Range("teamSizeMax", season.TeamSizeMax, season.TeamSize, maxInt)
+	}
+
+	if season.Capacity != 0 {
+		v.Range("capacity", season.Capacity, 2, maxInt)
+	}
+
+	v.Range("duration", season.Duration, 1, maxInt)
+	v.Ordered(
+		"signupsOpenedAt", season.SignupsOpenedAt,
+		"signupsClosedAt", season.SignupsClosedAt,
+	)
+	v.Ordered(
+		"signupsClosedAt", season.SignupsClosedAt, "endedAt", season.EndedAt,
+	)
+	return v.Err()
+}
+
 func (e *Env) GetSeason(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -107,10 +121,12 @@ func (e *Env) GetSeason(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Can(models.RoleTournamentAdmin, models.Scope{
+			TournamentId: season.TournamentId, SeasonId: season.Id,
+		}) {
 			return OK(season, c, w)
 		}
 	}
@@ -125,33 +141,32 @@ func (e *Env) GetSeason(
 func (e *Env) GetSeasons(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	seasons, err := e.M.GetSeasons(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "season", data,
 		[]string{"tournament_id", "slug"},
 		[]string{"id", "tournament_id", "name", "abbr", "published_at",
 			"signups_opened_at", "signups_closed_at", "ended_at"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	seasons, err := e.M.GetSeasons(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleTournamentAdmin) {
 			return OK(seasons, c, w)
 		}
 	}
@@ -169,6 +184,24 @@ func (e *Env) GetSeasons(
 	return OK(public, c, w)
 }
 
+type putSeasonBody struct {
+	Slug            *string
+	Name            *string
+	Abbr            *string
+	Description     *string
+	Rules           *string
+	TeamSize        *int `json:",string"`
+	TeamSizeMax     *int `json:",string"`
+	Capacity        *int `json:",string"`
+	Duration        *int `json:",string"`
+	YoutubePlaylist *string
+	Sponsors        *string
+	PublishedAt     *time.Time
+	SignupsOpenedAt *time.Time
+	SignupsClosedAt *time.Time
+	EndedAt         *time.Time
+}
+
 func (e *Env) PutSeason(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -177,37 +210,26 @@ func (e *Env) PutSeason(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Slug            *string
-		Name            *string
-		Abbr            *string
-		Description     *string
-		Rules           *string
-		TeamSize        *int `json:",string"`
-		TeamSizeMax     *int `json:",string"`
-		Capacity        *int `json:",string"`
-		Duration        *int `json:",string"`
-		YoutubePlaylist *string
-		Sponsors        *string
-		PublishedAt     *time.Time
-		SignupsOpenedAt *time.Time
-		SignupsClosedAt *time.Time
-		EndedAt         *time.Time
+	apierr := e.authorize(
+		me, models.RoleTournamentAdmin, "season", c.URLParams["id"],
+	)
+	if apierr != nil {
+		return apierr
 	}
+
+	var data putSeasonBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
 	var season *models.Season
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		var inerr error
 		season, inerr = etx.GetSeasonById(c.URLParams["id"])
 		if inerr != nil {
@@ -258,13 +280,6 @@ func (e *Env) PutSeason(
 		}
 
 		if data.TeamSize != nil && *data.TeamSize != season.TeamSize {
-			if *data.TeamSize < 1 {
-				return &Error{
-					E: err, C: http.StatusBadRequest,
-					M: "team size must be 1 or more",
-				}
-			}
-
 			season.TeamSize = *data.TeamSize
 			somethingChanged = true
 		}
@@ -274,33 +289,12 @@ func (e *Env) PutSeason(
 			somethingChanged = true
 		}
 
-		if season.TeamSizeMax < season.TeamSize && season.TeamSizeMax != 0 {
-			return &Error{
-				E: err, C: http.StatusBadRequest,
-				M: "max team size must be 0 for unlimited, or more than team size",
-			}
-		}
-
 		if data.Capacity != nil && *data.Capacity != season.Capacity {
-			if *data.Capacity < 2 && *data.Capacity != 0 {
-				return &Error{
-					E: err, C: http.StatusBadRequest,
-					M: "capacity must be 0 for unlimited, or more than 1",
-				}
-			}
-
 			season.Capacity = *data.Capacity
 			somethingChanged = true
 		}
 
 		if data.Duration != nil && *data.Duration != season.Duration {
-			if *data.Duration < 1 {
-				return &Error{
-					E: err, C: http.StatusBadRequest,
-					M: "duration must be 2 or more",
-				}
-			}
-
 			season.Duration = *data.Duration
 			somethingChanged = true
 		}
@@ -356,6 +350,11 @@ func (e *Env) PutSeason(
 			return nil
 		}
 
+		apierr := checkSeason(&season.SeasonPublic)
+		if apierr != nil {
+			return apierr
+		}
+
 		return etx.UpdateSeason(season, me.Id)
 	})
 	if err != nil {
@@ -370,6 +369,10 @@ func (e *Env) PutSeason(
 	return OK(season, c, w)
 }
 
+type patchSeasonBody struct {
+	Action string
+}
+
 func (e *Env) PatchSeason(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -378,23 +381,26 @@ func (e *Env) PatchSeason(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Action string
+	apierr := e.authorize(
+		me, models.RoleTournamentAdmin, "season", c.URLParams["id"],
+	)
+	if apierr != nil {
+		return apierr
 	}
+
+	var data patchSeasonBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
 	var season *models.Season
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		var inerr error
 		season, inerr = etx.GetSeasonById(c.URLParams["id"])
 		if inerr != nil {
@@ -473,7 +479,7 @@ func (e *Env) PatchSeason(
 
 			// TODO: admin-approve all team applications? not sure if it's possible
 		} else {
-			return &Error{C: http.StatusBadRequest, M: "bad action"}
+			return invalid("action", CodeEnum, "bad action")
 		}
 
 		return nil
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/elithrar/simple-scrypt"
 	"github.com/zenazn/goji/web"
@@ -16,27 +17,40 @@ var (
 	ErrBadToken = errors.New("invalid token")
 )
 
+type postSessionBody struct {
+	Email    string
+	Password string
+	Remember bool
+	Token    string
+	Provider string
+	Callback map[string]string
+}
+
 func (e *Env) PostSession(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Email    string
-		Password string
-		Remember bool
-		Token    string
-	}
+	var data postSessionBody
 	err := Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
 	var user *models.User
-	if data.Token == "" {
+	if data.Provider != "" {
+		var apierr *Error
+		user, data.Remember, apierr = e.identityUser(data.Provider, data.Callback)
+		if apierr != nil {
+			return apierr
+		}
+	} else if data.Token == "" {
 		user, err = e.M.GetUserByEmail(data.Email)
 		if err == utils.ErrNotFound {
 			return &Error{E: ErrBadAuth, C: http.StatusBadRequest}
 		} else if err != nil {
 			return &Error{E: err}
+		} else if len(user.Password) == 0 {
+			// signed up through an identity provider, never set a password
+			return &Error{E: ErrBadAuth, C: http.StatusBadRequest}
 		}
 
 		// TODO: automatically upgrade hash, if work factors differ from config
@@ -58,11 +72,36 @@ func (e *Env) PostSession(
 				return &Error{E: inerr}
 			}
 
-			user, inerr = e.M.GetUserById(otp.UserId)
-			if err != nil {
+			user, inerr = etx.GetUserById(otp.UserId)
+			if inerr != nil {
 				return &Error{E: inerr, C: http.StatusInternalServerError}
 			}
 
+			if otp.Purpose == models.OTPEmail {
+				if otp.CreatedAt.Add(EmailChangeAge).Before(time.Now()) {
+					return &Error{
+						C: http.StatusBadRequest,
+						M: "email change link expired, please, request it again",
+					}
+				}
+
+				// the address could've been claimed while the link was in transit
+				_, inerr = etx.GetUserByEmail(*otp.Email)
+				if inerr == nil {
+					return invalid("email", CodeTaken, "email taken")
+				} else if inerr != utils.ErrNotFound {
+					return &Error{E: inerr}
+				}
+
+				user.Email = *otp.Email
+
+				// whatever was sent to the old address is no good anymore
+				inerr = etx.DeleteOTPsByUser(user.Id)
+				if inerr != nil {
+					return &Error{E: inerr}
+				}
+			}
+
 			user.IsEmailVerified = true
 			inerr = etx.UpdateUser(user, user.Id)
 			if inerr != nil {
@@ -10,6 +10,14 @@ import (
 	"app/utils"
 )
 
+type postStageBody struct {
+	SeasonId  string
+	Slug      string
+	Name      string
+	Abbr      string
+	StartedAt *time.Time
+}
+
 func (e *Env) PostStage(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -18,25 +26,22 @@ func (e *Env) PostStage(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		SeasonId  string
-		Slug      string
-		Name      string
-		Abbr      string
-		StartedAt *time.Time
-	}
+	var data postStageBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
+	apierr := e.authorize(me, models.RoleTournamentAdmin, "season", data.SeasonId)
+	if apierr != nil {
+		return apierr
+	}
+
 	stage := &models.Stage{
 		StagePublic: models.StagePublic{
 			SeasonId:  data.SeasonId,
@@ -65,10 +70,15 @@ func (e *Env) GetStage(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		}
+
+		can, apierr := e.can(me, models.RoleTournamentAdmin, "stage", stage.Id)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(stage, c, w)
 		}
 	}
@@ -79,32 +89,31 @@ func (e *Env) GetStage(
 func (e *Env) GetStages(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	stages, err := e.M.GetStages(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "stage", data,
 		[]string{"season_id", "slug"},
 		[]string{"id", "season_id", "name", "abbr", "started_at"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	stages, err := e.M.GetStages(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleTournamentAdmin) {
 			return OK(stages, c, w)
 		}
 	}
@@ -117,6 +126,13 @@ func (e *Env) GetStages(
 	return OK(public, c, w)
 }
 
+type putStageBody struct {
+	Slug      *string
+	Name      *string
+	Abbr      *string
+	StartedAt *time.Time
+}
+
 func (e *Env) PutStage(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -125,11 +141,16 @@ func (e *Env) PutStage(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	apierr := e.authorize(
+		me, models.RoleTournamentAdmin, "stage", c.URLParams["id"],
+	)
+	if apierr != nil {
+		return apierr
 	}
 
 	stage, err := e.M.GetStageById(c.URLParams["id"])
@@ -137,12 +158,7 @@ func (e *Env) PutStage(
 		return &Error{E: err}
 	}
 
-	var data struct {
-		Slug      *string
-		Name      *string
-		Abbr      *string
-		StartedAt *time.Time
-	}
+	var data putStageBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -176,8 +192,15 @@ func (e *Env) PutStage(
 		return OK(stage, c, w)
 	}
 
-	err = e.M.UpdateStage(stage, me.Id)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		return etx.UpdateStage(stage, me.Id)
+	})
 	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
 		return &Error{E: err}
 	}
 
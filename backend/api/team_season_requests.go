@@ -10,6 +10,11 @@ import (
 	"app/utils"
 )
 
+type postTeamSeasonRequestBody struct {
+	TeamId   string `json:"teamId"`
+	SeasonId string `json:"seasonId"`
+}
+
 func (e *Env) PostTeamSeasonRequest(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -18,18 +23,23 @@ func (e *Env) PostTeamSeasonRequest(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		TeamId   string `json:"teamId"`
-		SeasonId string `json:"seasonId"`
-	}
+	var data postTeamSeasonRequestBody
 	err := Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
+	var v Validation
+	v.Id("teamId", data.TeamId)
+	v.Id("seasonId", data.SeasonId)
+	apierr := v.Err()
+	if apierr != nil {
+		return apierr
+	}
+
 	team, err := e.M.GetTeamById(data.TeamId)
 	if err == utils.ErrNotFound {
-		return &Error{C: http.StatusBadRequest, M: "bad team id"}
+		return invalid("teamId", CodeId, "bad team id")
 	}
 	if err != nil {
 		return &Error{E: err}
@@ -48,7 +58,7 @@ func (e *Env) PostTeamSeasonRequest(
 
 	season, err := e.M.GetSeasonById(data.SeasonId)
 	if err == utils.ErrNotFound {
-		return &Error{C: http.StatusBadRequest, M: "bad season id"}
+		return invalid("seasonId", CodeId, "bad season id")
 	}
 	if err != nil {
 		return &Error{E: err}
@@ -142,22 +152,21 @@ func (e *Env) GetTeamSeasonRequest(
 func (e *Env) GetTeamSeasonRequests(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	requests, err := e.M.GetTeamSeasonRequests(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "team_season_request", data,
 		[]string{"team_id", "season_id", "decision"},
 		[]string{"id", "team_id", "season_id", "created_at"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	requests, err := e.M.GetTeamSeasonRequests(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
@@ -165,6 +174,10 @@ func (e *Env) GetTeamSeasonRequests(
 	return OK(requests, c, w)
 }
 
+type patchTeamSeasonRequestBody struct {
+	Action string
+}
+
 func (e *Env) PatchTeamSeasonRequest(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -178,9 +191,7 @@ func (e *Env) PatchTeamSeasonRequest(
 		return &Error{E: err}
 	}
 
-	var data struct {
-		Action string
-	}
+	var data patchTeamSeasonRequestBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -201,10 +212,10 @@ func (e *Env) PatchTeamSeasonRequest(
 			}
 		}
 	} else if data.Action != "no" && data.Action != "cancel" {
-		return &Error{E: err, C: http.StatusBadRequest, M: "bad action"}
+		return invalid("action", CodeEnum, "bad action")
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
@@ -221,7 +232,12 @@ func (e *Env) PatchTeamSeasonRequest(
 
 		request.CancelledBy = &me.Id
 	} else {
-		if !me.IsAdmin {
+		can, apierr := e.can(
+			me, models.RoleTournamentAdmin, "season", request.SeasonId,
+		)
+		if apierr != nil {
+			return apierr
+		} else if !can {
 			return &Error{
 				E: err, C: http.StatusBadRequest,
 				M: "only admins can accept / decline applications",
@@ -254,10 +270,10 @@ func PatchTeamSeasonRequestHelper(
 	eM *models.Env, request *models.TeamSeasonRequest,
 ) error {
 	userTeamRequests, inerr := eM.GetUserTeamRequests(models.NewQueryModifier(
-		models.QueryBase{0, 0, map[string]string{
+		models.QueryBase{Filter: map[string]string{
 			"team_id":  request.TeamId,
 			"decision": "\x00",
-		}, ""},
+		}},
 		[]string{"team_id", "decision"},
 		[]string{},
 	))
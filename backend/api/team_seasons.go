@@ -20,11 +20,17 @@ func (e *Env) GetTeamSeason(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+
+		can, apierr := e.can(
+			me, models.RoleTournamentAdmin, "season", teamSeason.SeasonId,
+		)
+		if apierr != nil {
+			return apierr
+		} else if can {
 			return OK(teamSeason, c, w)
 		}
 
@@ -43,12 +49,7 @@ func (e *Env) GetTeamSeason(
 func (e *Env) GetTeamSeasons(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -58,28 +59,32 @@ func (e *Env) GetTeamSeasons(
 	seasonId, _ := data.Filter["season_id"]
 	requestId, _ := data.Filter["request_id"]
 	if (teamId == "") && (seasonId == "") && (requestId == "") {
-		return &Error{
-			E: err, C: http.StatusBadRequest,
-			M: "filter by team_id, season_id or request_id required",
-		}
+		return invalid(
+			"filter", CodeRequired,
+			"filter by team_id, season_id or request_id required",
+		)
 	}
 
-	teamSeasons, err := e.M.GetTeamSeasons(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "team_season", data,
 		[]string{"team_id", "season_id", "is_done"},
 		[]string{"id", "team_id", "season_id", "is_done", "created_at", "left_at"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	teamSeasons, err := e.M.GetTeamSeasons(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleTournamentAdmin) {
 			return OK(teamSeasons, c, w)
 		}
 
@@ -94,6 +99,10 @@ func (e *Env) GetTeamSeasons(
 	return OK(public, c, w)
 }
 
+type patchTeamSeasonBody struct {
+	Action string
+}
+
 func (e *Env) PatchTeamSeason(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -102,17 +111,12 @@ func (e *Env) PatchTeamSeason(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
-	if !me.IsAdmin {
-		return &Error{E: utils.ErrUnauthorized}
-	}
 
-	var data struct {
-		Action string
-	}
+	var data patchTeamSeasonBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -122,6 +126,14 @@ func (e *Env) PatchTeamSeason(
 	if err != nil {
 		return &Error{E: err}
 	}
+
+	apierr := e.authorize(
+		me, models.RoleTournamentAdmin, "season", teamSeason.SeasonId,
+	)
+	if apierr != nil {
+		return apierr
+	}
+
 	if teamSeason.LeftAt != nil {
 		return &Error{C: http.StatusBadRequest, M: "this team isn't a participant"}
 	}
@@ -135,11 +147,18 @@ func (e *Env) PatchTeamSeason(
 	} else if data.Action == "kick" {
 		teamSeason.KickedBy = &me.Id
 	} else {
-		return &Error{E: err, C: http.StatusBadRequest, M: "bad action"}
+		return invalid("action", CodeEnum, "bad action")
 	}
 
-	err = e.M.UpdateTeamSeason(teamSeason, me.Id)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		return etx.UpdateTeamSeason(teamSeason, me.Id)
+	})
 	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
 		return &Error{E: err}
 	}
 
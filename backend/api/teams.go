@@ -18,6 +18,12 @@ const ( // inspired by Steam
 	abbrMaxLen = 12
 )
 
+type postTeamBody struct {
+	Name string
+	Abbr string
+	Logo string
+}
+
 func (e *Env) PostTeam(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -26,32 +32,28 @@ func (e *Env) PostTeam(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Name string
-		Abbr string
-		Logo string
-	}
+	var data postTeamBody
 	err := Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
+	var v Validation
 	data.Name = strings.TrimSpace(data.Name)
-	if len(data.Name) < nameMinLen || len(data.Name) > nameMaxLen {
-		return &Error{C: http.StatusBadRequest, M: "invalid name length"}
-	}
-
+	v.Length("name", data.Name, nameMinLen, nameMaxLen)
 	data.Abbr = strings.TrimSpace(data.Abbr)
-	if len(data.Abbr) < abbrMinLen || len(data.Abbr) > abbrMaxLen {
-		return &Error{C: http.StatusBadRequest, M: "invalid abbreviation length"}
+	v.Length("abbr", data.Abbr, abbrMinLen, abbrMaxLen)
+	apierr := v.Err()
+	if apierr != nil {
+		return apierr
 	}
 
 	teams, err := e.M.GetTeams(models.NewQueryModifier(
-		models.QueryBase{0, 0, map[string]string{
+		models.QueryBase{Filter: map[string]string{
 			"created_by":   session.UserId,
 			"disbanded_at": "\x00",
-		}, ""},
-		[]string{"created_by", "disbanded_at"},
+		}},
+		[]string{"created_by", "disbanded_at", "name:text", "abbr:text"},
 		[]string{},
 	))
 	if err != nil {
@@ -110,11 +112,11 @@ func (e *Env) GetTeam(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleAdmin) {
 			return OK(team, c, w)
 		}
 
@@ -133,33 +135,32 @@ func (e *Env) GetTeam(
 func (e *Env) GetTeams(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	teams, err := e.M.GetTeams(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "team", data,
 		[]string{"created_by", "disbanded_at"},
 		[]string{"id", "name", "abbr", "created_at", "created_by"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	teams, err := e.M.GetTeams(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleAdmin) {
 			return OK(teams, c, w)
 		}
 	}
@@ -172,6 +173,12 @@ func (e *Env) GetTeams(
 	return OK(public, c, w)
 }
 
+type putTeamBody struct {
+	Name *string
+	Abbr *string
+	Logo *string
+}
+
 func (e *Env) PutTeam(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -180,7 +187,7 @@ func (e *Env) PutTeam(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
@@ -193,7 +200,7 @@ func (e *Env) PutTeam(
 		return &Error{C: http.StatusBadRequest, M: "this team is disbanded"}
 	}
 
-	if !me.IsAdmin {
+	if !me.Is(models.RoleAdmin) {
 		myUserTeam, err := e.M.GetUserTeamByUserTeam(me.Id, team.Id)
 		if err != nil && err != utils.ErrNotFound {
 			return &Error{E: err, C: http.StatusInternalServerError}
@@ -203,11 +210,7 @@ func (e *Env) PutTeam(
 		}
 	}
 
-	var data struct {
-		Name *string
-		Abbr *string
-		Logo *string
-	}
+	var data putTeamBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -219,7 +222,7 @@ func (e *Env) PutTeam(
 		*data.Name = strings.TrimSpace(*data.Name)
 		if *data.Name != team.Name {
 			if len(*data.Name) < nameMinLen || len(*data.Name) > nameMaxLen {
-				return &Error{C: http.StatusBadRequest, M: "invalid name length"}
+				return invalid("name", CodeLength, "invalid name length")
 			}
 
 			team.Name = *data.Name
@@ -231,9 +234,9 @@ func (e *Env) PutTeam(
 		*data.Abbr = strings.TrimSpace(*data.Abbr)
 		if *data.Abbr != team.Abbr {
 			if len(*data.Abbr) < abbrMinLen || len(*data.Abbr) > abbrMaxLen {
-				return &Error{
-					C: http.StatusBadRequest, M: "invalid abbreviation length",
-				}
+				return invalid(
+					"abbr", CodeLength, "invalid abbreviation length",
+				)
 			}
 
 			team.Abbr = *data.Abbr
@@ -250,14 +253,25 @@ func (e *Env) PutTeam(
 		return OK(team, c, w)
 	}
 
-	err = e.M.UpdateTeam(team, me.Id)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		return etx.UpdateTeam(team, me.Id)
+	})
 	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
 		return &Error{E: err}
 	}
 
 	return OK(team, c, w)
 }
 
+type patchTeamBody struct {
+	Action string
+}
+
 func (e *Env) PatchTeam(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -266,11 +280,11 @@ func (e *Env) PatchTeam(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
-	if !me.IsAdmin {
+	if !me.Is(models.RoleAdmin) {
 		return &Error{
 			E: utils.ErrUnauthorized,
 			M: "only admins can disband teams at the moment",
@@ -285,26 +299,24 @@ func (e *Env) PatchTeam(
 		return &Error{C: http.StatusBadRequest, M: "this team is disbanded"}
 	}
 
-	var data struct {
-		Action string
-	}
+	var data patchTeamBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 	if data.Action != "disband" {
-		return &Error{E: err, C: http.StatusBadRequest, M: "bad action"}
+		return invalid("action", CodeEnum, "bad action")
 	}
 
 	now := time.Now()
 	team.DisbandedAt = &now
 	team.DisbandedBy = &me.Id
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		teamSeasons, inerr := etx.GetTeamSeasons(models.NewQueryModifier(
-			models.QueryBase{0, 0, map[string]string{
+			models.QueryBase{Filter: map[string]string{
 				"team_id": team.Id,
 				"left_at": "\x00",
-			}, ""},
+			}},
 			[]string{"team_id", "left_at"},
 			[]string{},
 		))
@@ -320,10 +332,10 @@ func (e *Env) PatchTeam(
 
 		teamSeasonRequests, inerr := etx.GetTeamSeasonRequests(
 			models.NewQueryModifier(
-				models.QueryBase{0, 0, map[string]string{
+				models.QueryBase{Filter: map[string]string{
 					"team_id":  team.Id,
 					"decision": "\x00",
-				}, ""},
+				}},
 				[]string{"team_id", "decision"},
 				[]string{},
 			),
@@ -344,10 +356,10 @@ func (e *Env) PatchTeam(
 		}
 
 		userTeams, inerr := etx.GetUserTeams(models.NewQueryModifier(
-			models.QueryBase{0, 0, map[string]string{
+			models.QueryBase{Filter: map[string]string{
 				"team_id": team.Id,
 				"left_at": "\x00",
-			}, ""},
+			}},
 			[]string{"team_id", "left_at"},
 			[]string{},
 		))
@@ -366,10 +378,10 @@ func (e *Env) PatchTeam(
 		}
 
 		userTeamRequests, inerr := etx.GetUserTeamRequests(models.NewQueryModifier(
-			models.QueryBase{0, 0, map[string]string{
+			models.QueryBase{Filter: map[string]string{
 				"team_id":  team.Id,
 				"decision": "\x00",
-			}, ""},
+			}},
 			[]string{"team_id", "decision"},
 			[]string{},
 		))
@@ -10,6 +10,26 @@ import (
 	"app/utils"
 )
 
+type postTournamentBody struct {
+	Slug        string
+	GameId      string
+	Name        string
+	Abbr        string
+	FoundedAt   time.Time
+	Description string
+	Email       string
+	Twitch      string
+	Youtube     string
+	Twitter     string
+	Facebook    string
+	Discord     string
+	Web         string
+	TwitchLive  string
+	Blur        string
+	Logo        string
+	LogoHasText bool
+}
+
 func (e *Env) PostTournament(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -18,32 +38,14 @@ func (e *Env) PostTournament(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
+	} else if !me.Is(models.RoleAdmin) {
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Slug        string
-		GameId      string
-		Name        string
-		Abbr        string
-		FoundedAt   time.Time
-		Description string
-		Email       string
-		Twitch      string
-		Youtube     string
-		Twitter     string
-		Facebook    string
-		Discord     string
-		Web         string
-		TwitchLive  string
-		Blur        string
-		Logo        string
-		LogoHasText bool
-	}
+	var data postTournamentBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -89,10 +91,12 @@ func (e *Env) GetTournament(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Can(
+			models.RoleTournamentAdmin, models.Scope{TournamentId: tournament.Id},
+		) {
 			return OK(tournament, c, w)
 		}
 	}
@@ -103,32 +107,31 @@ func (e *Env) GetTournament(
 func (e *Env) GetTournaments(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	tournaments, err := e.M.GetTournaments(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
-		[]string{"game_id", "slug"},
+	modifier, apierr := e.list(c, r, "tournament", data,
+		[]string{"game_id", "slug", "name:text"},
 		[]string{"id", "game_id", "name", "abbr", "founded_at"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	tournaments, err := e.M.GetTournaments(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleTournamentAdmin) {
 			return OK(tournaments, c, w)
 		}
 	}
@@ -141,6 +144,25 @@ func (e *Env) GetTournaments(
 	return OK(public, c, w)
 }
 
+type putTournamentBody struct {
+	Slug        *string
+	Name        *string
+	Abbr        *string
+	FoundedAt   *time.Time
+	Description *string
+	Email       *string
+	Twitch      *string
+	Youtube     *string
+	Twitter     *string
+	Facebook    *string
+	Discord     *string
+	Web         *string
+	TwitchLive  *string
+	Blur        *string
+	Logo        *string
+	LogoHasText *bool
+}
+
 func (e *Env) PutTournament(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -149,38 +171,24 @@ func (e *Env) PutTournament(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if !me.IsAdmin {
+	} else if !me.Can(
+		models.RoleTournamentAdmin,
+		models.Scope{TournamentId: c.URLParams["id"]},
+	) {
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Slug        *string
-		Name        *string
-		Abbr        *string
-		FoundedAt   *time.Time
-		Description *string
-		Email       *string
-		Twitch      *string
-		Youtube     *string
-		Twitter     *string
-		Facebook    *string
-		Discord     *string
-		Web         *string
-		TwitchLive  *string
-		Blur        *string
-		Logo        *string
-		LogoHasText *bool
-	}
+	var data putTournamentBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
 	var tournament *models.Tournament
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		var inerr error
 		tournament, inerr = etx.GetTournamentById(c.URLParams["id"])
 		if inerr != nil {
@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/zenazn/goji/web"
@@ -10,6 +11,10 @@ import (
 	"app/utils"
 )
 
+type postUserGameBody struct {
+	GameId string
+}
+
 func (e *Env) PostUserGame(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -18,19 +23,31 @@ func (e *Env) PostUserGame(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		GameId string
-	}
+	var data postUserGameBody
 	err := Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
+	var v Validation
+	v.Id("gameId", data.GameId)
+	apierr := v.Err()
+	if apierr != nil {
+		return apierr
+	}
+
 	game, err := e.M.GetGameById(data.GameId)
 	if err != nil {
-		return &Error{E: err, C: http.StatusBadRequest, M: "bad game id"}
-	} else if game.VerificationHandle == nil ||
-		*game.VerificationHandle != "battlefield-4" { // TODO: constant
+		return invalid("gameId", CodeId, "bad game id")
+	}
+
+	handle := ""
+	if game.VerificationHandle != nil {
+		handle = *game.VerificationHandle
+	}
+
+	verifier, err := e.Verification.Verifier(handle)
+	if err != nil {
 		return &Error{
 			C: http.StatusBadRequest,
 			M: "this game's verification method isn't implemented yet",
@@ -46,7 +63,7 @@ func (e *Env) PostUserGame(
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
 
-	token, err := utils.GenerateToken(5, true)
+	token, err := verifier.IssueToken()
 	if err != nil {
 		return &Error{E: err}
 	}
@@ -76,10 +93,10 @@ func (e *Env) GetUserGame(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin || me.Id == userGame.UserId {
+		} else if me.Is(models.RoleAdmin) || me.Id == userGame.UserId {
 			return OK(userGame, c, w)
 		}
 	}
@@ -90,12 +107,7 @@ func (e *Env) GetUserGame(
 func (e *Env) GetUserGames(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -104,27 +116,30 @@ func (e *Env) GetUserGames(
 	userId, _ := data.Filter["user_id"]
 	gameId, _ := data.Filter["game_id"]
 	if (userId == "") && (gameId == "") {
-		return &Error{
-			E: err, C: http.StatusBadRequest,
-			M: "filter by user_id or game_id required",
-		}
+		return invalid(
+			"filter", CodeRequired, "filter by user_id or game_id required",
+		)
 	}
 
-	userGames, err := e.M.GetUserGames(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "user_game", data,
 		[]string{"user_id", "game_id"},
 		[]string{},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	userGames, err := e.M.GetUserGames(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin || me.Id == userId {
+		} else if me.Is(models.RoleAdmin) || me.Id == userId {
 			return OK(userGames, c, w)
 		}
 	}
@@ -137,6 +152,11 @@ func (e *Env) GetUserGames(
 	return OK(public, c, w)
 }
 
+type patchUserGameBody struct {
+	Action string
+	Reason string // for approve and reject
+}
+
 func (e *Env) PatchUserGame(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -149,9 +169,7 @@ func (e *Env) PatchUserGame(
 		}
 	}
 
-	var data struct {
-		Action string
-	}
+	var data patchUserGameBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -160,7 +178,7 @@ func (e *Env) PatchUserGame(
 	var me *models.User
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err = e.M.GetUserById(session.UserId)
+		me, err = e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
@@ -168,13 +186,15 @@ func (e *Env) PatchUserGame(
 
 	now := time.Now()
 	if data.Action == "nullify" {
-		if me != nil && me.Id != userGame.UserId && !me.IsAdmin {
+		if me != nil && me.Id != userGame.UserId && !me.Is(models.RoleAdmin) {
 			return &Error{E: utils.ErrUnauthorized}
 		}
 
 		userGame.Token = nil
 		userGame.NullifiedAt = &now
 		userGame.NullifiedBy = &me.Id
+	} else if data.Action == "approve" || data.Action == "reject" {
+		return e.decideUserGame(c, w, userGame, me, data)
 	} else if data.Action == "update" {
 		if now.Before(userGame.DataUpdatedAt.Add(time.Minute)) {
 			return &Error{
@@ -190,17 +210,92 @@ func (e *Env) PatchUserGame(
 
 		userGame.DataUpdateRequestedAt = &now
 	} else {
-		return &Error{C: http.StatusBadRequest, M: "bad action"}
+		return invalid("action", CodeEnum, "bad action")
 	}
 
-	err = e.M.UpdateUserGame(userGame)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		return etx.UpdateUserGame(userGame)
+	})
 	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
 		return &Error{E: err}
 	}
 
-	if me != nil && (me.Id == userGame.UserId || me.IsAdmin) {
+	if me != nil && (me.Id == userGame.UserId || me.Is(models.RoleAdmin)) {
 		return OK(userGame, c, w)
 	}
 
 	return OK(userGame.UserGamePublic, c, w)
 }
+
+// decideUserGame is for admins to verify a pending user-game pair by hand, or
+// to nullify it, recording why, like the worker does with the attempts it
+// sees.
+func (e *Env) decideUserGame(
+	c web.C, w http.ResponseWriter,
+	userGame *models.UserGame, me *models.User, data patchUserGameBody,
+) *Error {
+	if me == nil || !me.Is(models.RoleAdmin) {
+		return &Error{E: utils.ErrUnauthorized}
+	} else if strings.TrimSpace(data.Reason) == "" {
+		return invalid("reason", CodeRequired, "reason is mandatory")
+	}
+
+	err := e.conditionally(c, func(etx *models.Env) error {
+		// the worker may have verified the pair since it was loaded, claiming
+		// identifiers for data that mustn't be overwritten
+		fresh, inerr := etx.LockUserGame(userGame.Id)
+		if inerr != nil {
+			return inerr
+		} else if fresh.VerifiedAt != nil {
+			return &Error{
+				C: http.StatusBadRequest, M: "this user-game pair is already verified",
+			}
+		} else if fresh.NullifiedAt != nil {
+			return &Error{
+				C: http.StatusBadRequest, M: "this user-game pair is nullified",
+			}
+		}
+
+		userGame = fresh
+		now := time.Now()
+		attempt := &models.VerificationAttempt{
+			GameId:     userGame.GameId,
+			UserGameId: &userGame.Id,
+			Token:      userGame.Token,
+			Reason:     &data.Reason,
+			CreatedBy:  &me.Id,
+		}
+		userGame.Token = nil
+		if data.Action == "approve" {
+			attempt.Outcome = models.VerificationApproved
+			userGame.VerifiedAt = &now
+			userGame.DataUpdatedAt = &now
+		} else {
+			attempt.Outcome = models.VerificationRejected
+			userGame.NullifiedAt = &now
+			userGame.NullifiedBy = &me.Id
+		}
+
+		inerr = etx.UpdateUserGame(userGame)
+		if inerr != nil {
+			return inerr
+		}
+
+		return etx.CreateVerificationAttempt(attempt)
+	})
+	if err != nil {
+		apierr, ok := err.(*Error)
+		if ok {
+			return apierr
+		}
+
+		return &Error{E: err}
+	}
+
+	return OK(userGame, c, w)
+}
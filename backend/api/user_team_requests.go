@@ -10,6 +10,11 @@ import (
 	"app/utils"
 )
 
+type postUserTeamRequestBody struct {
+	UserId string `json:"userId"`
+	TeamId string `json:"teamId"`
+}
+
 func (e *Env) PostUserTeamRequest(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -18,25 +23,30 @@ func (e *Env) PostUserTeamRequest(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		UserId string `json:"userId"`
-		TeamId string `json:"teamId"`
-	}
+	var data postUserTeamRequestBody
 	err := Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
+	var v Validation
+	v.Id("userId", data.UserId)
+	v.Id("teamId", data.TeamId)
+	apierr := v.Err()
+	if apierr != nil {
+		return apierr
+	}
+
 	user, err := e.M.GetUserById(data.UserId)
 	if err == utils.ErrNotFound {
-		return &Error{C: http.StatusBadRequest, M: "bad user id"}
+		return invalid("userId", CodeId, "bad user id")
 	} else if err != nil {
 		return &Error{E: err}
 	}
 
 	team, err := e.M.GetTeamById(data.TeamId)
 	if err == utils.ErrNotFound {
-		return &Error{C: http.StatusBadRequest, M: "bad team id"}
+		return invalid("teamId", CodeId, "bad team id")
 	} else if err != nil {
 		return &Error{E: err}
 	} else if team.DisbandedAt != nil {
@@ -95,7 +105,7 @@ func (e *Env) PostUserTeamRequest(
 		}
 
 		teamSeasons, inerr := etx.GetTeamSeasons(models.NewQueryModifier(
-			models.QueryBase{0, 0, map[string]string{"team_id": team.Id}, ""},
+			models.QueryBase{Filter: map[string]string{"team_id": team.Id}},
 			[]string{"team_id"},
 			[]string{},
 		))
@@ -115,7 +125,7 @@ func (e *Env) PostUserTeamRequest(
 			request.AdminDecision = &tmpTrue
 		}
 
-		// even if me.IsAdmin == true, explicit approval is better
+		// even if me.Is(models.RoleAdmin), explicit approval is better
 
 		if session.UserId == user.Id {
 			request.UserDecision = &tmpTrue
@@ -144,7 +154,7 @@ func (e *Env) PostUserTeamRequest(
 	}
 
 	if needAdmin {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
@@ -173,11 +183,11 @@ func (e *Env) GetUserTeamRequest(
 		return &Error{E: err}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
-	if me.IsAdmin || me.Id == request.UserId {
+	if me.Is(models.RoleAdmin) || me.Id == request.UserId {
 		return OK(request, c, w)
 	}
 
@@ -200,18 +210,13 @@ func (e *Env) GetUserTeamRequests(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
@@ -219,13 +224,13 @@ func (e *Env) GetUserTeamRequests(
 	userId, _ := data.Filter["user_id"]
 	teamId, _ := data.Filter["team_id"]
 	if (userId == "") && (teamId == "") {
-		return &Error{
-			C: http.StatusBadRequest, M: "filter by user_id or team_id required",
-		}
+		return invalid(
+			"filter", CodeRequired, "filter by user_id or team_id required",
+		)
 	}
 
 	amRelated := false
-	if me.IsAdmin {
+	if me.Is(models.RoleAdmin) {
 		amRelated = true
 	} else if me.Id == userId {
 		amRelated = true
@@ -240,11 +245,15 @@ func (e *Env) GetUserTeamRequests(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	requests, err := e.M.GetUserTeamRequests(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "user_team_request", data,
 		[]string{"user_id", "team_id", "decision"},
 		[]string{"id", "user_id", "team_id", "created_at"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	requests, err := e.M.GetUserTeamRequests(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
@@ -252,6 +261,10 @@ func (e *Env) GetUserTeamRequests(
 	return OK(requests, c, w)
 }
 
+type patchUserTeamRequestBody struct {
+	Action string
+}
+
 func (e *Env) PatchUserTeamRequest(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -265,9 +278,7 @@ func (e *Env) PatchUserTeamRequest(
 		return &Error{E: err}
 	}
 
-	var data struct {
-		Action string
-	}
+	var data patchUserTeamRequestBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -277,16 +288,16 @@ func (e *Env) PatchUserTeamRequest(
 	if data.Action == "yes" {
 		action = true
 	} else if data.Action != "no" {
-		return &Error{C: http.StatusBadRequest, M: "bad action"}
+		return invalid("action", CodeEnum, "bad action")
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
 
 	now := time.Now()
-	if request.IsAdminNeeded() && me.IsAdmin {
+	if request.IsAdminNeeded() && me.Is(models.RoleAdmin) {
 		request.AdminDecision = &action
 		request.AdminDecidedAt = &now
 		request.AdminDecidedBy = &me.Id
@@ -309,7 +320,7 @@ func (e *Env) PatchUserTeamRequest(
 			request.LeaderDecision = &action
 			request.LeaderDecidedAt = &now
 			request.LeaderDecidedBy = &me.Id
-		} else if !request.IsAdminNeeded() || !me.IsAdmin {
+		} else if !request.IsAdminNeeded() || !me.Is(models.RoleAdmin) {
 			return &Error{
 				E: utils.ErrUnauthorized,
 				M: "you aren't involved in this request",
@@ -20,11 +20,11 @@ func (e *Env) GetUserTeam(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleAdmin) {
 			return OK(userTeam, c, w)
 		}
 
@@ -44,12 +44,7 @@ func (e *Env) GetUserTeams(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
 
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -61,28 +56,32 @@ func (e *Env) GetUserTeams(
 
 	if (userId == "") && (teamId == "") && (requestId == "") {
 		// It is not allowed to provide no filter.
-		return &Error{
-			E: err, C: http.StatusBadRequest,
-			M: "filter by user_id, team_id or request_id required",
-		}
+		return invalid(
+			"filter", CodeRequired,
+			"filter by user_id, team_id or request_id required",
+		)
 	}
 
-	userTeams, err := e.M.GetUserTeams(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
+	modifier, apierr := e.list(c, r, "user_team", data,
 		[]string{"user_id", "team_id", "is_leader", "request_id", "left_at"},
 		[]string{"id", "user_id", "team_id", "is_leader", "created_at", "left_at"},
-	))
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	userTeams, err := e.M.GetUserTeams(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
 		}
-		if me.IsAdmin {
+		if me.Is(models.RoleAdmin) {
 			return OK(userTeams, c, w)
 		}
 	}
@@ -95,6 +94,10 @@ func (e *Env) GetUserTeams(
 	return OK(public, c, w)
 }
 
+type patchUserTeamBody struct {
+	Action string
+}
+
 func (e *Env) PatchUserTeam(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -108,15 +111,13 @@ func (e *Env) PatchUserTeam(
 		return &Error{E: err}
 	}
 
-	var data struct {
-		Action string
-	}
+	var data patchUserTeamBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
 	}
@@ -125,7 +126,7 @@ func (e *Env) PatchUserTeam(
 		if userTeam.UserId != me.Id { // yep, the admin can only kick
 			return &Error{E: utils.ErrUnauthorized}
 		}
-	} else if !me.IsAdmin { // it's okay that this is a "catch-all", I think
+	} else if !me.Is(models.RoleAdmin) { // it's okay as a "catch-all", I think
 		myUserTeam, err := e.M.GetUserTeamByUserTeam(me.Id, userTeam.TeamId)
 		if err == utils.ErrNotFound || (err == nil && !myUserTeam.IsLeader) {
 			return &Error{E: utils.ErrUnauthorized}
@@ -141,14 +142,14 @@ func (e *Env) PatchUserTeam(
 	}
 
 	var isLastLeader bool
-	err = e.M.Atomic(func(etx *models.Env) error {
+	err = e.conditionally(c, func(etx *models.Env) error {
 		if userTeam.IsLeader {
 			userTeams, inerr := etx.GetUserTeams(models.NewQueryModifier(
-				models.QueryBase{0, 0, map[string]string{
+				models.QueryBase{Filter: map[string]string{
 					"team_id":   userTeam.TeamId,
 					"is_leader": "true",
 					"left_at":   "\x00",
-				}, ""},
+				}},
 				[]string{"team_id", "is_leader", "left_at"},
 				[]string{},
 			))
@@ -206,7 +207,7 @@ func (e *Env) PatchUserTeam(
 			userTeam.LeftAt = &now
 			userTeam.KickedBy = &me.Id
 		} else {
-			return &Error{C: http.StatusBadRequest, M: "bad action"}
+			return invalid("action", CodeEnum, "bad action")
 		}
 
 		return etx.UpdateUserTeam(userTeam, me.Id)
@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,27 +15,37 @@ import (
 
 const (
 	pwdMinLen = 8
+
+	// EmailChangeAge is how long the link sent to a new email address stays
+	// valid, the current one is kept until it's followed.
+	EmailChangeAge = time.Hour * 24
+
+	// VerificationResendInterval is how often the verification email can be
+	// sent again, so that nobody uses us for bombarding inboxes.
+	VerificationResendInterval = time.Minute * 5
 )
 
+type postUserBody struct {
+	Email    string
+	Password string
+}
+
 func (e *Env) PostUser(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Email    string
-		Password string
-	}
+	var data postUserBody
 	err := Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 	if len(data.Password) < pwdMinLen {
-		return &Error{C: http.StatusBadRequest, M: "password too short"}
+		return invalid("password", CodeLength, "password too short")
 	}
 
 	data.Email = strings.TrimSpace(data.Email)
 	_, err = e.M.GetUserByEmail(data.Email)
 	if err == nil {
-		return &Error{C: http.StatusBadRequest, M: "email taken"}
+		return invalid("email", CodeTaken, "email taken")
 	}
 	if err != utils.ErrNotFound {
 		return &Error{E: err}
@@ -58,21 +69,7 @@ func (e *Env) PostUser(
 			return &Error{E: inerr}
 		}
 
-		otp, inerr := etx.CreateOTP(user.Id)
-		if inerr != nil {
-			return &Error{E: inerr}
-		}
-
-		inerr = e.Mail.Send(
-			data.Email,
-			"Please verify your email address",
-			"[Verify email address](https://"+e.StaticHost+"/verify/"+otp.Token+")",
-		)
-		if inerr != nil {
-			return &Error{E: inerr}
-		}
-
-		return nil
+		return e.sendVerification(etx, user)
 	})
 	if err != nil {
 		apierr, ok := err.(*Error)
@@ -96,10 +93,15 @@ func (e *Env) GetUser(
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.Id == user.Id || me.IsAdmin {
+		} else if me.Id == user.Id || me.Is(models.RoleAdmin) {
+			user.Roles, err = e.M.GetRolesByUser(user.Id)
+			if err != nil {
+				return &Error{E: err}
+			}
+
 			return OK(user, c, w)
 		}
 	}
@@ -110,33 +112,33 @@ func (e *Env) GetUser(
 func (e *Env) GetUsers(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
-	var data struct {
-		Offset uint64            `param:"offset"`
-		Limit  uint64            `param:"count"`
-		Filter map[string]string `param:"filter"`
-		Sort   string            `param:"sort"`
-	}
+	var data models.QueryBase
 	err := DecodeQuery(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
 	}
 
-	users, err := e.M.GetUsers(models.NewQueryModifier(
-		models.QueryBase{data.Offset, data.Limit, data.Filter, data.Sort},
-		[]string{"is_admin", "is_email_verified"},
-		[]string{"id", "email", "nickname", "fullname", "is_admin",
-			"is_email_verified", "created_at"},
-	))
+	modifier, apierr := e.list(c, r, "\"user\"", data,
+		[]string{"is_email_verified", "nickname:text", "fullname:text"},
+		// only what GetUsers selects, and everyone gets to see, or there would
+		// be nothing to make the next cursor out of
+		[]string{"id", "nickname", "fullname", "is_admin", "created_at"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	users, err := e.M.GetUsers(modifier)
 	if err != nil {
 		return &Error{E: err}
 	}
 
 	session, ok := c.Env["session"].(*models.Session)
 	if ok {
-		me, err := e.M.GetUserById(session.UserId)
+		me, err := e.me(c, session)
 		if err != nil {
 			return &Error{E: err, C: http.StatusInternalServerError}
-		} else if me.IsAdmin {
+		} else if me.Is(models.RoleAdmin) {
 			return OK(users, c, w)
 		}
 	}
@@ -149,6 +151,15 @@ func (e *Env) GetUsers(
 	return OK(public, c, w)
 }
 
+type putUserBody struct {
+	Email         *string
+	Password      *string
+	Nickname      *string
+	Fullname      *string
+	GravatarEmail *string
+	IsAdmin       *bool
+}
+
 func (e *Env) PutUser(
 	c web.C, w http.ResponseWriter, r *http.Request,
 ) *Error {
@@ -157,10 +168,10 @@ func (e *Env) PutUser(
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
-	me, err := e.M.GetUserById(session.UserId)
+	me, err := e.me(c, session)
 	if err != nil {
 		return &Error{E: err, C: http.StatusInternalServerError}
-	} else if c.URLParams["id"] != session.UserId && !me.IsAdmin {
+	} else if c.URLParams["id"] != session.UserId && !me.Is(models.RoleAdmin) {
 		return &Error{E: utils.ErrUnauthorized}
 	}
 
@@ -169,14 +180,7 @@ func (e *Env) PutUser(
 		return &Error{E: err}
 	}
 
-	var data struct {
-		Email         *string
-		Password      *string
-		Nickname      *string
-		Fullname      *string
-		GravatarEmail *string
-		IsAdmin       *bool
-	}
+	var data putUserBody
 	err = Decode(r, &data)
 	if err != nil {
 		return &Error{E: err, C: http.StatusBadRequest}
@@ -190,28 +194,26 @@ func (e *Env) PutUser(
 	}
 
 	somethingChanged := false
-	emailChanged := false
+	var newEmail *string
+	isAdminChanged := false
 
 	if data.Email != nil {
 		*data.Email = strings.TrimSpace(*data.Email)
 		if *data.Email != user.Email {
 			_, err = e.M.GetUserByEmail(*data.Email)
 			if err == nil {
-				return &Error{C: http.StatusBadRequest, M: "email taken"}
+				return invalid("email", CodeTaken, "email taken")
 			} else if err != utils.ErrNotFound {
 				return &Error{E: err}
 			}
 
-			user.Email = *data.Email
-			user.IsEmailVerified = false
-			somethingChanged = true
-			emailChanged = true
+			newEmail = data.Email
 		}
 	}
 
 	if data.Password != nil {
 		if len(*data.Password) < pwdMinLen {
-			return &Error{C: http.StatusBadRequest, M: "password too short"}
+			return invalid("password", CodeLength, "password too short")
 		}
 
 		password, err := scrypt.GenerateFromPassword(
@@ -230,7 +232,7 @@ func (e *Env) PutUser(
 		*data.Nickname = strings.TrimSpace(*data.Nickname)
 		if *data.Nickname != user.Nickname {
 			if len(*data.Nickname) == 0 { // redundant, but explicit
-				return &Error{C: http.StatusBadRequest, M: "nickname too short"}
+				return invalid("nickname", CodeLength, "nickname too short")
 			}
 
 			user.Nickname = *data.Nickname
@@ -255,35 +257,81 @@ func (e *Env) PutUser(
 	}
 
 	if data.IsAdmin != nil && *data.IsAdmin != user.IsAdmin {
-		if !me.IsAdmin {
+		if !me.Is(models.RoleAdmin) {
 			return &Error{E: utils.ErrUnauthorized}
 		}
 
 		user.IsAdmin = *data.IsAdmin
 		somethingChanged = true
+		isAdminChanged = true
 	}
 
-	if !somethingChanged {
+	if !somethingChanged && newEmail == nil {
 		return OK(user, c, w)
 	}
 
-	err = e.M.Atomic(func(etx *models.Env) error {
-		inerr := etx.UpdateUser(user, me.Id)
+	err = e.conditionally(c, func(etx *models.Env) error {
+		// isAdmin is just a shorthand for the global admin role by now
+		if isAdminChanged && user.IsAdmin {
+			inerr := etx.CreateRole(&models.Role{
+				UserId: user.Id, Name: models.RoleAdmin, CreatedBy: &me.Id,
+			})
+			if inerr != nil {
+				return &Error{E: inerr}
+			}
+		} else if isAdminChanged {
+			role, inerr := etx.GetRoleByUserNameScope(
+				user.Id, models.RoleAdmin, nil, nil,
+			)
+			if inerr == nil {
+				inerr = etx.DeleteRole(role)
+			}
+
+			if inerr != nil {
+				return &Error{E: inerr}
+			}
+		}
+
+		if somethingChanged {
+			inerr := etx.UpdateUser(user, me.Id)
+			if inerr != nil {
+				return &Error{E: inerr}
+			}
+		}
+
+		if newEmail == nil {
+			return nil
+		}
+
+		// The address isn't swapped until the new one is confirmed, otherwise a
+		// typo (or a hijacked session) would lock the owner out of the account.
+		inerr := etx.DeleteOTPsByPurpose(user.Id, models.OTPEmail)
+		if inerr != nil {
+			return &Error{E: inerr}
+		}
+
+		otp, inerr := etx.CreateOTP(user.Id, models.OTPEmail, newEmail)
 		if inerr != nil {
 			return &Error{E: inerr}
-		} else if !emailChanged {
-			return nil
 		}
 
-		otp, inerr := etx.CreateOTP(user.Id)
+		inerr = e.Mail.Send(
+			*newEmail,
+			"Please confirm your new email address",
+			"[Confirm email address](https://"+e.StaticHost+"/verify/"+
+				otp.Token+")",
+		)
 		if inerr != nil {
 			return &Error{E: inerr}
 		}
 
 		inerr = e.Mail.Send(
 			user.Email,
-			"Please verify your email address",
-			"[Verify email address](https://"+e.StaticHost+"/verify/"+otp.Token+")",
+			"Your email address is about to change",
+			"Somebody, hopefully you, has asked to change the email address of "+
+				"your account to "+*newEmail+". It won't change until the link "+
+				"sent there is followed. If it wasn't you, change your password "+
+				"and contact support@auzom.gg.",
 		)
 		if inerr != nil {
 			return &Error{E: inerr}
@@ -302,3 +350,250 @@ func (e *Env) PutUser(
 
 	return OK(user, c, w)
 }
+
+func (e *Env) sendVerification(eM *models.Env, user *models.User) error {
+	otp, err := eM.CreateOTP(user.Id, models.OTPVerify, nil)
+	if err != nil {
+		return err
+	}
+
+	return e.Mail.Send(
+		user.Email,
+		"Please verify your email address",
+		"[Verify email address](https://"+e.StaticHost+"/verify/"+otp.Token+")",
+	)
+}
+
+// PostUserVerification sends the verification email once more, in case the
+// first one got lost.
+func (e *Env) PostUserVerification(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	user, err := e.M.GetUserById(c.URLParams["id"])
+	if err != nil {
+		return &Error{E: err}
+	} else if user.Id != me.Id && !me.Is(models.RoleAdmin) {
+		return &Error{E: utils.ErrUnauthorized}
+	} else if user.IsEmailVerified {
+		return &Error{C: http.StatusBadRequest, M: "already verified"}
+	}
+
+	latest, err := e.M.GetLatestOTP(user.Id, models.OTPVerify)
+	if err == nil {
+		if latest.CreatedAt.Add(VerificationResendInterval).After(time.Now()) {
+			w.Header().Set("Retry-After", strconv.Itoa(
+				int(VerificationResendInterval.Seconds()),
+			))
+			return &Error{
+				C: http.StatusTooManyRequests,
+				M: "verification email has just been sent, please, check your " +
+					"inbox, or try again in a few minutes",
+			}
+		}
+	} else if err != utils.ErrNotFound {
+		return &Error{E: err}
+	}
+
+	err = e.sendVerification(e.M, user)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return NoContent(c, w)
+}
+
+type userExport struct {
+	User               *models.User                 `json:"user"`
+	Sessions           []models.Session             `json:"sessions"`
+	Identities         []models.Identity            `json:"identities"`
+	ApiTokens          []models.ApiToken            `json:"apiTokens"`
+	Roles              []models.Role                `json:"roles"`
+	UserGames          []models.UserGame            `json:"userGames"`
+	Attempts           []models.VerificationAttempt `json:"verificationAttempts"`
+	AccountAlerts      []models.AccountAlert        `json:"accountAlerts"`
+	UserTeams          []models.UserTeam            `json:"userTeams"`
+	Teams              []models.Team                `json:"teams"`
+	UserTeamRequests   []models.UserTeamRequest     `json:"userTeamRequests"`
+	TeamSeasonRequests []models.TeamSeasonRequest   `json:"teamSeasonRequests"`
+	Comments           []models.Comment             `json:"comments"`
+	AttentionRequests  []models.AttentionRequest    `json:"attentionRequests"`
+	MatchReports       []models.MatchReport         `json:"matchReports"`
+}
+
+func byUser(column, userId string) *models.QueryModifier {
+	return models.NewQueryModifier(
+		models.QueryBase{Filter: map[string]string{column: userId}, Sort: "id"},
+		[]string{column}, []string{"id"},
+	)
+}
+
+// GetUserExport gathers everything tied to the user into a single archive,
+// as required by GDPR, article 20.
+func (e *Env) GetUserExport(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	user, err := e.M.GetUserById(c.URLParams["id"])
+	if err != nil {
+		return &Error{E: err}
+	} else if user.Id != me.Id && !me.Is(models.RoleAdmin) {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	export := userExport{User: user}
+	export.Sessions, err = e.M.GetSessionsByUser(user.Id)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.Identities, err = e.M.GetIdentities(byUser("user_id", user.Id))
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.ApiTokens, err = e.M.GetApiTokens(byUser("user_id", user.Id))
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.Roles, err = e.M.GetRolesByUser(user.Id)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.UserGames, err = e.M.GetUserGames(byUser("user_id", user.Id))
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.Attempts, err = e.M.GetVerificationAttemptsByUser(user.Id)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.AccountAlerts, err = e.M.GetAccountAlerts(
+		byUser("user_id", user.Id),
+	)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.UserTeams, err = e.M.GetUserTeams(byUser("user_id", user.Id))
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.Teams, err = e.M.GetTeams(byUser("created_by", user.Id))
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.UserTeamRequests, err = e.M.GetUserTeamRequests(
+		byUser("user_id", user.Id),
+	)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.TeamSeasonRequests, err = e.M.GetTeamSeasonRequests(
+		byUser("created_by", user.Id),
+	)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.Comments, err = e.M.GetComments(byUser("created_by", user.Id))
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.AttentionRequests, err = e.M.GetAttentionRequests(
+		byUser("created_by", user.Id),
+	)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	export.MatchReports, err = e.M.GetMatchReports(byUser("created_by", user.Id))
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	w.Header().Set(
+		"Content-Disposition", "attachment; filename=\"auzom-"+user.Id+".json\"",
+	)
+	return OK(export, c, w)
+}
+
+// DeleteUser anonymises the account, see models.DeleteUser. Comments, reports
+// and the like stay where they are, attributed to a deleted user.
+func (e *Env) DeleteUser(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	session, ok := c.Env["session"].(*models.Session)
+	if !ok {
+		return &Error{E: utils.ErrUnauthorized}
+	}
+
+	me, err := e.me(c, session)
+	if err != nil {
+		return &Error{E: err, C: http.StatusInternalServerError}
+	}
+
+	user, err := e.M.GetUserById(c.URLParams["id"])
+	if err != nil {
+		return &Error{E: err}
+	} else if user.Id != me.Id && !me.Is(models.RoleAdmin) {
+		return &Error{E: utils.ErrUnauthorized}
+	} else if user.DeletedAt != nil {
+		return &Error{C: http.StatusBadRequest, M: "already deleted"}
+	} else if user.Id == me.Id &&
+		session.CreatedAt.Add(SessionAgeSensitive).Before(time.Now()) {
+		return &Error{
+			E: utils.ErrUnauthorized,
+			M: "please, re-login, so you can delete your account",
+		}
+	}
+
+	leaderships, err := e.M.GetUserTeams(&models.QueryModifier{
+		Filter: map[string]interface{}{
+			"user_id": user.Id, "is_leader": true, "left_at": nil,
+		},
+	})
+	if err != nil {
+		return &Error{E: err}
+	} else if len(leaderships) > 0 {
+		return &Error{
+			C: http.StatusBadRequest,
+			M: "hand the leadership of your teams over, or disband them, first",
+		}
+	}
+
+	err = e.M.Atomic(func(etx *models.Env) error {
+		return etx.DeleteUser(user, me.Id)
+	})
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(user, c, w)
+}
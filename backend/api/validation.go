@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Problem codes, for clients to tell what's wrong without parsing messages.
+const (
+	CodeRequired = "required" // missing or empty
+	CodeLength   = "length"   // too short or too long, strings and lists
+	CodeRange    = "range"    // a number or a time out of bounds
+	CodeEnum     = "enum"     // not one of the accepted values
+	CodeId       = "id"       // not an id, or not one of an existing row
+	CodeOrder    = "order"    // a time that has to come before another doesn't
+	CodeFormat   = "format"   // can't be parsed
+	CodeTaken    = "taken"    // has to be unique, and isn't
+)
+
+// maxInt is as much as integer columns fit, for Range to go up to when there's
+// no other limit.
+const maxInt = math.MaxInt32
+
+// Problem is a single thing wrong with a request. Field is a path into the
+// body or the query, like "rounds[2].gameMapId" or "filter[match_id]", empty
+// if it's the request as a whole.
+type Problem struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Validation collects problems, so that all of them are reported at once. The
+// checks report whether the value passed.
+type Validation []Problem
+
+func (v *Validation) Add(field, code, message string) {
+	*v = append(*v, Problem{field, code, message})
+}
+
+func (v *Validation) Required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, CodeRequired, field+" is mandatory")
+		return false
+	}
+
+	return true
+}
+
+// Length counts characters, not bytes.
+func (v *Validation) Length(field, value string, min, max int) bool {
+	n := utf8.RuneCountInString(value)
+	if n < min || n > max {
+		v.Add(field, CodeLength, fmt.Sprintf(
+			"%s has to be %d to %d characters long", field, min, max,
+		))
+		return false
+	}
+
+	return true
+}
+
+func (v *Validation) Range(field string, value, min, max int) bool {
+	if value < min || value > max {
+		v.Add(field, CodeRange, fmt.Sprintf(
+			"%s has to be in [%d, %d]", field, min, max,
+		))
+		return false
+	}
+
+	return true
+}
+
+func (v *Validation) Enum(field, value string, accepted ...string) bool {
+	for _, a := range accepted {
+		if value == a {
+			return true
+		}
+	}
+
+	v.Add(field, CodeEnum, fmt.Sprintf(
+		"%s has to be one of %s", field, strings.Join(accepted, ", "),
+	))
+	return false
+}
+
+// Id checks that value looks like a row id, which are all serials.
+func (v *Validation) Id(field, value string) bool {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		v.Add(field, CodeId, "bad "+field)
+		return false
+	}
+
+	return true
+}
+
+// Ordered checks that before comes before after, if both are there.
+func (v *Validation) Ordered(
+	beforeField string, before *time.Time, afterField string, after *time.Time,
+) bool {
+	if before != nil && after != nil && !before.Before(*after) {
+		v.Add(afterField, CodeOrder, afterField+" has to be after "+beforeField)
+		return false
+	}
+
+	return true
+}
+
+// Err is nil if there are no problems, and a 400 listing them otherwise.
+func (v Validation) Err() *Error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(v))
+	for _, p := range v {
+		messages = append(messages, p.Message)
+	}
+
+	return &Error{
+		C: http.StatusBadRequest, M: strings.Join(messages, "; "), P: v,
+	}
+}
+
+// invalid is a shorthand for a single problem.
+func invalid(field, code, message string) *Error {
+	return Validation{{field, code, message}}.Err()
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/zenazn/goji/web"
+
+	"app/models"
+)
+
+// GetVerificationAttempts is for admins only, since attempts have IPs in
+// them, among other things.
+func (e *Env) GetVerificationAttempts(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *Error {
+	_, apierr := e.Admin(c)
+	if apierr != nil {
+		return apierr
+	}
+
+	var data models.QueryBase
+	err := DecodeQuery(r, &data)
+	if err != nil {
+		return &Error{E: err, C: http.StatusBadRequest}
+	}
+
+	if data.Sort == "" {
+		data.Sort = "-id"
+	}
+
+	modifier, apierr := e.list(c, r, "verification_attempt", data,
+		[]string{
+			"game_id", "user_game_id", "name:text", "token", "outcome",
+			"created_at", "created_by",
+		},
+		[]string{"id", "created_at"},
+	)
+	if apierr != nil {
+		return apierr
+	}
+
+	attempts, err := e.M.GetVerificationAttempts(modifier)
+	if err != nil {
+		return &Error{E: err}
+	}
+
+	return OK(attempts, c, w)
+}
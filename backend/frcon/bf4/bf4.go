@@ -0,0 +1,309 @@
+// Package bf4 speaks Battlefield 4's dialect of Frostbite RCON, on top of an
+// frcon session, so that nobody has to count words by hand.
+package bf4
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"app/frcon"
+)
+
+var ErrBadResponse = errors.New("bad response")
+
+// Error is a response other than OK, like InvalidPlayerName.
+type Error struct {
+	Command string
+	Status  string
+}
+
+func (err *Error) Error() string {
+	return "bf4: " + err.Command + ": " + err.Status
+}
+
+// Server is a game server on the other end of a session.
+type Server struct {
+	s *frcon.Session
+}
+
+func New(s *frcon.Session) *Server {
+	return &Server{s}
+}
+
+func (srv *Server) Session() *frcon.Session {
+	return srv.s
+}
+
+// request sends words and makes sure the response is OK, returning the rest
+// of it. Public commands don't wait for the login procedure.
+func (srv *Server) request(public bool, words ...string) ([]string, error) {
+	var resp []string
+	var err error
+	if public {
+		resp, err = srv.s.RequestPublic(words)
+	} else {
+		resp, err = srv.s.Request(words)
+	}
+
+	if err != nil {
+		return nil, err
+	} else if len(resp) == 0 {
+		return nil, ErrBadResponse
+	} else if resp[0] != "OK" {
+		return nil, &Error{words[0], resp[0]}
+	}
+
+	return resp[1:], nil
+}
+
+// PlayerInfo is a row of listPlayers.
+type PlayerInfo struct {
+	Name    string
+	GUID    string
+	TeamId  int
+	SquadId int
+	Kills   int
+	Deaths  int
+	Score   int
+	Rank    int
+	Ping    int
+	Type    int // 0 is a player, the rest are spectators and commanders
+}
+
+// ListPlayers lists everyone on the server.
+func (srv *Server) ListPlayers() ([]PlayerInfo, error) {
+	resp, err := srv.request(true, "listPlayers", "all")
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePlayerInfo(resp)
+}
+
+// parsePlayerInfo reads a player info block, which is the number of columns,
+// their names, the number of rows, and the rows themselves, all flattened.
+func parsePlayerInfo(words []string) ([]PlayerInfo, error) {
+	if len(words) < 1 {
+		return nil, ErrBadResponse
+	}
+
+	// counts are checked against len(words) first, so that nothing overflows
+	numColumns, err := strconv.Atoi(words[0])
+	if err != nil || numColumns < 1 || numColumns > len(words) ||
+		len(words) < 2+numColumns {
+		return nil, ErrBadResponse
+	}
+
+	columns := words[1 : 1+numColumns]
+	numRows, err := strconv.Atoi(words[1+numColumns])
+	rows := words[2+numColumns:]
+	if err != nil || numRows < 0 || numRows > len(rows) ||
+		len(rows) != numRows*numColumns {
+		return nil, ErrBadResponse
+	}
+
+	players := make([]PlayerInfo, 0, numRows)
+	for ; len(rows) > 0; rows = rows[numColumns:] {
+		var p PlayerInfo
+		ints := map[string]*int{
+			"teamId": &p.TeamId, "squadId": &p.SquadId, "kills": &p.Kills,
+			"deaths": &p.Deaths, "score": &p.Score, "rank": &p.Rank,
+			"ping": &p.Ping, "type": &p.Type,
+		}
+		for i, column := range columns {
+			if column == "name" {
+				p.Name = rows[i]
+			} else if column == "guid" {
+				p.GUID = rows[i]
+			} else if x, ok := ints[column]; ok {
+				// ping is garbage while joining, so don't be strict about it
+				*x, _ = strconv.Atoi(rows[i])
+			}
+		}
+
+		players = append(players, p)
+	}
+
+	return players, nil
+}
+
+// KickPlayer kicks a player by name, showing them the reason.
+func (srv *Server) KickPlayer(name, reason string) error {
+	_, err := srv.request(false, "admin.kickPlayer", name, reason)
+	return err
+}
+
+// Say shows a message in the chat to a subset of players, which is all of
+// them by default, or, for instance, "player", name.
+func (srv *Server) Say(message string, subset ...string) error {
+	if len(subset) == 0 {
+		subset = []string{"all"}
+	}
+
+	_, err := srv.request(
+		false, append([]string{"admin.say", message}, subset...)...,
+	)
+	return err
+}
+
+// SayToPlayer is Say to a single player.
+func (srv *Server) SayToPlayer(name, message string) error {
+	return srv.Say(message, "player", name)
+}
+
+// Ban bans by name, ip or guid, for d rounded down to seconds, or forever if
+// it's zero, and saves the ban list.
+func (srv *Server) Ban(
+	idType, id string, d time.Duration, reason string,
+) error {
+	timeout := []string{"perm"}
+	if d > 0 {
+		timeout = []string{"seconds", strconv.Itoa(int(d / time.Second))}
+	}
+
+	words := append([]string{"banList.add", idType, id}, timeout...)
+	_, err := srv.request(false, append(words, reason)...)
+	if err != nil {
+		return err
+	}
+
+	_, err = srv.request(false, "banList.save")
+	return err
+}
+
+// ServerInfo is the part of serverInfo that all versions of the game agree on.
+type ServerInfo struct {
+	Name            string
+	Players         int
+	MaxPlayers      int
+	GameMode        string
+	Map             string
+	RoundsPlayed    int
+	RoundsTotal     int
+	TeamScores      []int
+	TargetScore     int
+	OnlineState     string
+	Ranked          bool
+	PunkBuster      bool
+	HasGamePassword bool
+	UpTime          time.Duration
+	RoundTime       time.Duration
+}
+
+func (srv *Server) ServerInfo() (*ServerInfo, error) {
+	resp, err := srv.request(true, "serverInfo")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseServerInfo(resp)
+}
+
+func parseServerInfo(words []string) (*ServerInfo, error) {
+	w := &wordReader{words: words}
+	info := &ServerInfo{
+		Name:         w.string(),
+		Players:      w.int(),
+		MaxPlayers:   w.int(),
+		GameMode:     w.string(),
+		Map:          w.string(),
+		RoundsPlayed: w.int(),
+		RoundsTotal:  w.int(),
+	}
+
+	numTeams := w.int()
+	for i := 0; i < numTeams && w.err == nil; i++ {
+		info.TeamScores = append(info.TeamScores, w.int())
+	}
+
+	info.TargetScore = w.int()
+	info.OnlineState = w.string()
+	info.Ranked = w.bool()
+	info.PunkBuster = w.bool()
+	info.HasGamePassword = w.bool()
+	info.UpTime = time.Duration(w.int()) * time.Second
+	info.RoundTime = time.Duration(w.int()) * time.Second
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	return info, nil
+}
+
+// MapListEntry is a map in the rotation.
+type MapListEntry struct {
+	Map      string
+	GameMode string
+	Rounds   int
+}
+
+func (srv *Server) MapList() ([]MapListEntry, error) {
+	resp, err := srv.request(false, "mapList.list")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseMapList(resp)
+}
+
+// parseMapList reads the number of maps and of words per map, followed by
+// the maps, each of which has at least three words.
+func parseMapList(words []string) ([]MapListEntry, error) {
+	w := &wordReader{words: words}
+	numMaps := w.int()
+	numWords := w.int()
+	if w.err != nil || numMaps < 0 || numMaps > len(words) || numWords < 3 ||
+		numWords > len(words) || len(w.words) != numMaps*numWords {
+		return nil, ErrBadResponse
+	}
+
+	maps := make([]MapListEntry, 0, numMaps)
+	for i := 0; i < numMaps; i++ {
+		maps = append(maps, MapListEntry{
+			Map:      w.string(),
+			GameMode: w.string(),
+			Rounds:   w.int(),
+		})
+		w.words = w.words[numWords-3:]
+	}
+
+	return maps, w.err
+}
+
+// wordReader takes words off the front, remembering the first thing that
+// went wrong, so that parsers don't have to check every single one.
+type wordReader struct {
+	words []string
+	err   error
+}
+
+func (w *wordReader) string() string {
+	if len(w.words) == 0 {
+		w.err = ErrBadResponse
+		return ""
+	}
+
+	word := w.words[0]
+	w.words = w.words[1:]
+	return word
+}
+
+func (w *wordReader) int() int {
+	word := w.string()
+	if w.err != nil {
+		return 0
+	}
+
+	// scores are floats in the wild, even though they're always whole
+	x, err := strconv.ParseFloat(word, 64)
+	if err != nil {
+		w.err = ErrBadResponse
+	}
+
+	return int(x)
+}
+
+func (w *wordReader) bool() bool {
+	return w.string() == "true"
+}
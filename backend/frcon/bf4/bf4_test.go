@@ -0,0 +1,73 @@
+package bf4
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const maxInt = int(^uint(0) >> 1)
+
+func TestParsePlayerInfo(t *testing.T) {
+	players, err := parsePlayerInfo(strings.Fields(
+		"3 name guid ping 2 nelly EA_1 30 kora EA_2 65535",
+	))
+	expected := []PlayerInfo{
+		{Name: "nelly", GUID: "EA_1", Ping: 30},
+		{Name: "kora", GUID: "EA_2", Ping: 65535},
+	}
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(players, expected) {
+		t.Errorf("got %+v, expected %+v", players, expected)
+	}
+
+	bad := [][]string{
+		{},
+		{"0", "0"},
+		{"x", "name", "0"},
+		{"2", "name", "0"},
+		{"1", "name", "2", "nelly"},
+		{"1", "name", "-1"},
+		{strconv.Itoa(maxInt), "name", "0"},
+		{"2", "name", "guid", strconv.Itoa(maxInt/2 + 1)},
+		{"2", "name", "guid", strconv.Itoa(maxInt)},
+	}
+	for _, words := range bad {
+		_, err := parsePlayerInfo(words)
+		if err != ErrBadResponse {
+			t.Errorf("%q: got %v, expected ErrBadResponse", words, err)
+		}
+	}
+}
+
+func TestParseMapList(t *testing.T) {
+	maps, err := parseMapList(strings.Fields(
+		"2 3 MP_Abandoned ConquestLarge0 2 MP_Damage RushLarge0 1",
+	))
+	expected := []MapListEntry{
+		{"MP_Abandoned", "ConquestLarge0", 2}, {"MP_Damage", "RushLarge0", 1},
+	}
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(maps, expected) {
+		t.Errorf("got %+v, expected %+v", maps, expected)
+	}
+
+	bad := [][]string{
+		{},
+		{"1", "2", "MP_Abandoned", "ConquestLarge0"},
+		{"1", "3", "MP_Abandoned", "ConquestLarge0"},
+		{"-1", "3"},
+		{strconv.Itoa(maxInt), "3"},
+		{"3", strconv.Itoa(maxInt/3 + 1)},
+		{strconv.Itoa(maxInt/3 + 1), "3", "x", "y", "z"},
+	}
+	for _, words := range bad {
+		_, err := parseMapList(words)
+		if err != ErrBadResponse {
+			t.Errorf("%q: got %v, expected ErrBadResponse", words, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package bf4
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrBadEvent = errors.New("bad event")
+
+// PlayerJoin is player.onJoin, sent as soon as someone connects, before they
+// even spawn.
+type PlayerJoin struct {
+	Name string
+	GUID string
+}
+
+// PlayerLeave is player.onLeave.
+type PlayerLeave struct {
+	Name string
+}
+
+// Chat is player.onChat, where Subset is who the message is for, as in "all",
+// or "team", 1.
+type Chat struct {
+	Name   string
+	Text   string
+	Subset []string
+}
+
+// PunkBusterGUID is a punkBuster.onMessage telling the GUID that PunkBuster
+// has computed for a player, which comes some time after they join.
+type PunkBusterGUID struct {
+	Name string
+	GUID string
+	IP   string
+}
+
+// LevelLoaded is server.onLevelLoaded, after which the players have to load
+// the level too, rejoining in the process.
+type LevelLoaded struct {
+	Map          string
+	GameMode     string
+	RoundsPlayed int
+	RoundsTotal  int
+}
+
+const pbGUIDComputed = "PunkBuster Server: Player GUID Computed"
+
+// ParseEvent decodes the words of an event into one of the types above. Other
+// events, including the rest of PunkBuster's chatter, are nil with no error.
+func ParseEvent(words []string) (interface{}, error) {
+	if len(words) == 0 {
+		return nil, ErrBadEvent
+	}
+
+	args := words[1:]
+	switch words[0] {
+	case "player.onJoin":
+		if len(args) != 2 {
+			return nil, ErrBadEvent
+		}
+
+		return PlayerJoin{Name: args[0], GUID: args[1]}, nil
+	case "player.onLeave":
+		if len(args) < 1 {
+			return nil, ErrBadEvent
+		}
+
+		return PlayerLeave{Name: args[0]}, nil
+	case "player.onChat":
+		if len(args) < 3 {
+			return nil, ErrBadEvent
+		}
+
+		return Chat{Name: args[0], Text: args[1], Subset: args[2:]}, nil
+	case "punkBuster.onMessage":
+		if len(args) != 1 {
+			return nil, ErrBadEvent
+		} else if !strings.HasPrefix(args[0], pbGUIDComputed) {
+			return nil, nil
+		}
+
+		return parsePunkBusterGUID(args[0])
+	case "server.onLevelLoaded":
+		if len(args) != 4 {
+			return nil, ErrBadEvent
+		}
+
+		roundsPlayed, err := strconv.Atoi(args[2])
+		if err != nil {
+			return nil, ErrBadEvent
+		}
+
+		roundsTotal, err := strconv.Atoi(args[3])
+		if err != nil {
+			return nil, ErrBadEvent
+		}
+
+		return LevelLoaded{args[0], args[1], roundsPlayed, roundsTotal}, nil
+	}
+
+	return nil, nil
+}
+
+// parsePunkBusterGUID reads messages like "PunkBuster Server: Player GUID
+// Computed 0123456789abcdef0123456789abcdef(-) (slot #1) 127.0.0.1:3659 Name",
+// where the GUID is followed by (-) if it's valid, and the name by a newline.
+func parsePunkBusterGUID(message string) (interface{}, error) {
+	// SplitN instead of Split so that nicknames with spaces, which is possible
+	// on consoles, don't break everything.
+	parts := strings.SplitN(message, " ", 10)
+	if len(parts) < 10 || len(parts[5]) != 35 || len(parts[9]) == 0 {
+		return nil, ErrBadEvent
+	}
+
+	return PunkBusterGUID{
+		Name: strings.TrimSuffix(parts[9], "\n"),
+		GUID: parts[5][:32],
+		IP:   strings.Split(parts[8], ":")[0],
+	}, nil
+}
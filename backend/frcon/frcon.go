@@ -1,9 +1,8 @@
 package frcon
 
 import (
-	"bytes"
+	"context"
 	"crypto/md5"
-	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"io"
@@ -11,17 +10,9 @@ import (
 	"strings"
 	"sync"
 	"time"
-
 	// log "github.com/Sirupsen/logrus"
-	"github.com/djherbis/buffer"
 )
 
-// TODO: implement proper logging, with levels
-// TODO: add timeout / sleep customization
-// TODO: make auto-login and auto-events-enabling opt-outable
-// TODO: document the API
-// TODO: move as much code as possible into pure functions, so it can be tested
-// TODO: implement a mock game server
 // TODO: test for memory leaks and race conditions, including after closing
 
 const (
@@ -32,12 +23,87 @@ const (
 	requestTimeout  = 10 * time.Second
 	callbackTimeout = time.Second
 	emitTimeout     = 30 * time.Second
-	maxSize         = 0x4000     // 16384
-	isClientBit     = 0x80000000 // 0 = server, 1 = client
-	isResponseBit   = 0x40000000 // 0 = request, 1 = response
-	seqNumMask      = 0x3FFFFFFF // this is also the max, thanks to lucky alignment
 )
 
+// Options tune a session. Zero values stand for the defaults above.
+type Options struct {
+	DialTimeout    time.Duration
+	ReadTimeout    time.Duration // how often to check whether we're done
+	WriteTimeout   time.Duration
+	RequestTimeout time.Duration // for Request and logging in
+	// CallbackTimeout is how long a response waits for whoever requested it,
+	// and EmitTimeout is the same for an event and whoever reads them.
+	CallbackTimeout time.Duration
+	EmitTimeout     time.Duration
+	// Backoff tells how long to wait before retrying to connect or to log in
+	// after failing attempt times in a row. The default is ConstantBackoff.
+	Backoff func(attempt int) time.Duration
+	// DisableAutoLogin leaves logging in to the caller, with RequestContext,
+	// in which case Request doesn't wait for it.
+	DisableAutoLogin bool
+	// DisableAutoEvents skips admin.eventsEnabled after logging in.
+	DisableAutoEvents bool
+	Logger            Logger
+}
+
+// Logger is satisfied by both the standard library's and logrus' loggers.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
+
+// ConstantBackoff waits the same amount of time between every attempt.
+func ConstantBackoff(d time.Duration) func(int) time.Duration {
+	return func(int) time.Duration { return d }
+}
+
+// ExponentialBackoff doubles the wait after every attempt, starting from min
+// and going up to max.
+func ExponentialBackoff(min, max time.Duration) func(int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := min
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+
+		if d > max {
+			return max
+		}
+
+		return d
+	}
+}
+
+func (opts *Options) setDefaults() {
+	durations := []struct {
+		d   *time.Duration
+		def time.Duration
+	}{
+		{&opts.DialTimeout, dialTimeout},
+		{&opts.ReadTimeout, readTimeout},
+		{&opts.WriteTimeout, writeTimeout},
+		{&opts.RequestTimeout, requestTimeout},
+		{&opts.CallbackTimeout, callbackTimeout},
+		{&opts.EmitTimeout, emitTimeout},
+	}
+	for _, x := range durations {
+		if *x.d == 0 {
+			*x.d = x.def
+		}
+	}
+
+	if opts.Backoff == nil {
+		opts.Backoff = ConstantBackoff(retrySleep)
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = nopLogger{}
+	}
+}
+
 var (
 	ErrBadSequence    = errors.New("bad sequence number")
 	ErrDone           = errors.New("done")
@@ -49,11 +115,13 @@ var (
 )
 
 type timeoutConn struct {
-	netconn net.Conn
+	netconn      net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
 }
 
 func (conn timeoutConn) Read(b []byte) (int, error) {
-	conn.netconn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.netconn.SetReadDeadline(time.Now().Add(conn.readTimeout))
 	n, err := conn.netconn.Read(b)
 	if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
 		return n, errTimeout
@@ -63,7 +131,7 @@ func (conn timeoutConn) Read(b []byte) (int, error) {
 }
 
 func (conn timeoutConn) Write(b []byte) (int, error) {
-	conn.netconn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	conn.netconn.SetWriteDeadline(time.Now().Add(conn.writeTimeout))
 	n, err := conn.netconn.Write(b)
 	if neterr, ok := err.(net.Error); ok && neterr.Timeout() {
 		return n, errTimeout
@@ -87,21 +155,45 @@ const (
 	EBadRead      EventType = iota
 )
 
+var eventTypeNames = map[EventType]string{
+	EWords:        "words",
+	EConnected:    "connected",
+	ELoggedIn:     "logged_in",
+	EDisconnected: "disconnected",
+	EBadPassword:  "bad_password",
+	EBadRead:      "bad_read",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Event is something that happened to the session, or, with EWords, an event
+// that the game server sent.
 type Event struct {
 	Type      EventType
 	Timestamp time.Time
 	Words     []string
 }
 
+// Session is a connection to a game server that's kept alive, and logged in,
+// until Close, however many times it breaks in the meantime.
 type Session struct {
 	host       string
 	password   string
+	opts       Options
 	conn       chan timeoutConn
 	writeMutex sync.Mutex
 	badconn    chan timeoutConn
 	loggedin   chan struct{}
 	relogin    chan struct{}
 	done       chan struct{}
+	closeOnce  sync.Once
+	wg         sync.WaitGroup
 	seqNumIter int
 	seqMutex   sync.Mutex
 	callbacks  map[int]chan []string
@@ -109,10 +201,46 @@ type Session struct {
 	events     chan Event
 }
 
+// Dial starts a session with the default options, without waiting for it to
+// connect. The events channel is closed after Close.
 func Dial(host, password string) (*Session, chan Event) {
+	return start(host, password, Options{})
+}
+
+// DialContext starts a session, and waits for it to connect, and to log in,
+// unless that's disabled. If ctx is done first, the session is closed, and
+// its error is returned.
+func DialContext(
+	ctx context.Context, host, password string, opts Options,
+) (*Session, chan Event, error) {
+	s, events := start(host, password, opts)
+
+	// only one of these is non-nil, and nil channels block forever
+	var connected <-chan timeoutConn
+	var loggedin <-chan struct{}
+	if s.opts.DisableAutoLogin {
+		connected = s.conn
+	} else {
+		loggedin = s.loggedin
+	}
+
+	select {
+	case <-connected:
+	case <-loggedin:
+	case <-ctx.Done():
+		s.Close()
+		return nil, nil, ctx.Err()
+	}
+
+	return s, events, nil
+}
+
+func start(host, password string, opts Options) (*Session, chan Event) {
+	opts.setDefaults()
 	s := &Session{
 		host:      host,
 		password:  password,
+		opts:      opts,
 		conn:      make(chan timeoutConn),
 		badconn:   make(chan timeoutConn),
 		loggedin:  make(chan struct{}),
@@ -121,12 +249,30 @@ func Dial(host, password string) (*Session, chan Event) {
 		callbacks: make(map[int]chan []string),
 		events:    make(chan Event),
 	}
-	go s.maintainConnection()
-	go s.stayLoggedIn()
-	go s.keepReading()
+	s.spawn(s.maintainConnection)
+	if !opts.DisableAutoLogin {
+		s.spawn(s.stayLoggedIn)
+	}
+
+	s.spawn(s.keepReading)
 	return s, s.events
 }
 
+// spawn runs f in a goroutine that Close waits for. It's only ever called from
+// goroutines that are spawned themselves, or before any are, so that Close
+// can't stop waiting too early.
+func (s *Session) spawn(f func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		f()
+	}()
+}
+
+func (s *Session) goEmit(t EventType, w []string) {
+	s.spawn(func() { s.emit(t, w) })
+}
+
 func (s *Session) emit(t EventType, w []string) {
 	// In case you're unsure, the Event struct initialization below is evaluated
 	// before select is blocked, not after the channel is ready to consume it, so
@@ -135,42 +281,50 @@ func (s *Session) emit(t EventType, w []string) {
 	//  https://play.golang.org/p/mdJ42XdAKz
 	select {
 	case s.events <- Event{t, time.Now(), w}:
-	case <-time.After(emitTimeout):
+	case <-time.After(s.opts.EmitTimeout):
 	case <-s.done:
 	}
 }
 
-func (s *Session) maintainConnection() {
-	var conn timeoutConn
-
-	connect := func() {
-		isRetry := false
-		for {
+// retry calls f until it succeeds, backing off in between, and gives up if
+// the session is done.
+func (s *Session) retry(f func() bool) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
 			select {
+			case <-time.After(s.opts.Backoff(attempt)):
 			case <-s.done:
 				return
-			default:
 			}
+		}
 
-			if isRetry {
-				select {
-				case <-time.After(retrySleep):
-				case <-s.done:
-					return
-				}
-			} else {
-				isRetry = true
-			}
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		if f() {
+			return
+		}
+	}
+}
+
+func (s *Session) maintainConnection() {
+	var conn timeoutConn
 
-			netconn, err := net.DialTimeout("tcp", s.host, dialTimeout)
+	connect := func() {
+		s.retry(func() bool {
+			netconn, err := net.DialTimeout("tcp", s.host, s.opts.DialTimeout)
 			if err != nil {
-				continue
+				s.opts.Logger.Printf("frcon: can't connect to %s: %v", s.host, err)
+				return false
 			}
 
-			go s.emit(EConnected, nil)
-			conn = timeoutConn{netconn}
-			break
-		}
+			s.goEmit(EConnected, nil)
+			conn = timeoutConn{netconn, s.opts.ReadTimeout, s.opts.WriteTimeout}
+			return true
+		})
 	}
 
 	connect()
@@ -179,7 +333,7 @@ func (s *Session) maintainConnection() {
 		case s.conn <- conn:
 		case badconn := <-s.badconn:
 			if badconn == conn {
-				go s.emit(EDisconnected, nil)
+				s.goEmit(EDisconnected, nil)
 				conn.Close()
 				// s.cbMutex.Lock()
 				// for _, c := range s.callbacks {
@@ -190,10 +344,20 @@ func (s *Session) maintainConnection() {
 
 				// Could check if there's a login procedure in progress, but let's keep
 				// this simple, for a safe measure.
-				go func() { s.relogin <- struct{}{} }()
+				if !s.opts.DisableAutoLogin {
+					s.spawn(func() {
+						select {
+						case s.relogin <- struct{}{}:
+						case <-s.done:
+						}
+					})
+				}
 			}
 		case <-s.done:
-			conn.Close()
+			if conn.netconn != nil {
+				conn.Close()
+			}
+
 			return
 		}
 	}
@@ -201,48 +365,14 @@ func (s *Session) maintainConnection() {
 
 func (s *Session) stayLoggedIn() {
 	login := func() {
-		for {
-			select {
-			case <-s.done:
-				return
-			default:
-			}
-
-			// TODO: consider sleeping on retry, like in connect()
-
-			resp, err := s.request([]string{"login.hashed"}, false)
-			if err != nil {
-				continue
-			} else if len(resp) < 2 || resp[0] != "OK" {
-				continue
+		s.retry(func() bool {
+			if !s.logIn() {
+				return false
 			}
 
-			challenge, err := hex.DecodeString(resp[1])
-			if err != nil {
-				// TODO: report connection?
-				continue
-			}
-			hash := md5.Sum(append(challenge, []byte(s.password)...))
-			resp, err = s.request([]string{
-				"login.hashed", strings.ToUpper(hex.EncodeToString(hash[:])),
-			}, false)
-			if err != nil {
-				continue
-			} else if len(resp) != 1 || resp[0] != "OK" {
-				go s.emit(EBadPassword, nil)
-				continue
-			}
-
-			resp, err = s.request([]string{"admin.eventsEnabled", "true"}, false)
-			if err != nil {
-				continue
-			} else if len(resp) != 1 || resp[0] != "OK" {
-				continue
-			}
-
-			go s.emit(ELoggedIn, nil)
-			break
-		}
+			s.goEmit(ELoggedIn, nil)
+			return true
+		})
 	}
 
 	login()
@@ -257,12 +387,47 @@ func (s *Session) stayLoggedIn() {
 	}
 }
 
-const headerSize = 12
+// logIn goes through login.hashed, and enables events, unless told not to.
+func (s *Session) logIn() bool {
+	resp, err := s.requestWithTimeout([]string{"login.hashed"}, false)
+	if err != nil {
+		return false
+	} else if len(resp) < 2 || resp[0] != "OK" {
+		return false
+	}
+
+	challenge, err := hex.DecodeString(resp[1])
+	if err != nil {
+		// TODO: report connection?
+		s.opts.Logger.Printf("frcon: bad login challenge from %s", s.host)
+		return false
+	}
+	hash := md5.Sum(append(challenge, []byte(s.password)...))
+	resp, err = s.requestWithTimeout([]string{
+		"login.hashed", strings.ToUpper(hex.EncodeToString(hash[:])),
+	}, false)
+	if err != nil {
+		return false
+	} else if len(resp) != 1 || resp[0] != "OK" {
+		s.opts.Logger.Printf("frcon: bad password for %s", s.host)
+		s.goEmit(EBadPassword, nil)
+		return false
+	}
 
-type header struct {
-	Sequence uint32
-	Size     int32
-	NumWords int32
+	if s.opts.DisableAutoEvents {
+		return true
+	}
+
+	resp, err = s.requestWithTimeout(
+		[]string{"admin.eventsEnabled", "true"}, false,
+	)
+	if err != nil {
+		return false
+	} else if len(resp) != 1 || resp[0] != "OK" {
+		return false
+	}
+
+	return true
 }
 
 func (s *Session) getCallback(seqNum int) (c chan []string, ok bool) {
@@ -275,13 +440,10 @@ func (s *Session) getCallback(seqNum int) (c chan []string, ok bool) {
 func (s *Session) keepReading() {
 	var conn timeoutConn
 	var b [maxSize]byte
+	pending := make([]byte, 0, maxSize*2)
 
-	buf := buffer.NewRing(buffer.New(maxSize * 2))
-	queued := int32(0)
-	var h *header
-
-	reset := func(_ string) {
-		go s.emit(EBadRead, nil)
+	reset := func() {
+		s.goEmit(EBadRead, nil)
 
 		select {
 		case s.badconn <- conn:
@@ -289,15 +451,17 @@ func (s *Session) keepReading() {
 			return
 		}
 
-		buf.Reset()
-		queued = int32(0)
-		h = nil
+		pending = pending[:0]
 	}
 
-OUTER:
 	for {
 		select {
-		case conn = <-s.conn:
+		case c := <-s.conn:
+			if c != conn {
+				pending = pending[:0] // leftovers of the previous connection
+			}
+
+			conn = c
 		case <-s.done:
 			return
 		}
@@ -306,79 +470,53 @@ OUTER:
 		// TODO: if n is zero, then the connection is closed
 		if err == errTimeout {
 			continue
-		} else if err == io.EOF {
-			reset("EOF")
-			continue
 		} else if err != nil {
-			reset("bad read")
+			reset()
 			continue
 		}
 
-		queued += int32(n)
-		_, err = buf.Write(b[:n])
-		if err != nil {
-			panic(err)
-		}
-
-		for queued >= headerSize {
-			if h == nil {
-				h = new(header)
-				binary.Read(buf, binary.LittleEndian, h)
-				if h.Size > maxSize {
-					reset("h.Size > maxSize")
-					continue OUTER
-				}
-
-				queued -= headerSize
-			}
-
-			if queued < h.Size-headerSize {
-				continue OUTER
+		pending = append(pending, b[:n]...)
+		for {
+			p, size, err := Decode(pending)
+			if err == io.ErrUnexpectedEOF {
+				break // wait for the rest
+			} else if err != nil {
+				s.opts.Logger.Printf("frcon: bad packet from %s: %v", s.host, err)
+				reset()
+				break
 			}
 
-			targetQueued := queued + headerSize - h.Size
-			var wordSize int32
-			words := make([]string, 0, h.NumWords)
-			for i := int32(0); i < h.NumWords; i++ {
-				binary.Read(buf, binary.LittleEndian, &wordSize)
-				if wordSize+5 > queued {
-					reset("wordSize+5 > queued")
-					continue OUTER
-				}
-				buf.Read(b[:wordSize+1]) // +1 is there to accommodate NULL
-				words = append(words, string(b[:wordSize]))
-				queued -= wordSize + 5
-			}
+			pending = append(pending[:0], pending[size:]...)
+			s.handle(p)
+		}
+	}
+}
 
-			if queued != targetQueued {
-				reset("queued != targetQueued")
-				continue OUTER
-			}
+func (s *Session) handle(p Packet) {
+	if !p.IsResponse {
+		s.goEmit(EWords, p.Words)
+		// send's errors are irrelevant in this case
+		s.send(context.Background(), p.Sequence, []string{"OK"}, false, false)
+		return
+	}
 
-			seqNum := int(h.Sequence & seqNumMask)
-			if h.Sequence&isResponseBit == 0 {
-				go s.emit(EWords, words)
-				// send's errors are irrelevant in this case
-				s.send(seqNum, []string{"OK"}, false, false)
-			} else {
-				if c, ok := s.getCallback(seqNum); ok {
-					go func() {
-						select {
-						case c <- words: // words slice is goroutine-safe
-						case <-time.After(callbackTimeout):
-							// TODO: print an error or something
-						case <-s.done:
-						}
-					}()
-				}
+	if c, ok := s.getCallback(p.Sequence); ok {
+		s.spawn(func() {
+			select {
+			case c <- p.Words: // words slice is goroutine-safe
+			case <-time.After(s.opts.CallbackTimeout):
+				s.opts.Logger.Printf(
+					"frcon: nobody took the response to %d", p.Sequence,
+				)
+			case <-s.done:
 			}
-
-			h = nil
-		}
+		})
 	}
 }
 
-func (s *Session) request(words []string, waitLogin bool) ([]string, error) {
+func (s *Session) request(
+	ctx context.Context, words []string, waitLogin bool,
+) ([]string, error) {
 	s.seqMutex.Lock()
 	seqNum := s.seqNumIter
 	s.seqNumIter++
@@ -397,56 +535,53 @@ func (s *Session) request(words []string, waitLogin bool) ([]string, error) {
 		s.cbMutex.Unlock()
 	}()
 
-	// TODO: if the connection keeps failing, or the password is incorrect / the
-	// game server is misbehaving, then send can block for ages, hence, we
-	// probably need a timeout
-	err := s.send(seqNum, words, true, waitLogin)
+	err := s.send(ctx, seqNum, words, true, waitLogin)
 	if err != nil {
 		return nil, err
 	}
 	select {
 	case resp := <-c:
 		return resp, nil
-	case <-time.After(requestTimeout):
-		return nil, ErrRequestTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	case <-s.done:
 		return nil, ErrDone
 	}
 }
 
+// requestWithTimeout is request within RequestTimeout, sending and waiting
+// for the login procedure and the connection included.
+func (s *Session) requestWithTimeout(
+	words []string, waitLogin bool,
+) ([]string, error) {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), s.opts.RequestTimeout,
+	)
+	defer cancel()
+	resp, err := s.request(ctx, words, waitLogin)
+	if err == context.DeadlineExceeded {
+		return nil, ErrRequestTimeout
+	}
+
+	return resp, err
+}
+
 func (s *Session) send(
-	seqNum int, words []string, isRequest bool, waitLogin bool,
+	ctx context.Context, seqNum int, words []string, isRequest bool,
+	waitLogin bool,
 ) error {
-	if seqNum < 0 || seqNum > seqNumMask {
-		return ErrBadSequence
-	} else if len(words) == 0 {
+	if len(words) == 0 {
 		return ErrNoWords
 	} // TODO: not sure if matters
 
-	h := header{
-		Sequence: uint32(seqNum),
-		Size:     headerSize,
-		NumWords: int32(len(words)),
-	}
-	for _, word := range words {
-		h.Size += 5 + int32(len(word)) // 5 = len(word) + NULL terminator
-	}
-	if h.Size > maxSize {
-		return ErrPayloadTooLong
-	}
-
-	buf := bytes.NewBuffer(make([]byte, 0, h.Size))
-	if isRequest {
-		h.Sequence |= isClientBit
-	} else {
-		h.Sequence |= isResponseBit
-	}
-
-	binary.Write(buf, binary.LittleEndian, &h)
-	for _, word := range words {
-		binary.Write(buf, binary.LittleEndian, int32(len(word)))
-		buf.WriteString(word)
-		buf.WriteByte(0)
+	b, err := Encode(Packet{
+		Sequence:   seqNum,
+		IsClient:   isRequest,
+		IsResponse: !isRequest,
+		Words:      words,
+	})
+	if err != nil {
+		return err
 	}
 
 	var conn timeoutConn
@@ -456,6 +591,8 @@ func (s *Session) send(
 		if waitLogin {
 			select {
 			case <-s.loggedin:
+			case <-ctx.Done():
+				return ctx.Err()
 			case <-s.done:
 				return ErrDone
 			}
@@ -463,12 +600,14 @@ func (s *Session) send(
 
 		select {
 		case conn = <-s.conn:
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-s.done:
 			return ErrDone
 		}
 
 		s.writeMutex.Lock()
-		_, err := conn.Write(buf.Bytes())
+		_, err := conn.Write(b)
 		s.writeMutex.Unlock()
 		if err != nil {
 			select {
@@ -485,19 +624,34 @@ func (s *Session) send(
 	return nil
 }
 
+// Request sends words once logged in, unless logging in is left to the
+// caller, and waits for the response, all within RequestTimeout.
 func (s *Session) Request(words []string) ([]string, error) {
-	return s.request(words, true)
+	return s.requestWithTimeout(words, !s.opts.DisableAutoLogin)
 }
 
-// don't wait for the login procedure to complete
+// RequestPublic doesn't wait for the login procedure to complete.
 func (s *Session) RequestPublic(words []string) ([]string, error) {
-	return s.request(words, false)
+	return s.requestWithTimeout(words, false)
+}
+
+// RequestContext is Request bound by ctx instead of RequestTimeout.
+func (s *Session) RequestContext(
+	ctx context.Context, words []string,
+) ([]string, error) {
+	return s.request(ctx, words, !s.opts.DisableAutoLogin)
 }
 
 func (s *Session) Host() string {
 	return s.host
 }
 
+// Close stops the session, waits for everything it was doing to stop, and
+// closes the events channel. Requests in progress fail with ErrDone.
 func (s *Session) Close() {
-	close(s.done)
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		close(s.events)
+	})
 }
@@ -0,0 +1,129 @@
+package frcon_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"app/frcon"
+	"app/frcon/frcontest"
+)
+
+const password = "password"
+
+// dial logs into s, with timeouts short enough for tests to not drag on.
+func dial(
+	t *testing.T, s *frcontest.Server,
+) (*frcon.Session, chan frcon.Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := frcon.Options{
+		ReadTimeout:    50 * time.Millisecond,
+		RequestTimeout: 2 * time.Second,
+		Backoff:        frcon.ConstantBackoff(10 * time.Millisecond),
+	}
+	session, events, err := frcon.DialContext(ctx, s.Addr, password, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(session.Close)
+	waitFor(t, events, frcon.ELoggedIn)
+	return session, events
+}
+
+func newServer(t *testing.T) *frcontest.Server {
+	s, err := frcontest.NewServer(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(s.Close)
+	return s
+}
+
+// waitFor reads events until every one of types has come at least once, in
+// whatever order, since they're emitted concurrently, returning the last
+// event of each type.
+func waitFor(
+	t *testing.T, events chan frcon.Event, types ...frcon.EventType,
+) map[frcon.EventType]frcon.Event {
+	seen := make(map[frcon.EventType]frcon.Event)
+	timeout := time.After(5 * time.Second)
+	for len(seen) < len(types) {
+		select {
+		case event := <-events:
+			for _, typ := range types {
+				if event.Type == typ {
+					seen[typ] = event
+				}
+			}
+		case <-timeout:
+			t.Fatalf("waited for %v, got %v", types, seen)
+		}
+	}
+
+	return seen
+}
+
+// relogged makes sure that the session has logged in again, and still works.
+func relogged(t *testing.T, s *frcontest.Server, session *frcon.Session) {
+	if logins := s.Logins(); logins != 2 {
+		t.Errorf("logged in %d times, expected 2", logins)
+	}
+
+	resp, err := session.Request([]string{"version"})
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(resp, []string{"OK"}) {
+		t.Errorf("got %q", resp)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	s := newServer(t)
+	_, events := dial(t, s)
+
+	err := s.Emit("player.onJoin", "nelly", "EA_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := waitFor(t, events, frcon.EWords)[frcon.EWords]
+	expected := []string{"player.onJoin", "nelly", "EA_1"}
+	if !reflect.DeepEqual(event.Words, expected) {
+		t.Errorf("got %q, expected %q", event.Words, expected)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	s := newServer(t)
+	session, events := dial(t, s)
+
+	s.Drop()
+	waitFor(t, events, frcon.EDisconnected, frcon.EConnected, frcon.ELoggedIn)
+	relogged(t, s, session)
+
+	// events have to be enabled again too
+	err := s.Emit("server.onRoundOver", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, events, frcon.EWords)
+}
+
+func TestBadPacket(t *testing.T) {
+	s := newServer(t)
+	session, events := dial(t, s)
+
+	// a size too small to fit even the header
+	err := s.SendRaw([]byte{0, 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, events, frcon.EBadRead, frcon.EDisconnected, frcon.ELoggedIn)
+	relogged(t, s, session)
+}
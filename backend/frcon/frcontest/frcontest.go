@@ -0,0 +1,280 @@
+// Package frcontest is a fake Frostbite RCON server, for testing frcon and
+// whatever uses it without a real game server. It logs clients in with
+// login.hashed, enables events with admin.eventsEnabled, and answers the rest
+// with whatever the handlers say. On top of that, it can emit events, drop
+// connections and send garbage whenever it's told to.
+package frcontest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"app/frcon"
+)
+
+var ErrClosed = errors.New("server closed")
+
+// HandlerFunc answers a request that made it past the login check.
+type HandlerFunc func(words []string) []string
+
+// Server listens on a random local port from NewServer until Close.
+type Server struct {
+	Addr     string
+	Password string
+
+	listener net.Listener
+	mutex    sync.Mutex
+	conns    map[*conn]struct{}
+	handlers map[string]HandlerFunc
+	requests [][]string
+	logins   int
+	seqNum   int
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type conn struct {
+	netconn    net.Conn
+	writeMutex sync.Mutex
+	salt       []byte
+	loggedIn   bool
+	events     bool
+}
+
+// NewServer starts a server that lets in clients knowing the password.
+// Requests other than the ones about logging in and enabling events are
+// answered with OK, and need logging in, unless handled otherwise with
+// Handle.
+func NewServer(password string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		Password: password,
+		listener: listener,
+		conns:    make(map[*conn]struct{}),
+		handlers: make(map[string]HandlerFunc),
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s, nil
+}
+
+// Handle makes the server answer requests starting with command using h,
+// whether the client is logged in or not.
+func (s *Server) Handle(command string, h HandlerFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[command] = h
+}
+
+// Requests returns every request received so far, in order, including the
+// login ones.
+func (s *Server) Requests() [][]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([][]string(nil), s.requests...)
+}
+
+// Logins tells how many times clients have logged in successfully.
+func (s *Server) Logins() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.logins
+}
+
+// Conns tells how many clients are connected right now.
+func (s *Server) Conns() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.conns)
+}
+
+// Emit sends an event to every client that has enabled them. Their responses
+// are read and thrown away.
+func (s *Server) Emit(words ...string) error {
+	s.mutex.Lock()
+	s.seqNum = (s.seqNum + 1) & frcon.MaxSequence
+	packet, err := frcon.Encode(frcon.Packet{Sequence: s.seqNum, Words: words})
+	targets := s.targets(func(c *conn) bool { return c.events })
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return s.write(targets, packet)
+}
+
+// SendRaw writes b as is to every client, logged in or not, which is how
+// malformed packets are made.
+func (s *Server) SendRaw(b []byte) error {
+	s.mutex.Lock()
+	targets := s.targets(func(*conn) bool { return true })
+	s.mutex.Unlock()
+	return s.write(targets, b)
+}
+
+// Drop disconnects every client, but keeps listening, so they can come back.
+func (s *Server) Drop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c := range s.conns {
+		c.netconn.Close()
+	}
+}
+
+// Close disconnects everyone, stops listening, and waits for everything to
+// wind down.
+func (s *Server) Close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+
+	s.closed = true
+	s.listener.Close()
+	for c := range s.conns {
+		c.netconn.Close()
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+}
+
+// targets has to be called with the mutex locked.
+func (s *Server) targets(filter func(c *conn) bool) []*conn {
+	targets := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		if filter(c) {
+			targets = append(targets, c)
+		}
+	}
+
+	return targets
+}
+
+func (s *Server) write(targets []*conn, b []byte) error {
+	s.mutex.Lock()
+	closed := s.closed
+	s.mutex.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	for _, c := range targets {
+		c.writeMutex.Lock()
+		c.netconn.Write(b) // a dead client is none of the caller's business
+		c.writeMutex.Unlock()
+	}
+
+	return nil
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		netconn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &conn{netconn: netconn}
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			netconn.Close()
+			return
+		}
+
+		s.conns[c] = struct{}{}
+		s.mutex.Unlock()
+		s.wg.Add(1)
+		go s.serve(c)
+	}
+}
+
+func (s *Server) serve(c *conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, c)
+		s.mutex.Unlock()
+		c.netconn.Close()
+	}()
+
+	for {
+		p, err := frcon.ReadPacket(c.netconn)
+		if err != nil {
+			return
+		} else if p.IsResponse {
+			continue // a response to an event
+		}
+
+		p.IsResponse = true
+		p.Words = s.answer(c, p.Words)
+		packet, err := frcon.Encode(p)
+		if err != nil {
+			return
+		}
+
+		c.writeMutex.Lock()
+		_, err = c.netconn.Write(packet)
+		c.writeMutex.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) answer(c *conn, words []string) []string {
+	s.mutex.Lock()
+	s.requests = append(s.requests, words)
+	var h HandlerFunc
+	if len(words) > 0 {
+		h = s.handlers[words[0]]
+	}
+	s.mutex.Unlock()
+
+	if len(words) == 0 {
+		return []string{"UnknownCommand"}
+	} else if h != nil {
+		return h(words)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch {
+	case words[0] == "login.hashed" && len(words) == 1:
+		c.salt = make([]byte, 16)
+		rand.Read(c.salt)
+		return []string{"OK", strings.ToUpper(hex.EncodeToString(c.salt))}
+	case words[0] == "login.hashed" && len(words) == 2:
+		if c.salt == nil {
+			return []string{"PasswordNotSet"}
+		}
+
+		hash := md5.Sum(append(c.salt, []byte(s.Password)...))
+		if !strings.EqualFold(words[1], hex.EncodeToString(hash[:])) {
+			return []string{"InvalidPasswordHash"}
+		}
+
+		c.loggedIn = true
+		s.logins++
+		return []string{"OK"}
+	case !c.loggedIn:
+		return []string{"LogInRequired"}
+	case words[0] == "admin.eventsEnabled" && len(words) == 2:
+		c.events = words[1] == "true"
+		return []string{"OK"}
+	}
+
+	return []string{"OK"}
+}
@@ -0,0 +1,165 @@
+package frcon
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	headerSize    = 12
+	maxSize       = 0x4000     // 16384
+	isClientBit   = 0x80000000 // 0 = server, 1 = client
+	isResponseBit = 0x40000000 // 0 = request, 1 = response
+	seqNumMask    = 0x3FFFFFFF // this is also the max, thanks to lucky alignment
+)
+
+// MaxSequence is the highest sequence number, after which they wrap around.
+const MaxSequence = seqNumMask
+
+var (
+	// ErrTruncated is for packets that are cut short, as in a word running past
+	// the end, or a size too small to fit even the header.
+	ErrTruncated = errors.New("truncated packet")
+	// ErrWordCount is for packets whose words don't add up to their size.
+	ErrWordCount = errors.New("word count mismatch")
+	// ErrTerminator is for words that aren't followed by NULL.
+	ErrTerminator = errors.New("word not terminated")
+)
+
+// Packet is the unit of Frostbite RCON: a sequence number, whether it came
+// from the client and whether it's a response, and some words. Every packet
+// is 12 bytes of header (sequence with the two bits, size, number of words),
+// followed by the words, each prefixed by its length and terminated by NULL,
+// all little-endian.
+type Packet struct {
+	Sequence   int
+	IsClient   bool
+	IsResponse bool
+	Words      []string
+}
+
+// Size is how long p is once encoded.
+func (p Packet) Size() int {
+	size := headerSize
+	for _, word := range p.Words {
+		size += 5 + len(word) // 5 = len(word) + NULL terminator
+	}
+
+	return size
+}
+
+// Encode turns p into bytes, as long as it fits.
+func Encode(p Packet) ([]byte, error) {
+	if p.Sequence < 0 || p.Sequence > seqNumMask {
+		return nil, ErrBadSequence
+	}
+
+	size := p.Size()
+	if size > maxSize {
+		return nil, ErrPayloadTooLong
+	}
+
+	seq := uint32(p.Sequence)
+	if p.IsClient {
+		seq |= isClientBit
+	}
+
+	if p.IsResponse {
+		seq |= isResponseBit
+	}
+
+	b := make([]byte, size)
+	binary.LittleEndian.PutUint32(b[0:], seq)
+	binary.LittleEndian.PutUint32(b[4:], uint32(size))
+	binary.LittleEndian.PutUint32(b[8:], uint32(len(p.Words)))
+	i := headerSize
+	for _, word := range p.Words {
+		binary.LittleEndian.PutUint32(b[i:], uint32(len(word)))
+		i += 4 + copy(b[i+4:], word) + 1 // the NULL is already there
+	}
+
+	return b, nil
+}
+
+// Decode takes the first packet off of b, returning it and how many bytes it
+// took. If b holds only a part of it, the error is io.ErrUnexpectedEOF, and
+// it's worth trying again once there's more.
+func Decode(b []byte) (Packet, int, error) {
+	if len(b) < headerSize {
+		return Packet{}, 0, io.ErrUnexpectedEOF
+	}
+
+	seq := binary.LittleEndian.Uint32(b[0:])
+	size := binary.LittleEndian.Uint32(b[4:])
+	numWords := binary.LittleEndian.Uint32(b[8:])
+	if size > maxSize {
+		return Packet{}, 0, ErrPayloadTooLong
+	} else if size < headerSize {
+		return Packet{}, 0, ErrTruncated
+	} else if uint32(len(b)) < size {
+		return Packet{}, 0, io.ErrUnexpectedEOF
+	} else if numWords > (size-headerSize)/5 {
+		return Packet{}, 0, ErrWordCount
+	}
+
+	p := Packet{
+		Sequence:   int(seq & seqNumMask),
+		IsClient:   seq&isClientBit != 0,
+		IsResponse: seq&isResponseBit != 0,
+		Words:      make([]string, 0, numWords),
+	}
+	body := b[headerSize:size]
+	for i := uint32(0); i < numWords; i++ {
+		if len(body) < 5 {
+			return Packet{}, 0, ErrTruncated
+		}
+
+		wordSize := binary.LittleEndian.Uint32(body)
+		if uint32(len(body)-5) < wordSize {
+			return Packet{}, 0, ErrTruncated
+		}
+
+		if body[4+wordSize] != 0 {
+			return Packet{}, 0, ErrTerminator
+		}
+
+		p.Words = append(p.Words, string(body[4:4+wordSize]))
+		body = body[5+wordSize:]
+	}
+
+	if len(body) != 0 {
+		return Packet{}, 0, ErrWordCount
+	}
+
+	return p, int(size), nil
+}
+
+// ReadPacket reads a single packet from r, and nothing more. io.EOF means
+// that r ended cleanly between packets, and io.ErrUnexpectedEOF that it
+// ended in the middle of one.
+func ReadPacket(r io.Reader) (Packet, error) {
+	b := make([]byte, headerSize, maxSize)
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return Packet{}, err
+	}
+
+	size := binary.LittleEndian.Uint32(b[4:])
+	if size > maxSize {
+		return Packet{}, ErrPayloadTooLong
+	} else if size < headerSize {
+		return Packet{}, ErrTruncated
+	}
+
+	b = b[:size]
+	_, err = io.ReadFull(r, b[headerSize:])
+	if err == io.EOF {
+		return Packet{}, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return Packet{}, err
+	}
+
+	p, _, err := Decode(b)
+	return p, err
+}
@@ -0,0 +1,143 @@
+package frcon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func randomPacket(r *rand.Rand) Packet {
+	p := Packet{
+		Sequence:   r.Intn(MaxSequence + 1),
+		IsClient:   r.Intn(2) == 0,
+		IsResponse: r.Intn(2) == 0,
+		Words:      make([]string, r.Intn(10)),
+	}
+	for i := range p.Words {
+		word := make([]byte, r.Intn(50))
+		r.Read(word)
+		p.Words[i] = string(word)
+	}
+
+	return p
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		p := randomPacket(r)
+		b, err := Encode(p)
+		if err != nil {
+			t.Fatalf("%+v: %v", p, err)
+		} else if len(b) != p.Size() {
+			t.Fatalf("%+v: encoded into %d bytes, not %d", p, len(b), p.Size())
+		}
+
+		decoded, n, err := Decode(b)
+		if err != nil {
+			t.Fatalf("%+v: %v", p, err)
+		} else if n != len(b) {
+			t.Fatalf("%+v: took %d bytes out of %d", p, n, len(b))
+		} else if !reflect.DeepEqual(decoded, p) {
+			t.Fatalf("got %+v, expected %+v", decoded, p)
+		}
+
+		read, err := ReadPacket(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%+v: %v", p, err)
+		} else if !reflect.DeepEqual(read, p) {
+			t.Fatalf("read %+v, expected %+v", read, p)
+		}
+	}
+}
+
+// header makes a packet header without anything after it.
+func header(size, numWords uint32) []byte {
+	b := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(b[4:], size)
+	binary.LittleEndian.PutUint32(b[8:], numWords)
+	return b
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid, _ := Encode(Packet{Words: []string{"serverInfo"}})
+	unterminated := append([]byte{}, valid...)
+	unterminated[len(unterminated)-1] = 'x'
+	runningPast := append([]byte{}, valid...)
+	binary.LittleEndian.PutUint32(runningPast[headerSize:], 11)
+
+	cases := []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{"empty", nil, io.ErrUnexpectedEOF},
+		{"partial header", valid[:5], io.ErrUnexpectedEOF},
+		{"partial body", valid[:len(valid)-1], io.ErrUnexpectedEOF},
+		{"too long", header(maxSize+1, 0), ErrPayloadTooLong},
+		{"smaller than the header", header(headerSize-1, 0), ErrTruncated},
+		{"word running past the end", runningPast, ErrTruncated},
+		{"too many words for the size", header(headerSize, 1), ErrWordCount},
+		{
+			"too many words for the words",
+			append(header(headerSize+10, 2), valid[headerSize:]...)[:headerSize+10],
+			ErrTruncated,
+		},
+		{
+			"too few words",
+			append(header(uint32(len(valid)), 0), valid[headerSize:]...),
+			ErrWordCount,
+		},
+		{"unterminated word", unterminated, ErrTerminator},
+	}
+
+	for _, c := range cases {
+		_, _, err := Decode(c.b)
+		if err != c.err {
+			t.Errorf("%s: got %v, expected %v", c.name, err, c.err)
+		}
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	_, err := Encode(Packet{Words: []string{strings.Repeat("x", maxSize)}})
+	if err != ErrPayloadTooLong {
+		t.Errorf("got %v, expected ErrPayloadTooLong", err)
+	}
+
+	_, err = Encode(Packet{Sequence: MaxSequence + 1})
+	if err != ErrBadSequence {
+		t.Errorf("got %v, expected ErrBadSequence", err)
+	}
+}
+
+// FuzzDecode makes sure that Decode doesn't panic on anything the server
+// might send, and that whatever it accepts is encoded back into the same
+// bytes.
+func FuzzDecode(f *testing.F) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		b, _ := Encode(randomPacket(r))
+		f.Add(b)
+	}
+
+	f.Add(header(headerSize, 0))
+	f.Add(header(maxSize, 1<<31))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		p, n, err := Decode(b)
+		if err != nil {
+			return
+		}
+
+		encoded, err := Encode(p)
+		if err != nil {
+			t.Fatalf("%+v decoded, but doesn't encode: %v", p, err)
+		} else if !bytes.Equal(encoded, b[:n]) {
+			t.Fatalf("%x decoded into %+v, which encodes into %x", b[:n], p, encoded)
+		}
+	})
+}
@@ -23,8 +23,11 @@ import (
 	"app/api"
 	"app/mail"
 	"app/models"
+	"app/oauth"
 	"app/slack"
 	"app/utils"
+	"app/verification"
+	"app/verification/statstest"
 	"app/worker"
 )
 
@@ -72,6 +75,50 @@ func main() {
 		log.Println("WARNING: no Sentry key found, printing to stdout")
 	}
 
+	// Steam's OpenID needs no credentials, the rest are enabled only if their
+	// client credentials are supplied
+	providers := []oauth.Provider{&oauth.Steam{}}
+	if id := os.Getenv("GOOGLE_CLIENT_ID"); id != "" {
+		providers = append(providers, &oauth.OIDC{
+			ProviderName: "google",
+			Issuer:       "https://accounts.google.com",
+			ClientId:     id,
+			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+			Scopes:       []string{"openid", "email", "profile"},
+		})
+	} else {
+		log.Println("WARNING: no Google client ID found, Google login disabled")
+	}
+
+	if id := os.Getenv("DISCORD_CLIENT_ID"); id != "" {
+		providers = append(providers, &oauth.OIDC{
+			ProviderName:       "discord",
+			ClientId:           id,
+			ClientSecret:       os.Getenv("DISCORD_CLIENT_SECRET"),
+			Scopes:             []string{"identify", "email"},
+			AuthEndpoint:       "https://discord.com/api/oauth2/authorize",
+			TokenEndpoint:      "https://discord.com/api/oauth2/token",
+			UserInfoEndpoint:   "https://discord.com/api/users/@me",
+			SubjectClaim:       "id",
+			EmailVerifiedClaim: "verified",
+			NameClaim:          "username",
+		})
+	} else {
+		log.Println("WARNING: no Discord client ID found, Discord login disabled")
+	}
+
+	// STATS_FIXTURES is for verifying players without Battlelog, which is
+	// useful in development, now that it's mostly dead
+	var stats verification.StatsProvider = verification.NewBattlelog()
+	if path := os.Getenv("STATS_FIXTURES"); path != "" {
+		stats, err = statstest.Load(path)
+		if err != nil {
+			panic(err)
+		}
+
+		log.Println("WARNING: using stats fixtures instead of Battlelog")
+	}
+
 	env := api.New(
 		staticHost,
 		scrypt.Params{
@@ -84,15 +131,20 @@ func main() {
 		models.New(db),
 		mail.New("auzom <support@auzom.gg>", sg),
 		slack.New(staticHost, hook),
+		oauth.New(providers...),
+		verification.New(&verification.Battlefield4{Stats: stats}),
 		sentry,
 	)
 
 	// without this, the "from" IP is of nginx-proxy, not the real IP
 	goji.Insert(middleware.RealIP, middleware.Logger)
 	goji.Use(cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://" + staticHost, "https://" + staticHost},
-		AllowedMethods:   []string{"POST", "GET", "PUT", "PATCH", "DELETE"},
-		AllowedHeaders:   []string{"*"},
+		AllowedOrigins: []string{"http://" + staticHost, "https://" + staticHost},
+		AllowedMethods: []string{"POST", "GET", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"*"},
+		ExposedHeaders: []string{
+			"ETag", "Link", "X-Total-Count", "Idempotent-Replayed",
+		},
 		AllowCredentials: true,
 	}).Handler)
 
@@ -127,14 +179,34 @@ func main() {
 			Name:  "work",
 			Usage: "launch background worker",
 			Action: func(c *cli.Context) {
+				// BF4_SERVERS is password@host:port, space-separated, while the
+				// other two are for when there's just one
+				servers, err := worker.ParseServers(os.Getenv("BF4_SERVERS"))
+				if err != nil {
+					log.Fatalln("ERROR:", err)
+				}
+
 				address := os.Getenv("BF4_ADDRESS")
 				password := os.Getenv("BF4_PASSWORD")
-				if address == "" || password == "" {
+				if address != "" && password != "" {
+					servers = append(servers, worker.ServerConfig{
+						Address:  address,
+						Password: password,
+					})
+				}
+
+				if len(servers) == 0 {
 					log.Fatalln("ERROR: battlefield 4 verification server info missing")
 				}
 
+				pool, err := worker.NewPool(servers, env)
+				if err != nil {
+					log.Fatalln("ERROR: worker failed:", err)
+				}
+
+				setupWorkerRoutes(env, pool)
 				go goji.Serve()
-				worker.Run(address, password, env)
+				pool.Run()
 			},
 		},
 		{
@@ -174,14 +246,65 @@ func main() {
 							log.Fatal(err.Error())
 						}
 
-						err = env.M.CreateUser(&models.User{
+						user := &models.User{
 							Email:           email,
 							Password:        password,
 							IsEmailVerified: true,
-							UserPublic: models.UserPublic{
-								IsAdmin: c.Bool("admin"),
-							},
-						})
+						}
+						err = env.M.CreateUser(user)
+						if err != nil {
+							log.Fatal(err.Error())
+						}
+
+						if c.Bool("admin") {
+							err = env.M.CreateRole(&models.Role{
+								UserId: user.Id, Name: models.RoleAdmin,
+							})
+							if err != nil {
+								log.Fatal(err.Error())
+							}
+						}
+					},
+				},
+				{
+					Name:      "grant",
+					Usage:     "grant a role, globally or within a tournament or season",
+					ArgsUsage: "<email> <role>",
+					Flags:     roleFlags,
+					Action: func(c *cli.Context) {
+						role := roleFromArgs(env, c)
+						_, err := env.M.GetRoleByUserNameScope(
+							role.UserId, role.Name, role.TournamentId, role.SeasonId,
+						)
+						if err == nil {
+							log.Fatalf("%s already has this role", c.Args()[0])
+						} else if err != utils.ErrNotFound {
+							log.Fatal(err.Error())
+						}
+
+						err = env.M.CreateRole(role)
+						if err != nil {
+							log.Fatal(err.Error())
+						}
+					},
+				},
+				{
+					Name:      "revoke",
+					Usage:     "revoke a role granted with the same arguments",
+					ArgsUsage: "<email> <role>",
+					Flags:     roleFlags,
+					Action: func(c *cli.Context) {
+						role := roleFromArgs(env, c)
+						role, err := env.M.GetRoleByUserNameScope(
+							role.UserId, role.Name, role.TournamentId, role.SeasonId,
+						)
+						if err == utils.ErrNotFound {
+							log.Fatalf("%s doesn't have this role", c.Args()[0])
+						} else if err != nil {
+							log.Fatal(err.Error())
+						}
+
+						err = env.M.DeleteRole(role)
 						if err != nil {
 							log.Fatal(err.Error())
 						}
@@ -194,3 +317,38 @@ func main() {
 	app.Run(os.Args)
 	log.Println("done")
 }
+
+var roleFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "tournament, t",
+		Usage: "tournament ID to scope the role to",
+	},
+	cli.StringFlag{
+		Name:  "season, s",
+		Usage: "season ID to scope the role to, implies its tournament",
+	},
+}
+
+func roleFromArgs(env *api.Env, c *cli.Context) *models.Role {
+	args := c.Args()
+	if len(args) < 2 {
+		log.Fatalf("expected 2 arguments, received %d", len(args))
+	}
+
+	user, err := env.M.GetUserByEmail(args[0])
+	if err == utils.ErrNotFound {
+		log.Fatalf("%s not found", args[0])
+	} else if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	tournamentId, seasonId := c.String("tournament"), c.String("season")
+	scope, apierr := env.RoleScope(args[1], &tournamentId, &seasonId)
+	if apierr != nil {
+		log.Fatal(apierr.Error())
+	}
+
+	role := &models.Role{UserId: user.Id, Name: args[1]}
+	role.TournamentId, role.SeasonId = scope.Ids()
+	return role
+}
@@ -0,0 +1,215 @@
+// Package metrics keeps counters, gauges and summaries in memory and writes
+// them out in Prometheus' text format, which is all that scraping needs, so
+// there's no pulling in the whole client library.
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is where packages register their metrics, much like http's
+// DefaultServeMux.
+var Default = New()
+
+// ContentType is what Write writes, for the Content-Type header.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type Registry struct {
+	mutex      sync.Mutex
+	families   []*family
+	names      map[string]bool
+	collectors []func()
+}
+
+func New() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// family is a metric and all of its label values.
+type family struct {
+	name   string
+	help   string
+	kind   string // counter, gauge or summary
+	labels []string
+	values map[string]*value
+}
+
+type value struct {
+	labels []string
+	x      float64 // the sum, for summaries
+	count  uint64  // summaries only
+}
+
+func (r *Registry) register(
+	name, help, kind string, labels []string,
+) *family {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.names[name] {
+		panic("metrics: " + name + " is registered twice")
+	}
+
+	f := &family{name, help, kind, labels, make(map[string]*value)}
+	r.names[name] = true
+	r.families = append(r.families, f)
+	return f
+}
+
+// Collect runs f before every Write, for gauges that are easier to read off
+// the state they describe than to keep up to date.
+func (r *Registry) Collect(f func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.collectors = append(r.collectors, f)
+}
+
+// update finds the value for labels, creating it if it's the first time, and
+// calls op on it, all with the registry locked.
+func (r *Registry) update(f *family, labels []string, op func(v *value)) {
+	if len(labels) != len(f.labels) {
+		panic("metrics: wrong number of labels for " + f.name)
+	}
+
+	// \xff can't appear in valid UTF-8, so it can't be in a label either
+	key := strings.Join(labels, "\xff")
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	v, ok := f.values[key]
+	if !ok {
+		v = &value{labels: append([]string(nil), labels...)}
+		f.values[key] = v
+	}
+
+	op(v)
+}
+
+// Counter only ever goes up.
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r, r.register(name, help, "counter", labels)}
+}
+
+// Add adds x, which mustn't be negative, to the counter with the given label
+// values, in the order that the labels were registered in.
+func (c *Counter) Add(x float64, labels ...string) {
+	if x < 0 {
+		panic("metrics: " + c.f.name + " can't go down")
+	}
+
+	c.r.update(c.f, labels, func(v *value) { v.x += x })
+}
+
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Gauge goes up and down.
+type Gauge struct {
+	r *Registry
+	f *family
+}
+
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r, r.register(name, help, "gauge", labels)}
+}
+
+func (g *Gauge) Set(x float64, labels ...string) {
+	g.r.update(g.f, labels, func(v *value) { v.x = x })
+}
+
+func (g *Gauge) Add(x float64, labels ...string) {
+	g.r.update(g.f, labels, func(v *value) { v.x += x })
+}
+
+// Summary is a sum and a count of observations, like durations, which is
+// enough for averages and rates, without any quantiles.
+type Summary struct {
+	r *Registry
+	f *family
+}
+
+func (r *Registry) Summary(name, help string, labels ...string) *Summary {
+	return &Summary{r, r.register(name, help, "summary", labels)}
+}
+
+func (s *Summary) Observe(x float64, labels ...string) {
+	s.r.update(s.f, labels, func(v *value) {
+		v.x += x
+		v.count++
+	})
+}
+
+// Write writes out everything in the text exposition format, version 0.0.4.
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.Lock()
+	collectors := r.collectors
+	r.mutex.Unlock()
+	for _, f := range collectors {
+		f()
+	}
+
+	var buf bytes.Buffer
+	r.mutex.Lock()
+	for _, f := range r.families {
+		f.write(&buf)
+	}
+	r.mutex.Unlock()
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (f *family) write(buf *bytes.Buffer) {
+	buf.WriteString(
+		"# HELP " + f.name + " " + helpEscaper.Replace(f.help) + "\n",
+	)
+	buf.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+
+	keys := make([]string, 0, len(f.values))
+	for key := range f.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		v := f.values[key]
+		labels := f.formatLabels(v.labels)
+		if f.kind == "summary" {
+			writeSample(buf, f.name+"_sum", labels, v.x)
+			writeSample(buf, f.name+"_count", labels, float64(v.count))
+		} else {
+			writeSample(buf, f.name, labels, v.x)
+		}
+	}
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (f *family) formatLabels(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(values))
+	for i, x := range values {
+		pairs[i] = f.labels[i] + `="` + labelEscaper.Replace(x) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func writeSample(buf *bytes.Buffer, name, labels string, x float64) {
+	buf.WriteString(
+		name + labels + " " + strconv.FormatFloat(x, 'g', -1, 64) + "\n",
+	)
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	AccountAlertDuplicate = "duplicate" // tried to verify with a taken identifier
+	AccountAlertShared    = "shared"    // has something like an IP in common
+)
+
+// AccountAlert is about two users who look like the same person, going by
+// the Key of their user-game pairs of a game having the same Value.
+type AccountAlert struct {
+	Id            string     `json:"id"`
+	Kind          string     `json:"kind"`
+	GameId        string     `db:"game_id" json:"gameId"`
+	UserId        string     `db:"user_id" json:"userId"`
+	RelatedUserId string     `db:"related_user_id" json:"relatedUserId"`
+	Key           string     `json:"key"`
+	Value         string     `json:"value"`
+	CreatedAt     time.Time  `db:"created_at" json:"createdAt"`
+	ResolvedAt    *time.Time `db:"resolved_at" json:"resolvedAt"`
+	ResolvedBy    *string    `db:"resolved_by" json:"resolvedBy"`
+}
+
+// CreateAccountAlert does nothing, and leaves alert as is, if the same alert
+// is still unresolved.
+func (e *Env) CreateAccountAlert(alert *AccountAlert) error {
+	err := e.Db.Get(
+		alert, `
+    INSERT INTO account_alert (
+      kind, game_id, user_id, related_user_id, key, value
+    )
+    VALUES ($1, $2, $3, $4, $5, $6)
+    ON CONFLICT (kind, game_id, user_id, related_user_id, key, value)
+    WHERE resolved_at IS NULL
+    DO NOTHING
+    RETURNING *`,
+		alert.Kind,
+		alert.GameId,
+		alert.UserId,
+		alert.RelatedUserId,
+		alert.Key,
+		alert.Value,
+	)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	return err
+}
+
+func (e *Env) GetAccountAlertById(id string) (*AccountAlert, error) {
+	var alert AccountAlert
+	err := e.Db.Get(
+		&alert, `
+    SELECT *
+    FROM account_alert
+    WHERE id=$1`,
+		id,
+	)
+	return &alert, BetterGetterErrors(err)
+}
+
+func (e *Env) GetAccountAlerts(
+	modifier *QueryModifier,
+) ([]AccountAlert, error) {
+	alerts := make([]AccountAlert, 0)
+	sql, args, err := modifier.ToSql("account_alert", "*")
+	if err != nil {
+		return alerts, err
+	}
+
+	err = e.Db.Select(&alerts, sql, args...)
+	return alerts, BetterGetterErrors(err)
+}
+
+func (e *Env) UpdateAccountAlert(alert *AccountAlert) error {
+	return e.Db.Get(
+		alert, `
+    UPDATE account_alert
+    SET
+      resolved_at=$2,
+      resolved_by=$3
+    WHERE id=$1
+    RETURNING *`,
+		alert.Id,
+		alert.ResolvedAt,
+		alert.ResolvedBy,
+	)
+}
+
+// RelatedAccount is another user whose user-game pair of GameId has the same
+// Value under Key as one of the user's, nullified pairs included.
+type RelatedAccount struct {
+	UserId string `db:"user_id" json:"userId"`
+	GameId string `db:"game_id" json:"gameId"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+}
+
+func (e *Env) GetRelatedAccounts(userId string) ([]RelatedAccount, error) {
+	related := make([]RelatedAccount, 0)
+	err := e.Db.Select(
+		&related, `
+    SELECT DISTINCT other.user_id, mine.game_id, kv.key, kv.value
+    FROM user_game mine
+    CROSS JOIN LATERAL jsonb_each_text(mine.data) kv
+    JOIN user_game other ON
+      other.game_id=mine.game_id AND
+      other.user_id<>mine.user_id AND
+      other.data->>kv.key=kv.value
+    WHERE
+      mine.user_id=$1 AND
+      kv.value<>''
+    ORDER BY other.user_id, mine.game_id, kv.key`,
+		userId,
+	)
+	return related, BetterGetterErrors(err)
+}
+
+// GetUserIdsByGameData lists the users, other than userId, whose valid pairs
+// of the game have value under key.
+func (e *Env) GetUserIdsByGameData(
+	gameId, key, value, userId string,
+) ([]string, error) {
+	userIds := make([]string, 0)
+	err := e.Db.Select(
+		&userIds, `
+    SELECT DISTINCT user_id
+    FROM user_game
+    WHERE
+      game_id=$1 AND
+      data->>$2=$3 AND
+      user_id<>$4 AND
+      nullified_at IS NULL`,
+		gameId,
+		key,
+		value,
+		userId,
+	)
+	return userIds, BetterGetterErrors(err)
+}
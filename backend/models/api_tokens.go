@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+
+	"app/utils"
+)
+
+// ApiTokenPrefix tells API tokens apart from session tokens, which never contain
+// a dot.
+const ApiTokenPrefix = "pat."
+
+type ApiToken struct {
+	Id         string         `json:"id"`
+	TokenHash  []byte         `db:"token" json:"-"`
+	Token      string         `db:"-" json:"token,omitempty"` // filled only upon creation
+	UserId     string         `db:"user_id" json:"userId"`
+	Name       string         `json:"name"`
+	Scopes     pq.StringArray `json:"scopes"`
+	CreatedAt  time.Time      `db:"created_at" json:"createdAt"`
+	ExpiresAt  time.Time      `db:"expires_at" json:"expiresAt"`
+	LastUsedAt *time.Time     `db:"last_used_at" json:"lastUsedAt"`
+	LastUsedIp *string        `db:"last_used_ip" json:"lastUsedIp"`
+}
+
+func (t *ApiToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *Env) CreateApiToken(apiToken *ApiToken) error {
+	token, err := utils.GenerateToken(32, false)
+	if err != nil {
+		return err
+	}
+
+	token = ApiTokenPrefix + token
+	err = e.Db.Get(
+		apiToken, `
+    INSERT INTO api_token (token, user_id, name, scopes, expires_at)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING *`,
+		utils.Blake2b256(token),
+		apiToken.UserId,
+		apiToken.Name,
+		apiToken.Scopes,
+		apiToken.ExpiresAt,
+	)
+	apiToken.Token = token
+	return err
+}
+
+func (e *Env) GetApiTokenById(id string) (*ApiToken, error) {
+	var apiToken ApiToken
+	err := e.Db.Get(
+		&apiToken, `
+    SELECT *
+    FROM api_token
+    WHERE id=$1`,
+		id,
+	)
+	return &apiToken, BetterGetterErrors(err)
+}
+
+func (e *Env) GetApiTokenByToken(token string) (*ApiToken, error) {
+	var apiToken ApiToken
+	err := e.Db.Get(
+		&apiToken, `
+    SELECT *
+    FROM api_token
+    WHERE token=$1`,
+		utils.Blake2b256(token),
+	)
+	return &apiToken, BetterGetterErrors(err)
+}
+
+func (e *Env) GetApiTokens(modifier *QueryModifier) ([]ApiToken, error) {
+	apiTokens := make([]ApiToken, 0)
+	sql, args, err := modifier.ToSql("api_token", "*")
+	if err != nil {
+		return apiTokens, err
+	}
+
+	err = e.Db.Select(&apiTokens, sql, args...)
+	return apiTokens, err
+}
+
+func (e *Env) UpdateApiTokenLastUsed(apiToken *ApiToken) error {
+	_, err := e.Db.Exec(`
+    UPDATE api_token
+    SET last_used_at=$1, last_used_ip=$2
+    WHERE id=$3`,
+		apiToken.LastUsedAt,
+		apiToken.LastUsedIp,
+		apiToken.Id,
+	)
+	return err
+}
+
+func (e *Env) DeleteApiToken(apiToken *ApiToken) error {
+	_, err := e.Db.Exec(`
+    DELETE FROM api_token
+    WHERE id=$1`,
+		apiToken.Id,
+	)
+	return err
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Audit is a single column change, recorded by the audit triggers from
+// migration 0009. ChangedFrom is the value before the change, cast to text.
+type Audit struct {
+	Id          string    `json:"id"`
+	TableName   string    `db:"table_name" json:"tableName"`
+	RowId       string    `db:"row_id" json:"rowId"`
+	ColumnName  string    `db:"column_name" json:"columnName"`
+	ChangedAt   time.Time `db:"changed_at" json:"changedAt"`
+	ChangedFrom *string   `db:"changed_from" json:"changedFrom"`
+	ChangedBy   *string   `db:"changed_by" json:"changedBy"`
+}
+
+func (e *Env) GetAudits(modifier *QueryModifier) ([]Audit, error) {
+	audits := make([]Audit, 0)
+	sql, args, err := modifier.ToSql("audit", "*")
+	if err != nil {
+		return audits, err
+	}
+
+	err = e.Db.Select(&audits, sql, args...)
+	return audits, err
+}
+
+// NarrowAudits limits modifier to changes of columns, whatever the filters say.
+func NarrowAudits(modifier *QueryModifier, columns []string) {
+	modifier.Where = append(modifier.Where, sq.Eq{"column_name": columns})
+}
+
+func parseAuditFloat(value *string) (*float64, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(*value, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// getChangesAfter returns the changes made to rows of table strictly after
+// asOf, newest first, so that applying them in order leaves every column with
+// the changed_from of the earliest change after asOf, which is what it held at
+// the time.
+func (e *Env) getChangesAfter(
+	table string, ids []string, asOf time.Time,
+) ([]Audit, error) {
+	return e.GetAudits(&QueryModifier{
+		Filter: map[string]interface{}{"table_name": table, "row_id": ids},
+		Where:  []sq.Sqlizer{sq.Gt{"changed_at": asOf}},
+		Sort:   []string{"id DESC"},
+	})
+}
+
+// parseAuditTime reads a timestamptz cast to text, in the ISO DateStyle.
+func parseAuditTime(value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	var t time.Time
+	var err error
+	for _, layout := range []string{
+		"2006-01-02 15:04:05.999999999-07",
+		"2006-01-02 15:04:05.999999999-07:00",
+	} {
+		t, err = time.Parse(layout, *value)
+		if err == nil {
+			return &t, nil
+		}
+	}
+
+	return nil, err
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+var ErrBadCursor = errors.New("bad cursor")
+
+// Cursor points right after a row, for keyset pagination. It remembers the
+// sort it was made for, since it means nothing under any other one, and the
+// row's values of the sort columns, nil standing for NULL. Clients only ever
+// see it encoded, and should treat it as opaque.
+type Cursor struct {
+	Sort   []string  `json:"s"`
+	Values []*string `json:"v"`
+
+	aliases map[string]string
+}
+
+// NewCursor makes an encoded cursor pointing right after row, which has to be
+// a struct (or a pointer to one) with all of the sort columns in it.
+func NewCursor(sort []string, row interface{}) (string, error) {
+	cursor := Cursor{Sort: sort, Values: make([]*string, len(sort))}
+	for i, col := range sort {
+		value, ok := columnValue(reflect.ValueOf(row), sortColumn(col))
+		if !ok {
+			return "", fmt.Errorf("no %s column in %T", sortColumn(col), row)
+		}
+
+		cursor.Values[i] = value
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ParseCursor decodes a cursor made by NewCursor, making sure it was made for
+// the same sort.
+func ParseCursor(s string, sort []string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrBadCursor
+	}
+
+	var cursor Cursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil || len(cursor.Values) != len(cursor.Sort) {
+		return nil, ErrBadCursor
+	}
+
+	if strings.Join(cursor.Sort, ",") != strings.Join(sort, ",") {
+		return nil, ErrBadCursor
+	}
+
+	return &cursor, nil
+}
+
+// withAliases is the cursor with the given sort columns standing for
+// expressions, like is_admin for isAdminSql, since WHERE, unlike ORDER BY,
+// can't refer to aliases.
+func (cursor *Cursor) withAliases(aliases map[string]string) *Cursor {
+	c := *cursor
+	c.aliases = aliases
+	return &c
+}
+
+// column is what WHERE has to call a sort column by.
+func (cursor *Cursor) column(col string) string {
+	column := sortColumn(col)
+	if expr, ok := cursor.aliases[column]; ok {
+		return "(" + expr + ")"
+	}
+
+	return column
+}
+
+// ToSql makes Cursor an sq.Sqlizer, matching the rows that come after it,
+// like (a > x) OR (a = x AND b > y) for "a ASC, b ASC". NULLs come last in
+// ascending order and first in descending, like PostgreSQL sorts them.
+func (cursor *Cursor) ToSql() (string, []interface{}, error) {
+	or := sq.Or{}
+	for i, col := range cursor.Sort {
+		after := cursorAfter(cursor.column(col), col, cursor.Values[i])
+		if after == nil {
+			continue
+		}
+
+		and := sq.And{}
+		for j := 0; j < i; j++ {
+			and = append(and, cursorEq(cursor.column(cursor.Sort[j]),
+				cursor.Values[j]))
+		}
+
+		or = append(or, append(and, after))
+	}
+
+	if len(or) == 0 {
+		return "FALSE", nil, nil
+	}
+
+	return or.ToSql()
+}
+
+func cursorEq(column string, value *string) sq.Sqlizer {
+	if value == nil {
+		return sq.Eq{column: nil}
+	}
+
+	return sq.Eq{column: *value}
+}
+
+// cursorAfter matches the values of a single column that come after value,
+// nil if there are none. col is the sort entry, direction included.
+func cursorAfter(column, col string, value *string) sq.Sqlizer {
+	if strings.HasSuffix(col, " DESC") {
+		if value == nil {
+			return sq.NotEq{column: nil}
+		}
+
+		return sq.Lt{column: *value}
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	return sq.Or{sq.Gt{column: *value}, sq.Eq{column: nil}}
+}
+
+// sortColumn strips the direction off of a QueryModifier.Sort entry.
+func sortColumn(col string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(col, " ASC"), " DESC")
+}
+
+// columnValue finds the field a column gets scanned into, going by db tags
+// and lowercased names like sqlx does, and formats it as a string.
+func columnValue(v reflect.Value, column string) (*string, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			if value, ok := columnValue(v.Field(i), column); ok {
+				return value, true
+			}
+
+			continue
+		}
+
+		if fieldColumn(field) == column {
+			return formatColumn(v.Field(i)), true
+		}
+	}
+
+	return nil, false
+}
+
+// Columns lists the columns that row's type gets scanned from, embedded
+// structs included, like the Public halves of models.
+func Columns(row interface{}) []string {
+	return typeColumns(reflect.TypeOf(row))
+}
+
+func typeColumns(t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	columns := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			columns = append(columns, typeColumns(field.Type)...)
+		} else if name := fieldColumn(field); name != "-" {
+			columns = append(columns, name)
+		}
+	}
+
+	return columns
+}
+
+// fieldColumn goes by db tags and lowercased names, like sqlx does.
+func fieldColumn(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("db"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+
+	return name
+}
+
+func formatColumn(v reflect.Value) *string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	var s string
+	if t, ok := v.Interface().(time.Time); ok {
+		s = t.Format(time.RFC3339Nano)
+	} else {
+		s = fmt.Sprint(v.Interface())
+	}
+
+	return &s
+}
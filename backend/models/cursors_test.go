@@ -0,0 +1,133 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	sort := []string{"score_x DESC", "id ASC"}
+	score := 3.5
+	row := MatchPublic{Id: "7", ScoreX: &score}
+	s, err := NewCursor(sort, &row)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cursor, err := ParseCursor(s, sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *cursor.Values[0] != "3.5" || *cursor.Values[1] != "7" {
+		t.Errorf("got %v", cursor.Values)
+	}
+
+	_, err = ParseCursor(s, []string{"id ASC"})
+	if err != ErrBadCursor {
+		t.Errorf("other sort: got %v, expected ErrBadCursor", err)
+	}
+}
+
+func TestCursorMissingColumn(t *testing.T) {
+	_, err := NewCursor([]string{"email ASC"}, UserPublic{})
+	if err == nil {
+		t.Error("made a cursor out of a column that isn't there")
+	}
+}
+
+func TestCursorToSql(t *testing.T) {
+	x, y := "3", "7"
+	cases := []struct {
+		sort   []string
+		values []*string
+		sql    string
+		args   []interface{}
+	}{{
+		[]string{"score_x ASC", "id ASC"},
+		[]*string{&x, &y},
+		"(((score_x > ? OR score_x IS NULL)) OR " +
+			"(score_x = ? AND (id > ? OR id IS NULL)))",
+		[]interface{}{"3", "3", "7"},
+	}, {
+		[]string{"score_x DESC", "id ASC"},
+		[]*string{nil, &y},
+		"((score_x IS NOT NULL) OR " +
+			"(score_x IS NULL AND (id > ? OR id IS NULL)))",
+		[]interface{}{"7"},
+	}}
+
+	for _, c := range cases {
+		sql, args, err := (&Cursor{Sort: c.sort, Values: c.values}).ToSql()
+		if err != nil {
+			t.Errorf("%v: %v", c.sort, err)
+		} else if sql != c.sql {
+			t.Errorf("%v: got %q, expected %q", c.sort, sql, c.sql)
+		} else if !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%v: got %#v, expected %#v", c.sort, args, c.args)
+		}
+	}
+}
+
+// TestCursorAlias makes sure that a cursor over an aliased column, like
+// is_admin, refers to its expression, since WHERE can't see aliases.
+func TestCursorAlias(t *testing.T) {
+	modifier := &QueryModifier{Sort: []string{"is_admin DESC"}}
+	s, err := NewCursor(
+		[]string{"is_admin DESC", "id ASC"},
+		UserPublic{Id: "7", IsAdmin: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = modifier.Paginate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sql, _, err := modifier.ToSql(`"user"`, "id", isAdminSql)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := strings.Index(sql, `FROM "user"`)
+	where := sql[from:strings.Index(sql, "ORDER BY")]
+	if strings.Contains(where, "is_admin") ||
+		!strings.Contains(where, "(EXISTS (") {
+		t.Errorf("is_admin isn't replaced with its expression: %s", where)
+	}
+
+	if !strings.HasSuffix(sql, "ORDER BY is_admin DESC, id ASC") {
+		t.Errorf("ORDER BY should keep the alias: %s", sql)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	columns := Columns(MatchReport{})
+	public := Columns(&MatchReportPublic{})
+	for _, column := range []string{"id", "match_id", "score_x"} {
+		if !contains(public, column) {
+			t.Errorf("public columns lack %s: %v", column, public)
+		}
+	}
+
+	for _, column := range []string{"agreed_upon_at", "updated_by"} {
+		if contains(public, column) {
+			t.Errorf("public columns have %s", column)
+		} else if !contains(columns, column) {
+			t.Errorf("columns lack %s: %v", column, columns)
+		}
+	}
+}
+
+func contains(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+
+	return false
+}
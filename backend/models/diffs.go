@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Change is a piece of a revision, Op being one of "equal", "insert" and
+// "delete".
+type Change struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Revision is a single edit of a markdown column, rebuilt from the reverse
+// patches stored by Diff.
+type Revision struct {
+	Id        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy"`
+	Before    string    `json:"before"`
+	After     string    `json:"after"`
+	Changes   []Change  `json:"changes"`
+}
+
+var changeOps = map[dmp.Operation]string{
+	dmp.DiffEqual:  "equal",
+	dmp.DiffInsert: "insert",
+	dmp.DiffDelete: "delete",
+}
+
+// GetRevisions walks the column back in time, starting from its current value,
+// and returns the edits newest first.
+func (e *Env) GetRevisions(
+	tableName, rowId, columnName, current string,
+) ([]Revision, error) {
+	var diffs []struct {
+		Id           string
+		ReversePatch string    `db:"reverse_patch"`
+		CreatedAt    time.Time `db:"created_at"`
+		CreatedBy    string    `db:"created_by"`
+	}
+	err := e.Db.Select(
+		&diffs, `
+    SELECT id, reverse_patch, created_at, created_by
+    FROM diff
+    WHERE table_name=$1 AND row_id=$2 AND column_name=$3
+    ORDER BY id DESC`,
+		tableName,
+		rowId,
+		columnName,
+	)
+	if err != nil {
+		return nil, BetterGetterErrors(err)
+	}
+
+	revisions := make([]Revision, 0, len(diffs))
+	after := current
+	for _, diff := range diffs {
+		patches, err := e.Dmp.PatchFromText(diff.ReversePatch)
+		if err != nil {
+			return nil, err
+		}
+
+		before, _ := e.Dmp.PatchApply(patches, after)
+		revision := Revision{
+			Id:        diff.Id,
+			CreatedAt: diff.CreatedAt,
+			CreatedBy: diff.CreatedBy,
+			Before:    before,
+			After:     after,
+			Changes:   []Change{},
+		}
+
+		for _, d := range e.Dmp.DiffCleanupSemantic(
+			e.Dmp.DiffMain(before, after, false),
+		) {
+			revision.Changes = append(revision.Changes, Change{
+				changeOps[d.Type], d.Text,
+			})
+		}
+
+		revisions = append(revisions, revision)
+		after = before
+	}
+
+	return revisions, nil
+}
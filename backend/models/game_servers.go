@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	GameServerOffline    = "offline"
+	GameServerConnecting = "connecting"
+	GameServerOnline     = "online"
+)
+
+type GameServerPublic struct {
+	Id          string     `json:"id"`
+	GameId      string     `db:"game_id" json:"gameId"`
+	Address     string     `json:"address"`
+	Name        *string    `json:"name"`
+	Status      string     `json:"status"`
+	Players     int        `json:"players"`
+	MaxPlayers  int        `db:"max_players" json:"maxPlayers"`
+	FreeSlots   int        `db:"free_slots" json:"freeSlots"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+	ConnectedAt *time.Time `db:"connected_at" json:"connectedAt"`
+	CheckedAt   *time.Time `db:"checked_at" json:"checkedAt"`
+}
+
+type GameServer struct {
+	GameServerPublic
+	Error *string `json:"error"` // what went wrong last, admins only
+}
+
+// RegisterGameServer creates a server by its address, or takes over the one
+// that's already there, which is what the worker does with its config on
+// every launch.
+func (e *Env) RegisterGameServer(gameServer *GameServer) error {
+	return e.Db.Get(
+		gameServer, `
+    INSERT INTO game_server (game_id, address)
+    VALUES ($1, $2)
+    ON CONFLICT (address) DO UPDATE
+    SET game_id=EXCLUDED.game_id
+    RETURNING *`,
+		gameServer.GameId,
+		gameServer.Address,
+	)
+}
+
+func (e *Env) GetGameServerById(id string) (*GameServer, error) {
+	var gameServer GameServer
+	err := e.Db.Get(
+		&gameServer, `
+    SELECT *
+    FROM game_server
+    WHERE id=$1`,
+		id,
+	)
+	return &gameServer, BetterGetterErrors(err)
+}
+
+func (e *Env) GetGameServers(modifier *QueryModifier) ([]GameServer, error) {
+	gameServers := make([]GameServer, 0)
+	sql, args, err := modifier.ToSql("game_server", "*")
+	if err != nil {
+		return gameServers, err
+	}
+
+	err = e.Db.Select(&gameServers, sql, args...)
+	return gameServers, BetterGetterErrors(err)
+}
+
+func (e *Env) UpdateGameServer(gameServer *GameServer) error {
+	gameServer.FreeSlots = gameServer.MaxPlayers - gameServer.Players
+	if gameServer.FreeSlots < 0 {
+		gameServer.FreeSlots = 0
+	}
+
+	return e.Db.Get(
+		gameServer, `
+    UPDATE game_server
+    SET
+      name=$2,
+      status=$3,
+      players=$4,
+      max_players=$5,
+      free_slots=$6,
+      error=$7,
+      connected_at=$8,
+      checked_at=$9
+    WHERE id=$1
+    RETURNING *`,
+		gameServer.Id,
+		gameServer.Name,
+		gameServer.Status,
+		gameServer.Players,
+		gameServer.MaxPlayers,
+		gameServer.FreeSlots,
+		gameServer.Error,
+		gameServer.ConnectedAt,
+		gameServer.CheckedAt,
+	)
+}
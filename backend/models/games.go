@@ -74,6 +74,18 @@ func (e *Env) GetGameBySlug(slug string) (*Game, error) {
 	return &game, BetterGetterErrors(err)
 }
 
+func (e *Env) GetGameByVerificationHandle(handle string) (*Game, error) {
+	var game Game
+	err := e.Db.Get(
+		&game, `
+    SELECT *
+    FROM game
+    WHERE verification_handle=$1`,
+		handle,
+	)
+	return &game, BetterGetterErrors(err)
+}
+
 func (e *Env) GetGames(modifier *QueryModifier) ([]Game, error) {
 	games := make([]Game, 0)
 	sql, args, err := modifier.ToSql("game", "*")
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// IdempotencyKey is a client-chosen key for a POST, along with the response
+// to it once there is one.
+type IdempotencyKey struct {
+	UserId      string    `db:"user_id" json:"userId"`
+	Key         string    `json:"key"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	RequestHash []byte    `db:"request_hash" json:"-"`
+	Status      *int      `json:"status"`
+	Body        []byte    `json:"-"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+// ClaimIdempotencyKey records that a request with the key is under way, and
+// reports true, unless there's one already, in which case it fills key with
+// that one and reports false. Claims made before abandonedBefore that never
+// got a response are taken over, since whoever made them is long gone.
+func (e *Env) ClaimIdempotencyKey(
+	key *IdempotencyKey, abandonedBefore time.Time,
+) (bool, error) {
+	err := e.Db.Get(
+		key, `
+    INSERT INTO idempotency_key (user_id, key, method, path, request_hash)
+    VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT (user_id, key) DO UPDATE
+    SET method = EXCLUDED.method,
+        path = EXCLUDED.path,
+        request_hash = EXCLUDED.request_hash,
+        created_at = now()
+    WHERE idempotency_key.status IS NULL AND
+      idempotency_key.created_at<$6
+    RETURNING *`,
+		key.UserId,
+		key.Key,
+		key.Method,
+		key.Path,
+		key.RequestHash,
+		abandonedBefore,
+	)
+	if err == nil {
+		return true, nil
+	} else if err != sql.ErrNoRows {
+		return false, err
+	}
+
+	err = e.Db.Get(
+		key, `
+    SELECT *
+    FROM idempotency_key
+    WHERE user_id=$1 AND key=$2`,
+		key.UserId,
+		key.Key,
+	)
+	return false, BetterGetterErrors(err)
+}
+
+func (e *Env) UpdateIdempotencyKey(key *IdempotencyKey) error {
+	_, err := e.Db.Exec(`
+    UPDATE idempotency_key
+    SET status=$3, body=$4
+    WHERE user_id=$1 AND key=$2`,
+		key.UserId,
+		key.Key,
+		key.Status,
+		key.Body,
+	)
+	return err
+}
+
+func (e *Env) DeleteIdempotencyKey(key *IdempotencyKey) error {
+	_, err := e.Db.Exec(`
+    DELETE FROM idempotency_key
+    WHERE user_id=$1 AND key=$2`,
+		key.UserId,
+		key.Key,
+	)
+	return err
+}
+
+func (e *Env) DeleteIdempotencyKeysOlderThan(t time.Time) error {
+	_, err := e.Db.Exec(`
+    DELETE FROM idempotency_key
+    WHERE created_at<$1`,
+		t,
+	)
+	return err
+}
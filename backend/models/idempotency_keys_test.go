@@ -0,0 +1,42 @@
+package models_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"app/models"
+	"app/models/modelstest"
+)
+
+// TestClaimIdempotencyKey makes sure that a retry gets to see the hash of the
+// original request, to tell whether it's the same one.
+func TestClaimIdempotencyKey(t *testing.T) {
+	m := modelstest.New(t)
+	user := modelstest.User(t, m)
+	key := &models.IdempotencyKey{
+		UserId:      user.Id,
+		Key:         modelstest.Unique(t),
+		Method:      "POST",
+		Path:        "/teams",
+		RequestHash: []byte("first"),
+	}
+	abandonedBefore := time.Now().Add(-time.Minute)
+	claimed, err := m.ClaimIdempotencyKey(key, abandonedBefore)
+	if err != nil {
+		t.Fatal(err)
+	} else if !claimed {
+		t.Fatal("a fresh key wasn't claimed")
+	}
+
+	retry := *key
+	retry.RequestHash = []byte("second")
+	claimed, err = m.ClaimIdempotencyKey(&retry, abandonedBefore)
+	if err != nil {
+		t.Fatal(err)
+	} else if claimed {
+		t.Fatal("a key in progress was claimed again")
+	} else if !bytes.Equal(retry.RequestHash, []byte("first")) {
+		t.Errorf("got hash %q, expected the first one", retry.RequestHash)
+	}
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+)
+
+type Identity struct {
+	Id         string    `json:"id"`
+	UserId     string    `db:"user_id" json:"userId"`
+	Provider   string    `json:"provider"`
+	Subject    string    `json:"subject"`
+	Email      string    `json:"email"`
+	Name       string    `json:"name"`
+	Link       string    `json:"link"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+	LastUsedAt time.Time `db:"last_used_at" json:"lastUsedAt"`
+}
+
+func (e *Env) CreateIdentity(identity *Identity) error {
+	return e.Db.Get(
+		identity, `
+    INSERT INTO identity (user_id, provider, subject, email, name, link)
+    VALUES ($1, $2, $3, $4, $5, $6)
+    RETURNING *`,
+		identity.UserId,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		identity.Name,
+		identity.Link,
+	)
+}
+
+func (e *Env) GetIdentityById(id string) (*Identity, error) {
+	var identity Identity
+	err := e.Db.Get(
+		&identity, `
+    SELECT *
+    FROM identity
+    WHERE id=$1`,
+		id,
+	)
+	return &identity, BetterGetterErrors(err)
+}
+
+func (e *Env) GetIdentityByProviderSubject(
+	provider, subject string,
+) (*Identity, error) {
+	var identity Identity
+	err := e.Db.Get(
+		&identity, `
+    SELECT *
+    FROM identity
+    WHERE provider=$1 AND subject=$2`,
+		provider,
+		subject,
+	)
+	return &identity, BetterGetterErrors(err)
+}
+
+func (e *Env) GetIdentities(modifier *QueryModifier) ([]Identity, error) {
+	identities := make([]Identity, 0)
+	sql, args, err := modifier.ToSql("identity", "*")
+	if err != nil {
+		return identities, err
+	}
+
+	err = e.Db.Select(&identities, sql, args...)
+	return identities, err
+}
+
+func (e *Env) UpdateIdentity(identity *Identity) error {
+	return e.Db.Get(
+		identity, `
+    UPDATE identity
+    SET email=$2, name=$3, link=$4, last_used_at=now()
+    WHERE id=$1
+    RETURNING *`,
+		identity.Id,
+		identity.Email,
+		identity.Name,
+		identity.Link,
+	)
+}
+
+func (e *Env) DeleteIdentity(identity *Identity) error {
+	_, err := e.Db.Exec(`
+    DELETE FROM identity
+    WHERE id=$1`,
+		identity.Id,
+	)
+	return err
+}
@@ -35,6 +35,8 @@ type MatchReport struct {
 	AgreedUponBy *string    `db:"agreed_upon_by" json:"agreedUponBy"`
 	CreatedAt    time.Time  `db:"created_at" json:"createdAt"`
 	CreatedBy    string     `db:"created_by" json:"createdBy"`
+	UpdatedAt    *time.Time `db:"updated_at" json:"updatedAt"`
+	UpdatedBy    *string    `db:"updated_by" json:"updatedBy"`
 }
 
 func (e *Env) ClaimMatchReportId() (id string, err error) {
@@ -126,16 +128,75 @@ func (e *Env) GetMatchReports(modifier *QueryModifier) ([]MatchReport, error) {
 	return matchReports, err
 }
 
-func (e *Env) UpdateMatchReport(matchReport *MatchReport) error {
+// GetMatchReportsAsOf is GetMatchReports of ids rewound to asOf, like
+// GetMatchesAsOf. Reports don't change after they're filed, other than by
+// being agreed upon, so that's all there is to rewind, and reports filed
+// after asOf are left out.
+func (e *Env) GetMatchReportsAsOf(
+	ids []string, asOf time.Time,
+) ([]MatchReport, error) {
+	if len(ids) == 0 {
+		return make([]MatchReport, 0), nil
+	}
+
+	all, err := e.GetMatchReports(&QueryModifier{
+		Filter: map[string]interface{}{"id": ids},
+	})
+	if err != nil {
+		return all, err
+	}
+
+	matchReports := make([]MatchReport, 0, len(all))
+	for _, matchReport := range all {
+		if !matchReport.CreatedAt.After(asOf) {
+			matchReports = append(matchReports, matchReport)
+		}
+	}
+
+	byId := make(map[string]*MatchReport)
+	for i := range matchReports {
+		byId[matchReports[i].Id] = &matchReports[i]
+	}
+
+	audits, err := e.getChangesAfter("match_report", ids, asOf)
+	if err != nil {
+		return matchReports, err
+	}
+
+	for _, audit := range audits {
+		matchReport, ok := byId[audit.RowId]
+		if !ok {
+			continue
+		}
+
+		switch audit.ColumnName {
+		case "agreed_upon_at":
+			matchReport.AgreedUponAt, err = parseAuditTime(audit.ChangedFrom)
+		case "agreed_upon_by":
+			matchReport.AgreedUponBy = audit.ChangedFrom
+		}
+
+		if err != nil {
+			return matchReports, err
+		}
+	}
+
+	return matchReports, nil
+}
+
+func (e *Env) UpdateMatchReport(
+	matchReport *MatchReport, updatedBy string,
+) error {
 	err := e.Db.Get(
 		matchReport, `
     UPDATE match_report
-    SET agreed_upon_at=$2, agreed_upon_by=$3
+    SET agreed_upon_at=$2, agreed_upon_by=$3, updated_by=$4
     WHERE id=$1
     RETURNING *`,
 		matchReport.Id,
 		matchReport.AgreedUponAt,
 		matchReport.AgreedUponBy,
+		updatedBy,
 	)
 	return err
 }
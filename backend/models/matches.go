@@ -204,3 +204,73 @@ func (match *Match) UserIsLeaderOf(
 
 	return res, nil, http.StatusOK
 }
+
+// GetMatchesAsOf is GetMatches rewound to asOf, through the audit log. Matches
+// created later are left out. Changes made before the audit covered scores
+// (migration 0045) can't be rewound, so these come out as they are now.
+func (e *Env) GetMatchesAsOf(
+	modifier *QueryModifier, asOf time.Time,
+) ([]Match, error) {
+	all, err := e.GetMatches(modifier)
+	if err != nil {
+		return all, err
+	}
+
+	matches := make([]Match, 0, len(all))
+	for _, match := range all {
+		if !match.CreatedAt.After(asOf) {
+			matches = append(matches, match)
+		}
+	}
+
+	if len(matches) == 0 {
+		return matches, nil
+	}
+
+	byId := make(map[string]*Match)
+	ids := make([]string, 0, len(matches))
+	for i := range matches {
+		byId[matches[i].Id] = &matches[i]
+		ids = append(ids, matches[i].Id)
+	}
+
+	audits, err := e.getChangesAfter("match", ids, asOf)
+	if err != nil {
+		return matches, err
+	}
+
+	for _, audit := range audits {
+		err = byId[audit.RowId].rewind(audit.ColumnName, audit.ChangedFrom)
+		if err != nil {
+			return matches, err
+		}
+	}
+
+	return matches, nil
+}
+
+func (match *Match) rewind(column string, value *string) error {
+	var err error
+	switch column {
+	case "team_x":
+		match.TeamX = value
+	case "team_y":
+		match.TeamY = value
+	case "match_report_id":
+		match.MatchReportId = value
+	case "score_x":
+		match.ScoreX, err = parseAuditFloat(value)
+	case "score_y":
+		match.ScoreY, err = parseAuditFloat(value)
+	case "raw_score_x":
+		match.RawScoreX, err = parseAuditFloat(value)
+	case "raw_score_y":
+		match.RawScoreY, err = parseAuditFloat(value)
+	case "is_overridden":
+		match.IsOverridden = value != nil && *value == "true"
+	case "is_penalized":
+		match.IsPenalized = value != nil && *value == "true"
+	}
+
+	return err
+}
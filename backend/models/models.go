@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"reflect"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
@@ -85,11 +86,14 @@ func (a JSONArray) Value() (driver.Value, error) {
 	return json.Marshal(a)
 }
 
-func (a JSONArray) Scan(src interface{}) error {
+// Scan has to have a pointer receiver, or else whatever it unmarshals is lost.
+func (a *JSONArray) Scan(src interface{}) error {
 	if v := reflect.ValueOf(src); !v.IsValid() || v.IsNil() {
+		*a = nil
 		return nil
 	} else if data, ok := src.([]byte); ok {
-		return json.Unmarshal(data, &a)
+		*a = nil
+		return json.Unmarshal(data, a)
 	}
 
 	return errors.New("JSONArray: scan source was not []byte")
@@ -105,11 +109,13 @@ func (m JSONMap) Value() (driver.Value, error) {
 	return json.Marshal(m)
 }
 
-func (m JSONMap) Scan(src interface{}) error {
+func (m *JSONMap) Scan(src interface{}) error {
 	if v := reflect.ValueOf(src); !v.IsValid() || v.IsNil() {
+		*m = nil
 		return nil
 	} else if data, ok := src.([]byte); ok {
-		return json.Unmarshal(data, &m)
+		*m = nil
+		return json.Unmarshal(data, m)
 	}
 
 	return errors.New("JSONMap: scan source was not []byte")
@@ -140,3 +146,33 @@ func (e *Env) Diff(
 	)
 	return err
 }
+
+// GetVersion tells when a row was last changed, or created if it never was,
+// for tables that keep track of that.
+func (e *Env) GetVersion(table, id string) (time.Time, error) {
+	var version time.Time
+	err := e.Db.Get(
+		&version, `
+    SELECT COALESCE(updated_at, created_at)
+    FROM `+pq.QuoteIdentifier(table)+`
+    WHERE id=$1`,
+		id,
+	)
+	return version, BetterGetterErrors(err)
+}
+
+// LockVersion is GetVersion that also locks the row until the end of the
+// transaction, so that the version can't change before whoever's checking it
+// is done.
+func (e *Env) LockVersion(table, id string) (time.Time, error) {
+	var version time.Time
+	err := e.Db.Get(
+		&version, `
+    SELECT COALESCE(updated_at, created_at)
+    FROM `+pq.QuoteIdentifier(table)+`
+    WHERE id=$1
+    FOR UPDATE`,
+		id,
+	)
+	return version, BetterGetterErrors(err)
+}
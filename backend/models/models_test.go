@@ -0,0 +1,29 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONScan(t *testing.T) {
+	var m JSONMap
+	err := m.Scan([]byte(`{"eaId": "EA_1"}`))
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(m, JSONMap{"eaId": "EA_1"}) {
+		t.Errorf("got %v", m)
+	}
+
+	err = m.Scan(nil)
+	if err != nil || m != nil {
+		t.Errorf("NULL scanned into %v, %v", m, err)
+	}
+
+	var a JSONArray
+	err = a.Scan([]byte(`["a", "b"]`))
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(a, JSONArray{"a", "b"}) {
+		t.Errorf("got %v", a)
+	}
+}
@@ -0,0 +1,66 @@
+// Package modelstest connects tests to a database of their own, named by
+// TEST_POSTGRES_URL, which scripts/db-migrate has to have been run against.
+// Tests that need one are skipped without it. They share it, and don't clean
+// up after themselves, so whatever they create has to be unique.
+package modelstest
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"app/models"
+	"app/utils"
+)
+
+func New(t testing.TB) *models.Env {
+	url := os.Getenv("TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TEST_POSTGRES_URL isn't set")
+	}
+
+	dbcs, err := pq.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlx.Open("postgres", dbcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+	return models.New(db)
+}
+
+// Unique returns a random string to make names and such unique with.
+func Unique(t testing.TB) string {
+	s, err := utils.GenerateToken(8, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.ToLower(strings.TrimRight(s, "="))
+}
+
+// User creates a user with a unique email.
+func User(t testing.TB, m *models.Env) *models.User {
+	user := &models.User{
+		Email:    Unique(t) + "@example.com",
+		Password: []byte{},
+	}
+	err := m.CreateUser(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"app/utils"
+)
+
+type OAuthState struct {
+	TokenHash []byte    `db:"token" json:"-"`
+	Token     string    `db:"-" json:"-"` // filled only upon creation
+	Provider  string    `json:"provider"`
+	Verifier  string    `json:"-"`
+	UserId    *string   `db:"user_id" json:"userId"`
+	Remember  bool      `json:"remember"`
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+func (e *Env) CreateOAuthState(state *OAuthState) error {
+	token, err := utils.GenerateToken(32, false)
+	if err != nil {
+		return err
+	}
+
+	err = e.Db.Get(
+		state, `
+    INSERT INTO oauth_state (token, provider, verifier, user_id, remember)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING *`,
+		utils.Blake2b256(token),
+		state.Provider,
+		state.Verifier,
+		state.UserId,
+		state.Remember,
+	)
+	state.Token = token
+	return err
+}
+
+// DeleteOAuthStateByToken consumes a state, so that every one of them can be
+// used only once, no matter whether the login succeeds.
+func (e *Env) DeleteOAuthStateByToken(token string) (*OAuthState, error) {
+	var state OAuthState
+	err := e.Db.Get(
+		&state, `
+    DELETE FROM oauth_state
+    WHERE token=$1
+    RETURNING *`,
+		utils.Blake2b256(token),
+	)
+	return &state, BetterGetterErrors(err)
+}
+
+func (e *Env) DeleteOAuthStatesOlderThan(t time.Time) error {
+	_, err := e.Db.Exec(`
+    DELETE FROM oauth_state
+    WHERE created_at<$1`,
+		t,
+	)
+	return err
+}
@@ -0,0 +1,50 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"app/models"
+	"app/models/modelstest"
+	"app/utils"
+)
+
+func TestOAuthStateReuse(t *testing.T) {
+	m := modelstest.New(t)
+	state := &models.OAuthState{Provider: "mock", Verifier: "verifier"}
+	err := m.CreateOAuthState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumed, err := m.DeleteOAuthStateByToken(state.Token)
+	if err != nil {
+		t.Fatal(err)
+	} else if consumed.Verifier != "verifier" {
+		t.Errorf("got verifier %q", consumed.Verifier)
+	}
+
+	_, err = m.DeleteOAuthStateByToken(state.Token)
+	if err != utils.ErrNotFound {
+		t.Errorf("reused state: got %v, expected ErrNotFound", err)
+	}
+}
+
+func TestOAuthStateExpiry(t *testing.T) {
+	m := modelstest.New(t)
+	state := &models.OAuthState{Provider: "mock", Verifier: "verifier"}
+	err := m.CreateOAuthState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.DeleteOAuthStatesOlderThan(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.DeleteOAuthStateByToken(state.Token)
+	if err != utils.ErrNotFound {
+		t.Errorf("expired state: got %v, expected ErrNotFound", err)
+	}
+}
@@ -6,14 +6,22 @@ import (
 	"app/utils"
 )
 
+const (
+	OTPLogin  = "login"  // password reset, works as a verification too
+	OTPVerify = "verify" // email verification, sent upon sign-up and on request
+	OTPEmail  = "email"  // confirms the ownership of a new email address
+)
+
 type OTP struct {
 	TokenHash []byte    `db:"token" json:"-"`
 	Token     string    `db:"-" json:"token"` // filled only upon creation
 	UserId    string    `db:"user_id"`
+	Purpose   string    `db:"purpose"`
+	Email     *string   `db:"email"` // the new address, if Purpose is OTPEmail
 	CreatedAt time.Time `db:"created_at"`
 }
 
-func (e *Env) CreateOTP(userId string) (*OTP, error) {
+func (e *Env) CreateOTP(userId, purpose string, email *string) (*OTP, error) {
 	token, err := utils.GenerateToken(32, false)
 	if err != nil {
 		return nil, err
@@ -22,16 +30,33 @@ func (e *Env) CreateOTP(userId string) (*OTP, error) {
 	var otp OTP
 	err = e.Db.Get(
 		&otp, `
-    INSERT INTO otp (token, user_id)
-    VALUES ($1, $2)
+    INSERT INTO otp (token, user_id, purpose, email)
+    VALUES ($1, $2, $3, $4)
     RETURNING *`,
 		utils.Blake2b256(token),
 		userId,
+		purpose,
+		email,
 	)
 	otp.Token = token
 	return &otp, err
 }
 
+func (e *Env) GetLatestOTP(userId, purpose string) (*OTP, error) {
+	var otp OTP
+	err := e.Db.Get(
+		&otp, `
+    SELECT *
+    FROM otp
+    WHERE user_id=$1 AND purpose=$2
+    ORDER BY created_at DESC
+    LIMIT 1`,
+		userId,
+		purpose,
+	)
+	return &otp, BetterGetterErrors(err)
+}
+
 func (e *Env) DeleteOTPByToken(token string) (*OTP, error) {
 	var otp OTP
 	err := e.Db.Get(
@@ -43,3 +68,22 @@ func (e *Env) DeleteOTPByToken(token string) (*OTP, error) {
 	)
 	return &otp, BetterGetterErrors(err)
 }
+
+func (e *Env) DeleteOTPsByPurpose(userId, purpose string) error {
+	_, err := e.Db.Exec(`
+    DELETE FROM otp
+    WHERE user_id=$1 AND purpose=$2`,
+		userId,
+		purpose,
+	)
+	return err
+}
+
+func (e *Env) DeleteOTPsByUser(userId string) error {
+	_, err := e.Db.Exec(`
+    DELETE FROM otp
+    WHERE user_id=$1`,
+		userId,
+	)
+	return err
+}
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 )
@@ -12,7 +15,9 @@ type QueryModifier struct {
 	Offset uint64
 	Limit  uint64
 	Filter map[string]interface{}
+	Where  []sq.Sqlizer // everything that isn't a plain equality, ANDed
 	Sort   []string
+	After  *Cursor
 }
 
 func (modifier *QueryModifier) SetColumnFilter(
@@ -25,6 +30,47 @@ func (modifier *QueryModifier) SetColumnFilter(
 	modifier.Filter[column] = value
 }
 
+// SetRange limits column to [since, until), either end being optional.
+func (modifier *QueryModifier) SetRange(
+	column string, since, until *time.Time,
+) {
+	if since != nil {
+		modifier.Where = append(modifier.Where, sq.GtOrEq{column: *since})
+	}
+
+	if until != nil {
+		modifier.Where = append(modifier.Where, sq.Lt{column: *until})
+	}
+}
+
+// Paginate makes the sort total, by breaking ties with id, and skips
+// everything up to and including the row cursor points at, if any.
+func (modifier *QueryModifier) Paginate(cursor string) error {
+	hasId := false
+	for _, col := range modifier.Sort {
+		if sortColumn(col) == "id" {
+			hasId = true
+			break
+		}
+	}
+
+	if !hasId {
+		modifier.Sort = append(modifier.Sort, "id ASC")
+	}
+
+	if cursor == "" {
+		return nil
+	}
+
+	after, err := ParseCursor(cursor, modifier.Sort)
+	if err != nil {
+		return err
+	}
+
+	modifier.After = after
+	return nil
+}
+
 // TODO: We should be able to embed QueryBase when decoding url data. Sadly this
 //   is currently Not supported by goji/param. Resorted to copying for now.
 type QueryBase struct {
@@ -32,6 +78,102 @@ type QueryBase struct {
 	Limit  uint64            `param:"count"`
 	Filter map[string]string `param:"filter"`
 	Sort   string            `param:"sort"` // prepend "-" for descending
+	Cursor string            `param:"cursor"`
+	Total  bool              `param:"total"`
+}
+
+// Filters are keyed by column, optionally followed by an operator, like
+// filter[score_x:gte]=3, and the value is always a string:
+//
+//   col=v             equality, "\x00" standing for NULL
+//   col:ne=v          inequality
+//   col:gt=v          also gte, lt and lte
+//   col:in=a,b,c      one of the comma-separated values
+//   col:null=true     IS NULL, or IS NOT NULL if false
+//   col:notnull=      IS NOT NULL, regardless of the value
+//   col:prefix=v      case-insensitive prefix match, text columns only
+//   col:search=v      case-insensitive substring match, text columns only
+//
+// Keys prefixed with "any." (or "any1.", "any2." and so on, for more than one
+// group) are ORed within their group, as in filter[any.team_x]=1 and
+// filter[any.team_y]=1, while everything else is ANDed.
+//
+// Columns have to be in filterAllowed, and only those marked with ":text"
+// there, like "nickname:text", can be searched. Anything else is ignored.
+
+var filterOperators = map[string]func(column, value string) sq.Sqlizer{
+	"ne":  func(c, v string) sq.Sqlizer { return sq.NotEq{c: v} },
+	"gt":  func(c, v string) sq.Sqlizer { return sq.Gt{c: v} },
+	"gte": func(c, v string) sq.Sqlizer { return sq.GtOrEq{c: v} },
+	"lt":  func(c, v string) sq.Sqlizer { return sq.Lt{c: v} },
+	"lte": func(c, v string) sq.Sqlizer { return sq.LtOrEq{c: v} },
+	"in": func(c, v string) sq.Sqlizer {
+		return sq.Eq{c: strings.Split(v, ",")}
+	},
+	"null": func(c, v string) sq.Sqlizer {
+		if v == "false" {
+			return sq.NotEq{c: nil}
+		}
+
+		return sq.Eq{c: nil}
+	},
+	"notnull": func(c, v string) sq.Sqlizer { return sq.NotEq{c: nil} },
+}
+
+var textOperators = map[string]func(column, value string) sq.Sqlizer{
+	"prefix": func(c, v string) sq.Sqlizer {
+		return sq.Expr(c+" ILIKE ?", escapeLike(v)+"%")
+	},
+	"search": func(c, v string) sq.Sqlizer {
+		return sq.Expr(c+" ILIKE ?", "%"+escapeLike(v)+"%")
+	},
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var orGroupRegexp = regexp.MustCompile(`^(any[0-9]*)\.(.+)$`)
+
+// parseFilterKey splits "any2.score_x:gte" into "any2", "score_x" and "gte".
+func parseFilterKey(key string) (group, column, op string) {
+	if m := orGroupRegexp.FindStringSubmatch(key); m != nil {
+		group, key = m[1], m[2]
+	}
+
+	column = key
+	if i := strings.LastIndex(key, ":"); i >= 0 {
+		column, op = key[:i], key[i+1:]
+	}
+
+	return
+}
+
+// filterCondition turns a single filter into a condition, nil if either the
+// column or the operator isn't allowed.
+func filterCondition(
+	allowed map[string]bool, column, op, value string,
+) sq.Sqlizer {
+	isText, ok := allowed[column]
+	if !ok {
+		return nil
+	}
+
+	if op == "" {
+		if value == "\x00" {
+			return sq.Eq{column: nil}
+		}
+
+		return sq.Eq{column: value}
+	} else if operator, ok := filterOperators[op]; ok {
+		return operator(column, value)
+	} else if operator, ok := textOperators[op]; ok && isText {
+		return operator(column, value)
+	}
+
+	return nil
 }
 
 // TODO: check if sortAllowed and filterAllowed can be moved to struct tags.
@@ -51,17 +193,60 @@ func NewQueryModifier(
 		result.Limit = queryBase.Limit
 	}
 
-	for col, val := range queryBase.Filter {
-		for _, allowedcol := range filterAllowed {
-			if allowedcol == col {
+	allowed := make(map[string]bool) // column -> whether it's a text one
+	for _, col := range filterAllowed {
+		if strings.HasSuffix(col, ":text") {
+			allowed[strings.TrimSuffix(col, ":text")] = true
+		} else {
+			allowed[col] = false
+		}
+	}
+
+	// keys are sorted for the SQL to come out the same every time
+	keys := make([]string, 0, len(queryBase.Filter))
+	for key := range queryBase.Filter {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	groups := make(map[string]sq.Or)
+	groupNames := make([]string, 0)
+	for _, key := range keys {
+		val := queryBase.Filter[key]
+		group, col, op := parseFilterKey(key)
+		if group == "" && op == "" {
+			if _, ok := allowed[col]; ok {
 				if result == nil {
 					result = &QueryModifier{}
 				}
 
-				result.SetColumnFilter(allowedcol, val)
-				break
+				result.SetColumnFilter(col, val)
 			}
+
+			continue
+		}
+
+		cond := filterCondition(allowed, col, op, val)
+		if cond == nil {
+			continue
+		} else if result == nil {
+			result = &QueryModifier{}
+		}
+
+		if group == "" {
+			result.Where = append(result.Where, cond)
+			continue
+		}
+
+		if _, ok := groups[group]; !ok {
+			groupNames = append(groupNames, group)
 		}
+
+		groups[group] = append(groups[group], cond)
+	}
+
+	for _, group := range groupNames {
+		result.Where = append(result.Where, groups[group])
 	}
 
 	cols := strings.Split(queryBase.Sort, ",")
@@ -110,6 +295,14 @@ func (modifier *QueryModifier) ToSql(
 		q = q.Where(eq)
 	}
 
+	for _, cond := range modifier.Where {
+		q = q.Where(cond)
+	}
+
+	if modifier.After != nil {
+		q = q.Where(modifier.After.withAliases(aliases(columns)))
+	}
+
 	if len(modifier.Sort) > 0 {
 		q = q.OrderBy(modifier.Sort...)
 	}
@@ -124,3 +317,32 @@ func (modifier *QueryModifier) ToSql(
 
 	return q.ToSql()
 }
+
+// aliases maps the aliased columns, like isAdminSql, to their expressions.
+func aliases(columns []string) map[string]string {
+	aliases := make(map[string]string)
+	for _, col := range columns {
+		if i := strings.LastIndex(col, " AS "); i >= 0 {
+			aliases[strings.TrimSpace(col[i+4:])] = strings.TrimSpace(col[:i])
+		}
+	}
+
+	return aliases
+}
+
+// Count counts the rows a modifier would match, disregarding pagination.
+func (e *Env) Count(table string, modifier *QueryModifier) (uint64, error) {
+	var filter *QueryModifier
+	if modifier != nil {
+		filter = &QueryModifier{Filter: modifier.Filter, Where: modifier.Where}
+	}
+
+	sql, args, err := filter.ToSql(table, "COUNT(*)")
+	if err != nil {
+		return 0, err
+	}
+
+	var count uint64
+	err = e.Db.Get(&count, sql, args...)
+	return count, err
+}
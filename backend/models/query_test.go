@@ -0,0 +1,180 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryModifierToSql(t *testing.T) {
+	filterAllowed := []string{
+		"id", "score_x", "team_x", "team_y", "deleted_at", "nickname:text",
+	}
+	sortAllowed := []string{"id", "score_x"}
+
+	cases := []struct {
+		name   string
+		filter map[string]string
+		sort   string
+		sql    string
+		args   []interface{}
+	}{{
+		name: "nothing",
+		sql:  "SELECT * FROM t",
+	}, {
+		name:   "equality",
+		filter: map[string]string{"id": "1"},
+		sql:    "SELECT * FROM t WHERE id = $1",
+		args:   []interface{}{"1"},
+	}, {
+		name:   "null equality",
+		filter: map[string]string{"team_x": "\x00"},
+		sql:    "SELECT * FROM t WHERE team_x IS NULL",
+	}, {
+		name:   "ne",
+		filter: map[string]string{"id:ne": "1"},
+		sql:    "SELECT * FROM t WHERE id <> $1",
+		args:   []interface{}{"1"},
+	}, {
+		name:   "gt",
+		filter: map[string]string{"score_x:gt": "3"},
+		sql:    "SELECT * FROM t WHERE score_x > $1",
+		args:   []interface{}{"3"},
+	}, {
+		name:   "gte",
+		filter: map[string]string{"score_x:gte": "3"},
+		sql:    "SELECT * FROM t WHERE score_x >= $1",
+		args:   []interface{}{"3"},
+	}, {
+		name:   "lt",
+		filter: map[string]string{"score_x:lt": "3"},
+		sql:    "SELECT * FROM t WHERE score_x < $1",
+		args:   []interface{}{"3"},
+	}, {
+		name:   "lte",
+		filter: map[string]string{"score_x:lte": "3"},
+		sql:    "SELECT * FROM t WHERE score_x <= $1",
+		args:   []interface{}{"3"},
+	}, {
+		name:   "in",
+		filter: map[string]string{"id:in": "1,2,3"},
+		sql:    "SELECT * FROM t WHERE id IN ($1,$2,$3)",
+		args:   []interface{}{"1", "2", "3"},
+	}, {
+		name:   "null",
+		filter: map[string]string{"deleted_at:null": "true"},
+		sql:    "SELECT * FROM t WHERE deleted_at IS NULL",
+	}, {
+		name:   "null false",
+		filter: map[string]string{"deleted_at:null": "false"},
+		sql:    "SELECT * FROM t WHERE deleted_at IS NOT NULL",
+	}, {
+		name:   "notnull",
+		filter: map[string]string{"deleted_at:notnull": ""},
+		sql:    "SELECT * FROM t WHERE deleted_at IS NOT NULL",
+	}, {
+		name:   "prefix",
+		filter: map[string]string{"nickname:prefix": "Ab"},
+		sql:    "SELECT * FROM t WHERE nickname ILIKE $1",
+		args:   []interface{}{"Ab%"},
+	}, {
+		name:   "search",
+		filter: map[string]string{"nickname:search": "Ab"},
+		sql:    "SELECT * FROM t WHERE nickname ILIKE $1",
+		args:   []interface{}{"%Ab%"},
+	}, {
+		name:   "search escaped",
+		filter: map[string]string{"nickname:search": `100%_a\b`},
+		sql:    "SELECT * FROM t WHERE nickname ILIKE $1",
+		args:   []interface{}{`%100\%\_a\\b%`},
+	}, {
+		name: "and",
+		filter: map[string]string{
+			"id": "1", "score_x:gte": "3", "deleted_at:null": "true",
+		},
+		sql: "SELECT * FROM t WHERE id = $1 AND deleted_at IS NULL AND " +
+			"score_x >= $2",
+		args: []interface{}{"1", "3"},
+	}, {
+		name:   "any",
+		filter: map[string]string{"any.team_x": "1", "any.team_y": "1"},
+		sql:    "SELECT * FROM t WHERE (team_x = $1 OR team_y = $2)",
+		args:   []interface{}{"1", "1"},
+	}, {
+		name: "any groups",
+		filter: map[string]string{
+			"any1.team_x": "1", "any1.team_y": "1",
+			"any2.team_x": "2", "any2.team_y:null": "true",
+			"id:ne": "5",
+		},
+		sql: "SELECT * FROM t WHERE id <> $1 AND " +
+			"(team_x = $2 OR team_y = $3) AND (team_x = $4 OR team_y IS NULL)",
+		args: []interface{}{"5", "1", "1", "2"},
+	}, {
+		name: "not allowed",
+		filter: map[string]string{
+			"password": "x", "password:ne": "x", "any.password": "x",
+			"id:bogus": "1",
+		},
+		sql: "SELECT * FROM t",
+	}, {
+		name:   "text operator on a non-text column",
+		filter: map[string]string{"id:search": "1", "team_x:prefix": "1"},
+		sql:    "SELECT * FROM t",
+	}, {
+		name: "sort",
+		sort: "-score_x,id,password",
+		sql:  "SELECT * FROM t ORDER BY score_x DESC, id ASC",
+	}}
+
+	for _, c := range cases {
+		modifier := NewQueryModifier(
+			QueryBase{Filter: c.filter, Sort: c.sort}, filterAllowed, sortAllowed,
+		)
+		sql, args, err := modifier.ToSql("t", "*")
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		if sql != c.sql {
+			t.Errorf("%s: got %q, expected %q", c.name, sql, c.sql)
+		}
+
+		if len(args) != 0 || len(c.args) != 0 {
+			if !reflect.DeepEqual(args, c.args) {
+				t.Errorf("%s: got %#v, expected %#v", c.name, args, c.args)
+			}
+		}
+	}
+}
+
+func TestQueryModifierPagination(t *testing.T) {
+	modifier := NewQueryModifier(
+		QueryBase{Offset: 20, Limit: 10}, nil, nil,
+	)
+	sql, _, err := modifier.ToSql("t", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "SELECT * FROM t LIMIT 10 OFFSET 20"
+	if sql != expected {
+		t.Errorf("got %q, expected %q", sql, expected)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	cases := map[string]string{
+		"plain": "plain",
+		"50%":   `50\%`,
+		"a_b":   `a\_b`,
+		`a\b`:   `a\\b`,
+		`\%_`:   `\\\%\_`,
+	}
+
+	for in, expected := range cases {
+		if out := escapeLike(in); out != expected {
+			t.Errorf("%q: got %q, expected %q", in, out, expected)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	RoleAdmin           = "admin" // everything, everywhere, never scoped
+	RoleTournamentAdmin = "tournament-admin"
+	RoleReferee         = "referee"
+	RoleNewsEditor      = "news-editor"
+	RoleModerator       = "moderator"
+)
+
+var Roles = []string{
+	RoleAdmin, RoleTournamentAdmin, RoleReferee, RoleNewsEditor, RoleModerator,
+}
+
+type Role struct {
+	Id           string    `json:"id"`
+	UserId       string    `db:"user_id" json:"userId"`
+	Name         string    `json:"name"`
+	TournamentId *string   `db:"tournament_id" json:"tournamentId"`
+	SeasonId     *string   `db:"season_id" json:"seasonId"`
+	CreatedAt    time.Time `db:"created_at" json:"createdAt"`
+	CreatedBy    *string   `db:"created_by" json:"createdBy"`
+}
+
+// Scope is where something happens: everywhere, if both ids are empty, within a
+// tournament, or within a season of it.
+type Scope struct {
+	TournamentId string `db:"tournament_id"`
+	SeasonId     string `db:"season_id"`
+}
+
+var Global = Scope{}
+
+// Ids turns the scope into nullable columns.
+func (s Scope) Ids() (tournamentId, seasonId *string) {
+	if s.TournamentId != "" {
+		tournamentId = &s.TournamentId
+	}
+
+	if s.SeasonId != "" {
+		seasonId = &s.SeasonId
+	}
+
+	return
+}
+
+// Includes tells whether holding r is enough to act as role. Tournament admins
+// are referees, news editors and moderators of their tournament too.
+func (r *Role) Includes(role string) bool {
+	return r.Name == role || r.Name == RoleAdmin ||
+		(r.Name == RoleTournamentAdmin && role != RoleAdmin)
+}
+
+func (r *Role) Scope() Scope {
+	var scope Scope
+	if r.TournamentId != nil {
+		scope.TournamentId = *r.TournamentId
+	}
+
+	if r.SeasonId != nil {
+		scope.SeasonId = *r.SeasonId
+	}
+
+	return scope
+}
+
+// Covers tells whether r applies within scope. A role scoped to a tournament
+// covers all of its seasons, but not the other way around.
+func (r *Role) Covers(scope Scope) bool {
+	if r.TournamentId == nil {
+		return true
+	} else if *r.TournamentId != scope.TournamentId {
+		return false
+	}
+
+	return r.SeasonId == nil || *r.SeasonId == scope.SeasonId
+}
+
+func (e *Env) CreateRole(role *Role) error {
+	return e.Db.Get(
+		role, `
+    INSERT INTO role (user_id, name, tournament_id, season_id, created_by)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING *`,
+		role.UserId,
+		role.Name,
+		role.TournamentId,
+		role.SeasonId,
+		role.CreatedBy,
+	)
+}
+
+func (e *Env) GetRoleById(id string) (*Role, error) {
+	var role Role
+	err := e.Db.Get(
+		&role, `
+    SELECT *
+    FROM role
+    WHERE id=$1`,
+		id,
+	)
+	return &role, BetterGetterErrors(err)
+}
+
+func (e *Env) GetRolesByUser(userId string) ([]Role, error) {
+	roles := make([]Role, 0)
+	err := e.Db.Select(
+		&roles, `
+    SELECT *
+    FROM role
+    WHERE user_id=$1
+    ORDER BY id`,
+		userId,
+	)
+	return roles, err
+}
+
+func (e *Env) GetRoles(modifier *QueryModifier) ([]Role, error) {
+	roles := make([]Role, 0)
+	sql, args, err := modifier.ToSql("role", "*")
+	if err != nil {
+		return roles, err
+	}
+
+	err = e.Db.Select(&roles, sql, args...)
+	return roles, err
+}
+
+func (e *Env) DeleteRole(role *Role) error {
+	_, err := e.Db.Exec(`
+    DELETE FROM role
+    WHERE id=$1`,
+		role.Id,
+	)
+	return err
+}
+
+var scopeQueries = map[string]string{
+	"season": `
+    SELECT season.tournament_id, season.id AS season_id
+    FROM season
+    WHERE season.id=$1`,
+	"stage": `
+    SELECT season.tournament_id, season.id AS season_id
+    FROM stage
+    JOIN season ON season.id=stage.season_id
+    WHERE stage.id=$1`,
+	"bracket": `
+    SELECT season.tournament_id, season.id AS season_id
+    FROM bracket
+    JOIN stage ON stage.id=bracket.stage_id
+    JOIN season ON season.id=stage.season_id
+    WHERE bracket.id=$1`,
+	"bracket_round": `
+    SELECT season.tournament_id, season.id AS season_id
+    FROM bracket_round
+    JOIN bracket ON bracket.id=bracket_round.bracket_id
+    JOIN stage ON stage.id=bracket.stage_id
+    JOIN season ON season.id=stage.season_id
+    WHERE bracket_round.id=$1`,
+	"bracket_map": `
+    SELECT season.tournament_id, season.id AS season_id
+    FROM bracket_map
+    JOIN bracket ON bracket.id=bracket_map.bracket_id
+    JOIN stage ON stage.id=bracket.stage_id
+    JOIN season ON season.id=stage.season_id
+    WHERE bracket_map.id=$1`,
+	"match": `
+    SELECT season.tournament_id, season.id AS season_id
+    FROM match
+    JOIN bracket ON bracket.id=match.bracket_id
+    JOIN stage ON stage.id=bracket.stage_id
+    JOIN season ON season.id=stage.season_id
+    WHERE match.id=$1`,
+	"match_report": `
+    SELECT season.tournament_id, season.id AS season_id
+    FROM match_report
+    JOIN match ON match.id=match_report.match_id
+    JOIN bracket ON bracket.id=match.bracket_id
+    JOIN stage ON stage.id=bracket.stage_id
+    JOIN season ON season.id=stage.season_id
+    WHERE match_report.id=$1`,
+	"team_season": `
+    SELECT season.tournament_id, season.id AS season_id
+    FROM team_season
+    JOIN season ON season.id=team_season.season_id
+    WHERE team_season.id=$1`,
+}
+
+// GetScope finds where the target belongs. Targets outside of any tournament,
+// like games and teams, are global.
+func (e *Env) GetScope(target, targetId string) (Scope, error) {
+	var scope Scope
+	switch target {
+	case "tournament":
+		scope.TournamentId = targetId
+		return scope, nil
+	case "news":
+		newsItem, err := e.GetNewsItemById(targetId)
+		if err != nil {
+			return scope, err
+		}
+
+		return e.GetScope(newsItem.Target, newsItem.TargetId)
+	}
+
+	query, ok := scopeQueries[target]
+	if !ok {
+		return scope, nil
+	}
+
+	err := e.Db.Get(&scope, query, targetId)
+	return scope, BetterGetterErrors(err)
+}
+
+// isAdminSql stands in for the "user" column which predates roles.
+const isAdminSql = `EXISTS (
+      SELECT 1
+      FROM role
+      WHERE role.user_id="user".id AND role.name='` + RoleAdmin + `'
+    ) AS is_admin`
+
+func (e *Env) GetRoleByUserNameScope(
+	userId, name string, tournamentId, seasonId *string,
+) (*Role, error) {
+	var role Role
+	err := e.Db.Get(
+		&role, `
+    SELECT *
+    FROM role
+    WHERE
+      user_id=$1 AND name=$2 AND
+      tournament_id IS NOT DISTINCT FROM $3 AND
+      season_id IS NOT DISTINCT FROM $4`,
+		userId,
+		name,
+		tournamentId,
+		seasonId,
+	)
+	return &role, BetterGetterErrors(err)
+}
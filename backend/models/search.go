@@ -0,0 +1,73 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+var SearchTargets = []string{"user", "team", "tournament", "season", "news_item"}
+
+// SearchResult is a row of the search table, which only ever has public text
+// in it, see migration 0046.
+type SearchResult struct {
+	Target   string  `json:"target"`
+	TargetId string  `db:"target_id" json:"targetId"`
+	Title    string  `json:"title"`
+	Rank     float64 `json:"rank"`
+	Id       string  `json:"-"` // target:target_id, for cursors
+}
+
+var searchWordRegexp = regexp.MustCompile(`[\pL\pN]+`)
+
+// searchQuery turns whatever the user typed into a tsquery that matches
+// documents with all of the words, the last one possibly unfinished, empty if
+// there are no words at all.
+func searchQuery(text string) string {
+	words := searchWordRegexp.FindAllString(strings.ToLower(text), -1)
+	if len(words) == 0 {
+		return ""
+	}
+
+	words[len(words)-1] += ":*"
+	return strings.Join(words, " & ")
+}
+
+// NarrowSearch narrows modifier down to the visible rows of targets that match
+// text, best ranked first, for Search to select.
+func NarrowSearch(modifier *QueryModifier, text string, targets []string) {
+	query := searchQuery(text)
+	if query == "" {
+		modifier.Where = append(modifier.Where, sq.Expr("FALSE"))
+	} else {
+		modifier.Where = append(modifier.Where,
+			sq.Expr("document @@ to_tsquery('simple', ?)", query))
+	}
+
+	modifier.Where = append(modifier.Where, sq.Expr("visible_from <= now()"))
+	modifier.SetColumnFilter("target", targets)
+	modifier.Sort = []string{"rank DESC"}
+}
+
+// Search selects what NarrowSearch has narrowed modifier down to. Rank and id
+// are aliases, the latter being what Paginate breaks ties with, so that
+// cursors can point at results.
+func (e *Env) Search(
+	text string, modifier *QueryModifier,
+) ([]SearchResult, error) {
+	rank := "ts_rank(document, to_tsquery('simple', " +
+		pq.QuoteLiteral(searchQuery(text)) + "))::float8 AS rank"
+	sql, args, err := modifier.ToSql(
+		"search", "target", "target_id", "title", rank,
+		"target || ':' || target_id AS id",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0)
+	err = e.Db.Select(&results, sql, args...)
+	return results, err
+}
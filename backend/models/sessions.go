@@ -71,3 +71,16 @@ func (e *Env) DeleteSession(session *Session) error {
 	)
 	return err
 }
+
+func (e *Env) GetSessionsByUser(userId string) ([]Session, error) {
+	sessions := make([]Session, 0)
+	err := e.Db.Select(
+		&sessions, `
+    SELECT *
+    FROM session
+    WHERE user_id=$1
+    ORDER BY created_at`,
+		userId,
+	)
+	return sessions, err
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// UserGameIdentifier is a piece of data that a valid verified user-game pair
+// has claimed for itself, like a GUID, so that nobody else can verify with it.
+type UserGameIdentifier struct {
+	GameId     string `db:"game_id" json:"gameId"`
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	UserGameId string `db:"user_game_id" json:"userGameId"`
+}
+
+// ClaimUserGameIdentifier tells whether the identifier is now the pair's, or
+// was already. If not, identifier is overwritten with whichever pair has it.
+func (e *Env) ClaimUserGameIdentifier(
+	identifier *UserGameIdentifier,
+) (bool, error) {
+	userGameId := identifier.UserGameId
+	err := e.Db.Get(
+		identifier, `
+    INSERT INTO user_game_identifier (game_id, key, value, user_game_id)
+    VALUES ($1, $2, $3, $4)
+    ON CONFLICT (game_id, key, value) DO UPDATE
+    SET user_game_id=EXCLUDED.user_game_id
+    WHERE user_game_identifier.user_game_id=EXCLUDED.user_game_id
+    RETURNING *`,
+		identifier.GameId,
+		identifier.Key,
+		identifier.Value,
+		userGameId,
+	)
+	if err != sql.ErrNoRows {
+		return err == nil, err
+	}
+
+	err = e.Db.Get(
+		identifier, `
+    SELECT *
+    FROM user_game_identifier
+    WHERE
+      game_id=$1 AND
+      key=$2 AND
+      value=$3`,
+		identifier.GameId,
+		identifier.Key,
+		identifier.Value,
+	)
+	return false, BetterGetterErrors(err)
+}
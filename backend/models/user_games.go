@@ -51,6 +51,21 @@ func (e *Env) GetUserGameById(id string) (*UserGame, error) {
 	return &userGame, BetterGetterErrors(err)
 }
 
+// LockUserGame is GetUserGameById that also locks the pair until the end of
+// the transaction, for decisions that depend on what state it's in.
+func (e *Env) LockUserGame(id string) (*UserGame, error) {
+	var userGame UserGame
+	err := e.Db.Get(
+		&userGame, `
+    SELECT *
+    FROM user_game
+    WHERE id=$1
+    FOR UPDATE`,
+		id,
+	)
+	return &userGame, BetterGetterErrors(err)
+}
+
 func (e *Env) GetUserGames(modifier *QueryModifier) ([]UserGame, error) {
 	userGames := make([]UserGame, 0)
 	sql, args, err := modifier.ToSql("user_game", "*")
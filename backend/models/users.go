@@ -8,12 +8,13 @@ import (
 )
 
 type UserPublic struct {
-	Id        string    `json:"id"`
-	Nickname  string    `json:"nickname"`
-	Fullname  string    `json:"fullname"`
-	IsAdmin   bool      `db:"is_admin" json:"isAdmin"`
-	CreatedAt time.Time `db:"created_at" json:"createdAt"`
-	Gravatar  string    `json:"gravatar"`
+	Id        string     `json:"id"`
+	Nickname  string     `json:"nickname"`
+	Fullname  string     `json:"fullname"`
+	IsAdmin   bool       `db:"is_admin" json:"isAdmin"` // holds RoleAdmin
+	CreatedAt time.Time  `db:"created_at" json:"createdAt"`
+	Gravatar  string     `json:"gravatar"`
+	DeletedAt *time.Time `db:"deleted_at" json:"deletedAt"`
 }
 
 type User struct {
@@ -24,21 +25,38 @@ type User struct {
 	GravatarEmail   string     `db:"gravatar_email" json:"gravatarEmail"`
 	UpdatedAt       *time.Time `db:"updated_at" json:"updatedAt"`
 	UpdatedBy       *string    `db:"updated_by" json:"updatedBy"`
+	Roles           []Role     `db:"-" json:"roles,omitempty"`
+}
+
+// Can tells whether the user is allowed to act as role within scope. Roles have
+// to be loaded beforehand, see GetRolesByUser.
+func (u *User) Can(role string, scope Scope) bool {
+	for i := range u.Roles {
+		if u.Roles[i].Includes(role) && u.Roles[i].Covers(scope) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Is tells whether the user holds role globally.
+func (u *User) Is(role string) bool {
+	return u.Can(role, Global)
 }
 
 func (e *Env) CreateUser(user *User) error {
 	return e.Db.Get(
 		user, `
     INSERT INTO "user" (
-      email, password, is_email_verified, nickname, is_admin, gravatar
+      email, password, is_email_verified, nickname, gravatar
     )
-    VALUES ($1, $2, $3, $4, $5, $6)
-    RETURNING *`,
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING *, `+isAdminSql,
 		user.Email,
 		user.Password,
 		user.IsEmailVerified,
 		strings.Split(user.Email, "@")[0],
-		user.IsAdmin,
 		utils.EmailToGravatar(user.Email),
 	)
 }
@@ -46,7 +64,8 @@ func (e *Env) CreateUser(user *User) error {
 func (e *Env) GetUsers(modifier *QueryModifier) ([]User, error) {
 	users := make([]User, 0)
 	sql, args, err := modifier.ToSql("\"user\"",
-		"id", "nickname", "fullname", "is_admin", "created_at", "gravatar")
+		"id", "nickname", "fullname", isAdminSql, "created_at", "gravatar",
+		"deleted_at")
 	if err != nil {
 		return users, err
 	}
@@ -59,7 +78,7 @@ func (e *Env) GetUserById(id string) (*User, error) {
 	var user User
 	err := e.Db.Get(
 		&user, `
-    SELECT *
+    SELECT *, `+isAdminSql+`
     FROM "user"
     WHERE id=$1`,
 		id,
@@ -72,7 +91,7 @@ func (e *Env) GetUserByEmail(email string) (*User, error) {
 	var user User
 	err := e.Db.Get(
 		&user, `
-    SELECT *
+    SELECT *, `+isAdminSql+`
     FROM "user"
     WHERE LOWER(email)=LOWER($1)`,
 		email,
@@ -87,19 +106,17 @@ func (e *Env) UpdateUser(user *User, updatedBy string) error {
     SET
       nickname=$2,
       fullname=$3,
-      is_admin=$4,
-      gravatar=$5,
-      email=$6,
-      password=$7,
-      is_email_verified=$8,
-      gravatar_email=$9,
-      updated_by=$10
+      gravatar=$4,
+      email=$5,
+      password=$6,
+      is_email_verified=$7,
+      gravatar_email=$8,
+      updated_by=$9
     WHERE id=$1
-    RETURNING *`,
+    RETURNING *, `+isAdminSql,
 		user.Id,
 		user.Nickname,
 		user.Fullname,
-		user.IsAdmin,
 		utils.EmailToGravatar(user.GravatarEmail),
 		user.Email,
 		user.Password,
@@ -108,3 +125,112 @@ func (e *Env) UpdateUser(user *User, updatedBy string) error {
 		updatedBy,
 	)
 }
+
+// DeleteUser anonymises the user in place, rather than deleting the row, since
+// ids are referenced from everywhere, match history included. Everything that
+// can identify the person is wiped, audit records of it too, and everything
+// that can be used to log in is removed.
+func (e *Env) DeleteUser(user *User, deletedBy string) error {
+	err := e.Db.Get(
+		user, `
+    UPDATE "user"
+    SET
+      nickname='[deleted]',
+      fullname='',
+      gravatar=$2,
+      email=$3,
+      password='',
+      is_email_verified=false,
+      gravatar_email='',
+      deleted_at=now(),
+      updated_by=$4
+    WHERE id=$1
+    RETURNING *, `+isAdminSql,
+		user.Id,
+		utils.EmailToGravatar(""),
+		user.Id+"@deleted.invalid", // email has to stay unique
+		deletedBy,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.Db.Exec(`
+    UPDATE user_game
+    SET
+      token=NULL,
+      data=NULL,
+      name=NULL,
+      link=NULL,
+      nullified_at=COALESCE(nullified_at, now()),
+      nullified_by=COALESCE(nullified_by, $2),
+      updated_by=$2
+    WHERE user_id=$1`,
+		user.Id,
+		deletedBy,
+	)
+	if err != nil {
+		return err
+	}
+
+	// what the game told about the player, like their IP, goes too, though
+	// outcomes stay for admins to make sense of the pairs
+	_, err = e.Db.Exec(`
+    UPDATE verification_attempt
+    SET data=NULL, name=NULL, token=NULL
+    WHERE user_game_id IN (SELECT id FROM user_game WHERE user_id=$1)`,
+		user.Id,
+	)
+	if err != nil {
+		return err
+	}
+
+	// alerts keep who looked like whom, but not the values, which may be IPs;
+	// they're resolved so that blanked values can't clash with open ones
+	_, err = e.Db.Exec(`
+    UPDATE account_alert
+    SET
+      value='',
+      resolved_at=COALESCE(resolved_at, now()),
+      resolved_by=COALESCE(resolved_by, $2)
+    WHERE user_id=$1 OR related_user_id=$1`,
+		user.Id,
+		deletedBy,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.Db.Exec(`
+    UPDATE user_team
+    SET left_at=now(), is_leader=false, updated_by=$2
+    WHERE user_id=$1 AND left_at IS NULL`,
+		user.Id,
+		deletedBy,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{
+		"session", "otp", "oauth_state", "identity", "api_token", "role",
+		"idempotency_key",
+	} {
+		_, err = e.Db.Exec(`DELETE FROM `+table+` WHERE user_id=$1`, user.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	// audit triggers have just copied everything above, hence this goes last
+	_, err = e.Db.Exec(`
+    DELETE FROM audit
+    WHERE
+      (table_name='user' AND row_id=$1) OR
+      (table_name='user_game' AND row_id IN (
+        SELECT id FROM user_game WHERE user_id=$1
+      ))`,
+		user.Id,
+	)
+	return err
+}
@@ -0,0 +1,110 @@
+package models_test
+
+import (
+	"testing"
+
+	"app/models"
+	"app/models/modelstest"
+)
+
+// TestDeleteUserAttempts makes sure that what games have told about a deleted
+// user, like their IP, doesn't outlive them in verification attempts.
+func TestDeleteUserAttempts(t *testing.T) {
+	m := modelstest.New(t)
+	user := modelstest.User(t, m)
+	slug := "test-" + modelstest.Unique(t)
+	game := &models.Game{
+		GamePublic: models.GamePublic{Slug: slug, Name: slug},
+		CreatedBy:  user.Id,
+	}
+	err := m.CreateGame(game)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userGame := &models.UserGame{
+		UserGamePublic: models.UserGamePublic{UserId: user.Id, GameId: game.Id},
+		Data:           models.JSONMap{},
+	}
+	err = m.CreateUserGame(userGame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, token := "nelly", "token"
+	err = m.CreateVerificationAttempt(&models.VerificationAttempt{
+		GameId:     game.Id,
+		UserGameId: &userGame.Id,
+		Name:       &name,
+		Token:      &token,
+		Data:       models.JSONMap{"ip": "127.0.0.1"},
+		Outcome:    models.VerificationNoProfile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, err := m.GetVerificationAttemptsByUser(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(attempts) != 1 || attempts[0].Data["ip"] != "127.0.0.1" {
+		t.Fatalf("got %+v", attempts)
+	}
+
+	err = m.DeleteUser(user, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, err = m.GetVerificationAttemptsByUser(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(attempts) != 1 {
+		t.Fatalf("got %d attempts, expected 1", len(attempts))
+	} else if a := attempts[0]; a.Data != nil || a.Name != nil ||
+		a.Token != nil || a.Outcome != models.VerificationNoProfile {
+		t.Errorf("not wiped: %+v", a)
+	}
+}
+
+// TestDeleteUserAlerts makes sure that alerts about a deleted user stay, but
+// without values like IPs.
+func TestDeleteUserAlerts(t *testing.T) {
+	m := modelstest.New(t)
+	user := modelstest.User(t, m)
+	other := modelstest.User(t, m)
+	slug := "test-" + modelstest.Unique(t)
+	game := &models.Game{
+		GamePublic: models.GamePublic{Slug: slug, Name: slug},
+		CreatedBy:  user.Id,
+	}
+	err := m.CreateGame(game)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alert := &models.AccountAlert{
+		Kind:          models.AccountAlertShared,
+		GameId:        game.Id,
+		UserId:        other.Id,
+		RelatedUserId: user.Id,
+		Key:           "ip",
+		Value:         "127.0.0.1",
+	}
+	err = m.CreateAccountAlert(alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.DeleteUser(user, other.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alert, err = m.GetAccountAlertById(alert.Id)
+	if err != nil {
+		t.Fatal(err)
+	} else if alert.Value != "" || alert.ResolvedAt == nil {
+		t.Errorf("not wiped: %+v", alert)
+	}
+}
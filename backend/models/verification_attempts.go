@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	VerificationVerified     = "verified"
+	VerificationUnknownToken = "unknown_token"
+	VerificationNoProfile    = "no_profile"
+	VerificationDuplicate    = "duplicate"
+	VerificationFailed       = "failed"
+	VerificationApproved     = "approved" // by an admin
+	VerificationRejected     = "rejected" // by an admin
+)
+
+// VerificationAttempt is kept for admins to figure out what went wrong. Data
+// is what the game told about the player, like their GUIDs and IP.
+type VerificationAttempt struct {
+	Id         string    `json:"id"`
+	GameId     string    `db:"game_id" json:"gameId"`
+	UserGameId *string   `db:"user_game_id" json:"userGameId"`
+	Name       *string   `json:"name"`
+	Token      *string   `json:"token"`
+	Data       JSONMap   `json:"data"`
+	Outcome    string    `json:"outcome"`
+	Reason     *string   `json:"reason"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+	CreatedBy  *string   `db:"created_by" json:"createdBy"`
+}
+
+func (e *Env) CreateVerificationAttempt(attempt *VerificationAttempt) error {
+	return e.Db.Get(
+		attempt, `
+    INSERT INTO verification_attempt (
+      game_id, user_game_id, name, token, data, outcome, reason, created_by
+    )
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    RETURNING *`,
+		attempt.GameId,
+		attempt.UserGameId,
+		attempt.Name,
+		attempt.Token,
+		attempt.Data,
+		attempt.Outcome,
+		attempt.Reason,
+		attempt.CreatedBy,
+	)
+}
+
+func (e *Env) GetVerificationAttempts(
+	modifier *QueryModifier,
+) ([]VerificationAttempt, error) {
+	attempts := make([]VerificationAttempt, 0)
+	sql, args, err := modifier.ToSql("verification_attempt", "*")
+	if err != nil {
+		return attempts, err
+	}
+
+	err = e.Db.Select(&attempts, sql, args...)
+	return attempts, BetterGetterErrors(err)
+}
+
+// GetVerificationAttemptsByUser lists the attempts at verifying any of the
+// user's pairs, for exports.
+func (e *Env) GetVerificationAttemptsByUser(
+	userId string,
+) ([]VerificationAttempt, error) {
+	attempts := make([]VerificationAttempt, 0)
+	err := e.Db.Select(
+		&attempts, `
+    SELECT verification_attempt.*
+    FROM verification_attempt
+    JOIN user_game ON user_game.id=verification_attempt.user_game_id
+    WHERE user_game.user_id=$1
+    ORDER BY verification_attempt.id`,
+		userId,
+	)
+	return attempts, err
+}
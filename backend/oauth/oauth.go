@@ -0,0 +1,440 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const httpTimeout = 10 * time.Second
+
+var (
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	ErrBadCallback     = errors.New("bad callback parameters")
+	ErrDenied          = errors.New("access denied by the identity provider")
+	ErrBadResponse     = errors.New("bad response from the identity provider")
+)
+
+// Identity is what a provider knows about the person who has just logged in.
+// Subject is stable and unique within a provider, everything else may change
+// between logins.
+type Identity struct {
+	Provider        string
+	Subject         string
+	Email           string
+	IsEmailVerified bool
+	Name            string
+	Link            string
+}
+
+// Provider is a single external identity provider. AuthURL builds the URL the
+// browser is sent to, and Exchange turns whatever the provider sent back to
+// redirectURI (query parameters, as is) into an Identity. The verifier is the
+// PKCE code verifier generated for this particular attempt, providers that
+// don't support PKCE ignore it.
+type Provider interface {
+	Name() string
+	AuthURL(state, verifier, redirectURI string) (string, error)
+	Exchange(
+		callback map[string]string, verifier, redirectURI string,
+	) (*Identity, error)
+}
+
+type Env struct {
+	providers map[string]Provider
+}
+
+func New(providers ...Provider) *Env {
+	e := &Env{make(map[string]Provider)}
+	for _, p := range providers {
+		e.providers[p.Name()] = p
+	}
+
+	return e
+}
+
+func (e *Env) Provider(name string) (Provider, error) {
+	p, ok := e.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	return p, nil
+}
+
+func (e *Env) Names() []string {
+	names := make([]string, 0, len(e.providers))
+	for name := range e.providers {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// NewVerifier generates a PKCE code verifier, RFC 7636 section 4.1.
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge derives the S256 code challenge from a verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+var client = &http.Client{Timeout: httpTimeout}
+
+func getJSON(u, bearer string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v: %s returned %d", ErrBadResponse, u, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// OIDC is an OpenID Connect (or a close enough OAuth2) provider, which uses the
+// authorization code flow with PKCE. If Issuer is set, the endpoints are
+// discovered through it on first use, otherwise they have to be supplied.
+//
+// The identity is taken from the userinfo endpoint, using the access token
+// obtained over the back channel, so the ID token isn't needed at all.
+type OIDC struct {
+	ProviderName string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+
+	AuthEndpoint     string
+	TokenEndpoint    string
+	UserInfoEndpoint string
+
+	// Claims maps userinfo fields onto Identity, defaults to the standard
+	// OIDC claims. Discord, for example, calls the subject "id".
+	SubjectClaim       string
+	EmailClaim         string
+	EmailVerifiedClaim string
+	NameClaim          string
+
+	// LinkPrefix, if set, is prepended to the subject to form Identity.Link.
+	LinkPrefix string
+
+	discoverMutex sync.Mutex
+	discovered    bool
+}
+
+func (p *OIDC) Name() string {
+	return p.ProviderName
+}
+
+func (p *OIDC) discover() error {
+	p.discoverMutex.Lock()
+	defer p.discoverMutex.Unlock()
+	if p.discovered || p.Issuer == "" {
+		return nil
+	}
+
+	var config struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	err := getJSON(
+		strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration",
+		"", &config,
+	)
+	if err != nil {
+		// not remembered, the next request will try again, since the provider
+		// might've been down only for a moment
+		return err
+	}
+
+	if p.AuthEndpoint == "" {
+		p.AuthEndpoint = config.AuthorizationEndpoint
+	}
+
+	if p.TokenEndpoint == "" {
+		p.TokenEndpoint = config.TokenEndpoint
+	}
+
+	if p.UserInfoEndpoint == "" {
+		p.UserInfoEndpoint = config.UserInfoEndpoint
+	}
+
+	p.discovered = true
+	return nil
+}
+
+func (p *OIDC) AuthURL(state, verifier, redirectURI string) (string, error) {
+	err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(p.AuthEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientId)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (p *OIDC) Exchange(
+	callback map[string]string, verifier, redirectURI string,
+) (*Identity, error) {
+	if callback["error"] != "" {
+		return nil, ErrDenied
+	}
+
+	code := callback["code"]
+	if code == "" {
+		return nil, ErrBadCallback
+	}
+
+	err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.PostForm(p.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.ClientId},
+		"client_secret": {p.ClientSecret},
+		"code_verifier": {verifier},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrDenied
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return nil, err
+	} else if token.AccessToken == "" {
+		return nil, ErrBadResponse
+	}
+
+	var claims map[string]interface{}
+	err = getJSON(p.UserInfoEndpoint, token.AccessToken, &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Provider:        p.ProviderName,
+		Subject:         claimString(claims, p.SubjectClaim, "sub"),
+		Email:           claimString(claims, p.EmailClaim, "email"),
+		IsEmailVerified: claimBool(claims, p.EmailVerifiedClaim, "email_verified"),
+		Name:            claimString(claims, p.NameClaim, "name"),
+	}
+	if identity.Subject == "" {
+		return nil, ErrBadResponse
+	}
+
+	if p.LinkPrefix != "" {
+		identity.Link = p.LinkPrefix + identity.Subject
+	}
+
+	return identity, nil
+}
+
+func claimString(claims map[string]interface{}, key, def string) string {
+	if key == "" {
+		key = def
+	}
+
+	switch v := claims[key].(type) {
+	case string:
+		return v
+	case float64: // some providers send numeric ids
+		return fmt.Sprintf("%.0f", v)
+	}
+
+	return ""
+}
+
+func claimBool(claims map[string]interface{}, key, def string) bool {
+	if key == "" {
+		key = def
+	}
+
+	switch v := claims[key].(type) {
+	case bool:
+		return v
+	case string: // looking at you, AWS Cognito
+		return v == "true"
+	}
+
+	return false
+}
+
+const (
+	steamEndpoint = "https://steamcommunity.com/openid/login"
+	openidNs      = "http://specs.openid.net/auth/2.0"
+	openidSelect  = "http://specs.openid.net/auth/2.0/identifier_select"
+)
+
+// Steam is Steam's OpenID 2.0 provider. It knows nothing but the SteamID64,
+// which is what we're after anyway. Endpoint and Realm default to Steam's
+// endpoint and the origin of the redirect URI respectively.
+type Steam struct {
+	Endpoint string
+	Realm    string
+}
+
+func (p *Steam) Name() string {
+	return "steam"
+}
+
+func (p *Steam) endpoint() string {
+	if p.Endpoint != "" {
+		return p.Endpoint
+	}
+
+	return steamEndpoint
+}
+
+func (p *Steam) returnTo(state, redirectURI string) (string, error) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (p *Steam) AuthURL(state, _, redirectURI string) (string, error) {
+	returnTo, err := p.returnTo(state, redirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	realm := p.Realm
+	if realm == "" {
+		u, _ := url.Parse(redirectURI) // already parsed fine above
+		realm = u.Scheme + "://" + u.Host
+	}
+
+	return p.endpoint() + "?" + url.Values{
+		"openid.ns":         {openidNs},
+		"openid.mode":       {"checkid_setup"},
+		"openid.return_to":  {returnTo},
+		"openid.realm":      {realm},
+		"openid.identity":   {openidSelect},
+		"openid.claimed_id": {openidSelect},
+	}.Encode(), nil
+}
+
+func (p *Steam) Exchange(
+	callback map[string]string, _, redirectURI string,
+) (*Identity, error) {
+	if callback["openid.mode"] == "cancel" {
+		return nil, ErrDenied
+	} else if callback["openid.mode"] != "id_res" {
+		return nil, ErrBadCallback
+	}
+
+	returnTo, err := p.returnTo(callback["state"], redirectURI)
+	if err != nil {
+		return nil, err
+	} else if callback["openid.return_to"] != returnTo {
+		return nil, ErrBadCallback
+	}
+
+	claimedId := callback["openid.claimed_id"]
+	steamId := strings.TrimPrefix(claimedId, "https://steamcommunity.com/openid/id/")
+	if steamId == claimedId || steamId == "" || strings.Contains(steamId, "/") {
+		return nil, ErrBadCallback
+	}
+
+	// The assertion has to be verified by the provider itself, otherwise anybody
+	// could forge a callback with somebody else's SteamID.
+	form := url.Values{}
+	for k, v := range callback {
+		if strings.HasPrefix(k, "openid.") {
+			form.Set(k, v)
+		}
+	}
+	form.Set("openid.mode", "check_authentication")
+
+	resp, err := client.PostForm(p.endpoint(), form)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	isValid := false
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.TrimSpace(line) == "is_valid:true" {
+			isValid = true
+			break
+		}
+	}
+
+	if !isValid {
+		return nil, ErrDenied
+	}
+
+	return &Identity{
+		Provider: p.Name(),
+		Subject:  steamId,
+		Link:     "https://steamcommunity.com/profiles/" + steamId,
+	}, nil
+}
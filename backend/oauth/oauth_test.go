@@ -0,0 +1,243 @@
+package oauth_test
+
+import (
+	"net/url"
+	"testing"
+
+	"app/oauth"
+	"app/oauth/oauthtest"
+)
+
+const redirectURI = "https://auzom.test/oauth/mock"
+
+// login goes through the provider's consent screen, returning the callback.
+func login(
+	t *testing.T, s *oauthtest.Server, p oauth.Provider, state, verifier string,
+) map[string]string {
+	authURL, err := p.AuthURL(state, verifier, redirectURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback, err := s.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return callback
+}
+
+func TestOIDC(t *testing.T) {
+	s := oauthtest.NewServer()
+	defer s.Close()
+	p := s.OIDC("mock")
+
+	verifier, err := oauth.NewVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback := login(t, s, p, "state", verifier)
+	if callback["state"] != "state" {
+		t.Fatalf("state came back as %q", callback["state"])
+	}
+
+	identity, err := p.Exchange(callback, verifier, redirectURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := oauth.Identity{
+		Provider:        "mock",
+		Subject:         "1",
+		Email:           "player@example.com",
+		IsEmailVerified: true,
+		Name:            "player",
+	}
+	if *identity != expected {
+		t.Errorf("got %+v, expected %+v", *identity, expected)
+	}
+}
+
+func TestOIDCClaims(t *testing.T) {
+	s := oauthtest.NewServer()
+	defer s.Close()
+	s.Claims = map[string]interface{}{
+		"id":       float64(80351110224678912),
+		"verified": "true",
+		"username": "nelly",
+	}
+
+	p := s.OIDC("discord")
+	p.SubjectClaim = "id"
+	p.EmailVerifiedClaim = "verified"
+	p.NameClaim = "username"
+	p.LinkPrefix = "https://discord.test/users/"
+
+	verifier, _ := oauth.NewVerifier()
+	identity, err := p.Exchange(
+		login(t, s, p, "state", verifier), verifier, redirectURI,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity.Subject != "80351110224678912" || !identity.IsEmailVerified ||
+		identity.Name != "nelly" ||
+		identity.Link != "https://discord.test/users/80351110224678912" {
+		t.Errorf("claims mapped wrong: %+v", *identity)
+	}
+}
+
+func TestOIDCWrongVerifier(t *testing.T) {
+	s := oauthtest.NewServer()
+	defer s.Close()
+	p := s.OIDC("mock")
+
+	verifier, _ := oauth.NewVerifier()
+	other, _ := oauth.NewVerifier()
+	_, err := p.Exchange(login(t, s, p, "state", verifier), other, redirectURI)
+	if err != oauth.ErrDenied {
+		t.Errorf("got %v, expected ErrDenied", err)
+	}
+}
+
+// TestOIDCReplay makes sure that a callback is good for one login only, which
+// is what keeps a leaked one from being used again.
+func TestOIDCReplay(t *testing.T) {
+	s := oauthtest.NewServer()
+	defer s.Close()
+	p := s.OIDC("mock")
+
+	verifier, _ := oauth.NewVerifier()
+	callback := login(t, s, p, "state", verifier)
+	_, err := p.Exchange(callback, verifier, redirectURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Exchange(callback, verifier, redirectURI)
+	if err != oauth.ErrDenied {
+		t.Errorf("got %v, expected ErrDenied", err)
+	}
+}
+
+func TestOIDCBadCallback(t *testing.T) {
+	s := oauthtest.NewServer()
+	defer s.Close()
+	p := s.OIDC("mock")
+
+	cases := []struct {
+		callback map[string]string
+		err      error
+	}{
+		{map[string]string{"error": "access_denied"}, oauth.ErrDenied},
+		{map[string]string{"state": "state"}, oauth.ErrBadCallback},
+		{map[string]string{"code": "made-up"}, oauth.ErrDenied},
+	}
+
+	for _, c := range cases {
+		_, err := p.Exchange(c.callback, "verifier", redirectURI)
+		if err != c.err {
+			t.Errorf("%v: got %v, expected %v", c.callback, err, c.err)
+		}
+	}
+}
+
+func TestSteam(t *testing.T) {
+	s := oauthtest.NewServer()
+	defer s.Close()
+	p := s.Steam()
+
+	callback := login(t, s, p, "state", "")
+	if callback["state"] != "state" {
+		t.Fatalf("state came back as %q", callback["state"])
+	}
+
+	identity, err := p.Exchange(callback, "", redirectURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := oauth.Identity{
+		Provider: "steam",
+		Subject:  s.SteamId,
+		Link:     "https://steamcommunity.com/profiles/" + s.SteamId,
+	}
+	if *identity != expected {
+		t.Errorf("got %+v, expected %+v", *identity, expected)
+	}
+}
+
+// TestSteamReplay makes sure that check_authentication is asked every time,
+// and that a nonce is good for one login only.
+func TestSteamReplay(t *testing.T) {
+	s := oauthtest.NewServer()
+	defer s.Close()
+	p := s.Steam()
+
+	callback := login(t, s, p, "state", "")
+	_, err := p.Exchange(callback, "", redirectURI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Exchange(callback, "", redirectURI)
+	if err != oauth.ErrDenied {
+		t.Errorf("got %v, expected ErrDenied", err)
+	}
+}
+
+// TestSteamForged makes sure that a callback that Steam never sent is turned
+// down by check_authentication, even if it looks the part.
+func TestSteamForged(t *testing.T) {
+	s := oauthtest.NewServer()
+	defer s.Close()
+	p := s.Steam()
+
+	callback := login(t, s, p, "state", "")
+	callback["openid.response_nonce"] = "forged"
+	_, err := p.Exchange(callback, "", redirectURI)
+	if err != oauth.ErrDenied {
+		t.Errorf("got %v, expected ErrDenied", err)
+	}
+}
+
+func TestSteamBadCallback(t *testing.T) {
+	s := oauthtest.NewServer()
+	defer s.Close()
+	p := s.Steam()
+
+	wrongState := login(t, s, p, "state", "")
+	wrongState["state"] = "another"
+
+	notSteam := login(t, s, p, "state", "")
+	notSteam["openid.claimed_id"] = "https://example.com/openid/id/1"
+
+	returnTo, _ := url.Parse(redirectURI)
+	q := returnTo.Query()
+	q.Set("state", "state")
+	returnTo.RawQuery = q.Encode()
+	cases := []struct {
+		callback map[string]string
+		err      error
+	}{
+		{map[string]string{"openid.mode": "cancel"}, oauth.ErrDenied},
+		{map[string]string{"openid.mode": "checkid_setup"}, oauth.ErrBadCallback},
+		{wrongState, oauth.ErrBadCallback},
+		{notSteam, oauth.ErrBadCallback},
+		{map[string]string{
+			"state":             "state",
+			"openid.mode":       "id_res",
+			"openid.return_to":  returnTo.String(),
+			"openid.claimed_id": "https://steamcommunity.com/openid/id/1/x",
+		}, oauth.ErrBadCallback},
+	}
+
+	for i, c := range cases {
+		_, err := p.Exchange(c.callback, "", redirectURI)
+		if err != c.err {
+			t.Errorf("#%d: got %v, expected %v", i, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Package oauthtest is a local stand-in for the identity providers supported by
+// package oauth. It speaks just enough OIDC (discovery, authorization code with
+// PKCE, userinfo) and Steam OpenID 2.0 to drive the whole login flow without
+// talking to Google, Discord or Steam.
+package oauthtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"app/oauth"
+	"app/utils"
+)
+
+type grant struct {
+	clientId    string
+	redirectURI string
+	challenge   string
+	claims      map[string]interface{}
+}
+
+type Server struct {
+	URL          string
+	ClientId     string
+	ClientSecret string
+
+	// Claims are returned by the userinfo endpoint, and SteamId is asserted by
+	// the OpenID endpoint; both can be changed between logins.
+	Claims  map[string]interface{}
+	SteamId string
+
+	srv    *httptest.Server
+	mutex  sync.Mutex
+	codes  map[string]*grant
+	tokens map[string]map[string]interface{}
+	nonces map[string]struct{}
+}
+
+func NewServer() *Server {
+	s := &Server{
+		ClientId:     "client",
+		ClientSecret: "secret",
+		Claims: map[string]interface{}{
+			"sub":            "1",
+			"email":          "player@example.com",
+			"email_verified": true,
+			"name":           "player",
+		},
+		SteamId: "76561197960287930",
+		codes:   make(map[string]*grant),
+		tokens:  make(map[string]map[string]interface{}),
+		nonces:  make(map[string]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userinfo)
+	mux.HandleFunc("/openid/login", s.openid)
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL
+	return s
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// OIDC returns a provider configured against this server.
+func (s *Server) OIDC(name string) *oauth.OIDC {
+	return &oauth.OIDC{
+		ProviderName: name,
+		Issuer:       s.URL,
+		ClientId:     s.ClientId,
+		ClientSecret: s.ClientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// Steam returns a Steam provider configured against this server.
+func (s *Server) Steam() *oauth.Steam {
+	return &oauth.Steam{Endpoint: s.URL + "/openid/login"}
+}
+
+// Authorize plays the part of the browser: it follows an authorization URL,
+// approves it, and returns the callback parameters the provider would've sent
+// to the redirect URI.
+func (s *Server) Authorize(authURL string) (map[string]string, error) {
+	c := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := c.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("authorization failed with %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return nil, err
+	}
+
+	callback := make(map[string]string)
+	for k, v := range location.Query() {
+		callback[k] = v[0]
+	}
+
+	return callback, nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"userinfo_endpoint":      s.URL + "/userinfo",
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" ||
+		q.Get("client_id") != s.ClientId ||
+		q.Get("redirect_uri") == "" ||
+		q.Get("code_challenge_method") != "S256" ||
+		q.Get("code_challenge") == "" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code, err := utils.GenerateToken(16, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.mutex.Lock()
+	claims := make(map[string]interface{}, len(s.Claims))
+	for k, v := range s.Claims {
+		claims[k] = v
+	}
+	s.codes[code] = &grant{
+		q.Get("client_id"), q.Get("redirect_uri"), q.Get("code_challenge"), claims,
+	}
+	s.mutex.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST expected", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	g, ok := s.codes[r.PostFormValue("code")]
+	if !ok {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	// codes are single-use, successful or not
+	delete(s.codes, r.PostFormValue("code"))
+	if r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("client_id") != g.clientId ||
+		r.PostFormValue("client_secret") != s.ClientSecret ||
+		r.PostFormValue("redirect_uri") != g.redirectURI {
+		tokenError(w, "invalid_client")
+		return
+	} else if oauth.Challenge(r.PostFormValue("code_verifier")) != g.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	token, err := utils.GenerateToken(16, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.tokens[token] = g.claims
+	writeJSON(w, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mutex.Lock()
+	claims, ok := s.tokens[token]
+	s.mutex.Unlock()
+	if !ok {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, claims)
+}
+
+func (s *Server) openid(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	switch r.Form.Get("openid.mode") {
+	case "checkid_setup":
+		returnTo, err := url.Parse(r.Form.Get("openid.return_to"))
+		if err != nil || r.Form.Get("openid.realm") == "" {
+			http.Error(w, "bad openid request", http.StatusBadRequest)
+			return
+		}
+
+		nonce, err := utils.GenerateToken(16, true)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		s.mutex.Lock()
+		s.nonces[nonce] = struct{}{}
+		steamId := s.SteamId
+		s.mutex.Unlock()
+
+		id := "https://steamcommunity.com/openid/id/" + steamId
+		q := returnTo.Query()
+		q.Set("openid.ns", "http://specs.openid.net/auth/2.0")
+		q.Set("openid.mode", "id_res")
+		q.Set("openid.op_endpoint", s.URL+"/openid/login")
+		q.Set("openid.claimed_id", id)
+		q.Set("openid.identity", id)
+		q.Set("openid.return_to", returnTo.String())
+		q.Set("openid.response_nonce", nonce)
+		q.Set("openid.assoc_handle", "1234567890")
+		q.Set("openid.signed", "signed,op_endpoint,claimed_id,identity,"+
+			"return_to,response_nonce,assoc_handle")
+		q.Set("openid.sig", "mock")
+		returnTo.RawQuery = q.Encode()
+		http.Redirect(w, r, returnTo.String(), http.StatusFound)
+	case "check_authentication":
+		s.mutex.Lock()
+		_, ok := s.nonces[r.Form.Get("openid.response_nonce")]
+		delete(s.nonces, r.Form.Get("openid.response_nonce"))
+		s.mutex.Unlock()
+
+		fmt.Fprintf(w, "ns:http://specs.openid.net/auth/2.0\nis_valid:%t\n", ok)
+	default:
+		http.Error(w, "bad openid.mode", http.StatusBadRequest)
+	}
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -2,126 +2,193 @@ package main
 
 import (
 	"github.com/zenazn/goji"
+	"github.com/zenazn/goji/web"
 
 	"app/api"
+	"app/worker"
 )
 
 func setupRoutes(env *api.Env) {
 	goji.Use(env.NewMiddleware(env.Auth))
+	goji.Use(env.NewMiddleware(env.Idempotency))
+	goji.Use(env.NewMiddleware(env.Shape))
+	goji.Use(env.NewMiddleware(env.Preconditions))
 
-	goji.Post("/sessions", env.NewHandler(env.PostSession))
-	goji.Delete("/sessions/:token", env.NewHandler(env.DeleteSession))
-
-	goji.Post("/otps", env.NewHandler(env.PostOTP))
-
-	goji.Post("/users", env.NewHandler(env.PostUser))
-	goji.Get("/users/:id", env.NewHandler(env.GetUser))
-	goji.Get("/users", env.NewHandler(env.GetUsers))
-	goji.Put("/users/:id", env.NewHandler(env.PutUser))
-
-	goji.Post("/teams", env.NewHandler(env.PostTeam))
-	goji.Get("/teams/:id", env.NewHandler(env.GetTeam))
-	goji.Get("/teams", env.NewHandler(env.GetTeams))
-	goji.Put("/teams/:id", env.NewHandler(env.PutTeam))
-	goji.Patch("/teams/:id", env.NewHandler(env.PatchTeam))
-
-	goji.Post("/user_team_requests", env.NewHandler(env.PostUserTeamRequest))
-	goji.Get("/user_team_requests/:id", env.NewHandler(env.GetUserTeamRequest))
-	goji.Get("/user_team_requests", env.NewHandler(env.GetUserTeamRequests))
-	goji.Patch("/user_team_requests/:id", env.NewHandler(env.PatchUserTeamRequest))
-
-	goji.Get("/user_teams", env.NewHandler(env.GetUserTeams))
-	goji.Get("/user_teams/:id", env.NewHandler(env.GetUserTeam))
-	goji.Patch("/user_teams/:id", env.NewHandler(env.PatchUserTeam))
-
-	goji.Post("/games", env.NewHandler(env.PostGame))
-	goji.Get("/games/:id", env.NewHandler(env.GetGame))
-	goji.Get("/games", env.NewHandler(env.GetGames))
-	goji.Put("/games/:id", env.NewHandler(env.PutGame))
-
-	goji.Post("/game_maps", env.NewHandler(env.PostGameMap))
-	goji.Get("/game_maps/:id", env.NewHandler(env.GetGameMap))
-	goji.Get("/game_maps", env.NewHandler(env.GetGameMaps))
-	goji.Put("/game_maps/:id", env.NewHandler(env.PutGameMap))
-
-	goji.Post("/user_games", env.NewHandler(env.PostUserGame))
-	goji.Get("/user_games/:id", env.NewHandler(env.GetUserGame))
-	goji.Get("/user_games", env.NewHandler(env.GetUserGames))
-	goji.Patch("/user_games/:id", env.NewHandler(env.PatchUserGame))
-
-	goji.Post("/tournaments", env.NewHandler(env.PostTournament))
-	goji.Get("/tournaments/:id", env.NewHandler(env.GetTournament))
-	goji.Get("/tournaments", env.NewHandler(env.GetTournaments))
-	goji.Put("/tournaments/:id", env.NewHandler(env.PutTournament))
-
-	goji.Post("/seasons", env.NewHandler(env.PostSeason))
-	goji.Get("/seasons/:id", env.NewHandler(env.GetSeason))
-	goji.Get("/seasons", env.NewHandler(env.GetSeasons))
-	goji.Put("/seasons/:id", env.NewHandler(env.PutSeason))
-	goji.Patch("/seasons/:id", env.NewHandler(env.PatchSeason))
-
-	goji.Post("/team_season_requests", env.NewHandler(env.PostTeamSeasonRequest))
-	goji.Get("/team_season_requests/:id", env.NewHandler(env.GetTeamSeasonRequest))
-	goji.Get("/team_season_requests", env.NewHandler(env.GetTeamSeasonRequests))
-	goji.Patch("/team_season_requests/:id", env.NewHandler(env.PatchTeamSeasonRequest))
-
-	goji.Get("/team_seasons", env.NewHandler(env.GetTeamSeasons))
-	goji.Get("/team_seasons/:id", env.NewHandler(env.GetTeamSeason))
-	goji.Patch("/team_seasons/:id", env.NewHandler(env.PatchTeamSeason))
-
-	goji.Post("/stages", env.NewHandler(env.PostStage))
-	goji.Get("/stages/:id", env.NewHandler(env.GetStage))
-	goji.Get("/stages", env.NewHandler(env.GetStages))
-	goji.Put("/stages/:id", env.NewHandler(env.PutStage))
-
-	goji.Post("/brackets", env.NewHandler(env.PostBracket))
-	goji.Get("/brackets/:id", env.NewHandler(env.GetBracket))
-	goji.Get("/brackets", env.NewHandler(env.GetBrackets))
-	goji.Put("/brackets/:id", env.NewHandler(env.PutBracket))
-	goji.Patch("/brackets/:id", env.NewHandler(env.PatchBracket))
-	goji.Get("/brackets/:id/standings", env.NewHandler(env.GetBracketStandings))
-
-	goji.Post("/bracket_maps", env.NewHandler(env.PostBracketMap))
-	goji.Get("/bracket_maps/:id", env.NewHandler(env.GetBracketMap))
-	goji.Get("/bracket_maps", env.NewHandler(env.GetBracketMaps))
-
-	goji.Get("/bracket_rounds/:id", env.NewHandler(env.GetBracketRound))
-	goji.Get("/bracket_rounds", env.NewHandler(env.GetBracketRounds))
-
-	goji.Get("/matches/:id", env.NewHandler(env.GetMatch))
-	goji.Get("/matches", env.NewHandler(env.GetMatches))
-	goji.Put("/matches/:id", env.NewHandler(env.PutMatch))
-	goji.Get("/matches/:id/leadership", env.NewHandler(env.GetMatchLeadership))
-	goji.Patch("/matches/:id", env.NewHandler(env.PatchMatch))
-
-	goji.Get("/match_maps/:id", env.NewHandler(env.GetMatchMap))
-	goji.Get("/match_maps", env.NewHandler(env.GetMatchMaps))
-
-	goji.Post("/match_reports", env.NewHandler(env.PostMatchReport))
-	goji.Get("/match_reports/:id", env.NewHandler(env.GetMatchReport))
-	goji.Get("/match_reports", env.NewHandler(env.GetMatchReports))
-	goji.Patch("/match_reports/:id", env.NewHandler(env.PatchMatchReport))
-
-	goji.Get("/match_rounds/:id", env.NewHandler(env.GetMatchRound))
-	goji.Get("/match_rounds", env.NewHandler(env.GetMatchRounds))
-	goji.Get("/match_penalties/:id", env.NewHandler(env.GetMatchPenalty))
-	goji.Get("/match_penalties", env.NewHandler(env.GetMatchPenalties))
-
-	goji.Post("/comments", env.NewHandler(env.PostComment))
-	goji.Get("/comments/:id", env.NewHandler(env.GetComment))
-	goji.Get("/comments", env.NewHandler(env.GetComments))
-	goji.Put("/comments/:id", env.NewHandler(env.PutComment))
-	goji.Patch("/comments/:id", env.NewHandler(env.PatchComment))
-
-	goji.Post("/attention_requests", env.NewHandler(env.PostAttentionRequest))
-	goji.Get("/attention_requests/:id", env.NewHandler(env.GetAttentionRequest))
-	goji.Get("/attention_requests", env.NewHandler(env.GetAttentionRequests))
-	goji.Put("/attention_requests/:id", env.NewHandler(env.PutAttentionRequest))
-	goji.Patch("/attention_requests/:id", env.NewHandler(env.PatchAttentionRequest))
-
-	goji.Post("/news_items", env.NewHandler(env.PostNewsItem))
-	goji.Get("/news_items/:id", env.NewHandler(env.GetNewsItem))
-	goji.Get("/news_items", env.NewHandler(env.GetNewsItems))
-	goji.Put("/news_items/:id", env.NewHandler(env.PutNewsItem))
-	goji.Patch("/news_items/:id", env.NewHandler(env.PatchNewsItem))
+	addRoutes(env, goji.DefaultMux)
+}
+
+// addRoutes registers every route of the API on m, returning them, as in
+// "GET /users/:id", for routes_test.go to check against the OpenAPI spec.
+func addRoutes(env *api.Env, m *web.Mux) []string {
+	routes := make([]string, 0)
+	route := func(
+		method string, register func(web.PatternType, web.HandlerType),
+	) func(string, web.HandlerType) {
+		return func(pattern string, handler web.HandlerType) {
+			routes = append(routes, method+" "+pattern)
+			register(pattern, handler)
+		}
+	}
+
+	get, post, put := route("GET", m.Get), route("POST", m.Post),
+		route("PUT", m.Put)
+	patch, del := route("PATCH", m.Patch), route("DELETE", m.Delete)
+
+	get("/openapi.json", env.NewHandler(env.GetOpenAPI))
+
+	post("/sessions", env.NewHandler(env.PostSession))
+	del("/sessions/:token", env.NewHandler(env.DeleteSession))
+
+	post("/otps", env.NewHandler(env.PostOTP))
+
+	post("/oauth_states", env.NewHandler(env.PostOAuthState))
+	post("/identities", env.NewHandler(env.PostIdentity))
+	get("/identities", env.NewHandler(env.GetIdentities))
+	del("/identities/:id", env.NewHandler(env.DeleteIdentity))
+
+	post("/api_tokens", env.NewHandler(env.PostApiToken))
+	get("/api_tokens", env.NewHandler(env.GetApiTokens))
+	del("/api_tokens/:id", env.NewHandler(env.DeleteApiToken))
+
+	post("/roles", env.NewHandler(env.PostRole))
+	get("/roles", env.NewHandler(env.GetRoles))
+	del("/roles/:id", env.NewHandler(env.DeleteRole))
+
+	get("/audits", env.NewHandler(env.GetAudits))
+	get("/revisions", env.NewHandler(env.GetRevisions))
+
+	get("/search", env.NewHandler(env.GetSearch))
+
+	post("/users", env.NewHandler(env.PostUser))
+	get("/users/:id", env.NewHandler(env.GetUser))
+	get("/users", env.NewHandler(env.GetUsers))
+	put("/users/:id", env.NewHandler(env.PutUser))
+	del("/users/:id", env.NewHandler(env.DeleteUser))
+	post("/users/:id/verification", env.NewHandler(env.PostUserVerification))
+	get("/users/:id/export", env.NewHandler(env.GetUserExport))
+	get("/users/:id/related_accounts", env.NewHandler(env.GetUserRelatedAccounts))
+
+	post("/teams", env.NewHandler(env.PostTeam))
+	get("/teams/:id", env.NewHandler(env.GetTeam))
+	get("/teams", env.NewHandler(env.GetTeams))
+	put("/teams/:id", env.NewHandler(env.PutTeam))
+	patch("/teams/:id", env.NewHandler(env.PatchTeam))
+
+	post("/user_team_requests", env.NewHandler(env.PostUserTeamRequest))
+	get("/user_team_requests/:id", env.NewHandler(env.GetUserTeamRequest))
+	get("/user_team_requests", env.NewHandler(env.GetUserTeamRequests))
+	patch("/user_team_requests/:id", env.NewHandler(env.PatchUserTeamRequest))
+
+	get("/user_teams", env.NewHandler(env.GetUserTeams))
+	get("/user_teams/:id", env.NewHandler(env.GetUserTeam))
+	patch("/user_teams/:id", env.NewHandler(env.PatchUserTeam))
+
+	post("/games", env.NewHandler(env.PostGame))
+	get("/games/:id", env.NewHandler(env.GetGame))
+	get("/games", env.NewHandler(env.GetGames))
+	put("/games/:id", env.NewHandler(env.PutGame))
+
+	post("/game_maps", env.NewHandler(env.PostGameMap))
+	get("/game_maps/:id", env.NewHandler(env.GetGameMap))
+	get("/game_maps", env.NewHandler(env.GetGameMaps))
+	put("/game_maps/:id", env.NewHandler(env.PutGameMap))
+
+	post("/user_games", env.NewHandler(env.PostUserGame))
+	get("/user_games/:id", env.NewHandler(env.GetUserGame))
+	get("/user_games", env.NewHandler(env.GetUserGames))
+	patch("/user_games/:id", env.NewHandler(env.PatchUserGame))
+
+	get("/game_servers/:id", env.NewHandler(env.GetGameServer))
+	get("/game_servers", env.NewHandler(env.GetGameServers))
+
+	get("/verification_attempts", env.NewHandler(env.GetVerificationAttempts))
+	get("/account_alerts", env.NewHandler(env.GetAccountAlerts))
+	patch("/account_alerts/:id", env.NewHandler(env.PatchAccountAlert))
+
+	post("/tournaments", env.NewHandler(env.PostTournament))
+	get("/tournaments/:id", env.NewHandler(env.GetTournament))
+	get("/tournaments", env.NewHandler(env.GetTournaments))
+	put("/tournaments/:id", env.NewHandler(env.PutTournament))
+
+	post("/seasons", env.NewHandler(env.PostSeason))
+	get("/seasons/:id", env.NewHandler(env.GetSeason))
+	get("/seasons", env.NewHandler(env.GetSeasons))
+	put("/seasons/:id", env.NewHandler(env.PutSeason))
+	patch("/seasons/:id", env.NewHandler(env.PatchSeason))
+
+	post("/team_season_requests", env.NewHandler(env.PostTeamSeasonRequest))
+	get("/team_season_requests/:id", env.NewHandler(env.GetTeamSeasonRequest))
+	get("/team_season_requests", env.NewHandler(env.GetTeamSeasonRequests))
+	patch("/team_season_requests/:id", env.NewHandler(env.PatchTeamSeasonRequest))
+
+	get("/team_seasons", env.NewHandler(env.GetTeamSeasons))
+	get("/team_seasons/:id", env.NewHandler(env.GetTeamSeason))
+	patch("/team_seasons/:id", env.NewHandler(env.PatchTeamSeason))
+
+	post("/stages", env.NewHandler(env.PostStage))
+	get("/stages/:id", env.NewHandler(env.GetStage))
+	get("/stages", env.NewHandler(env.GetStages))
+	put("/stages/:id", env.NewHandler(env.PutStage))
+
+	post("/brackets", env.NewHandler(env.PostBracket))
+	get("/brackets/:id", env.NewHandler(env.GetBracket))
+	get("/brackets", env.NewHandler(env.GetBrackets))
+	put("/brackets/:id", env.NewHandler(env.PutBracket))
+	patch("/brackets/:id", env.NewHandler(env.PatchBracket))
+	get("/brackets/:id/standings", env.NewHandler(env.GetBracketStandings))
+
+	post("/bracket_maps", env.NewHandler(env.PostBracketMap))
+	get("/bracket_maps/:id", env.NewHandler(env.GetBracketMap))
+	get("/bracket_maps", env.NewHandler(env.GetBracketMaps))
+
+	get("/bracket_rounds/:id", env.NewHandler(env.GetBracketRound))
+	get("/bracket_rounds", env.NewHandler(env.GetBracketRounds))
+
+	get("/matches/:id", env.NewHandler(env.GetMatch))
+	get("/matches", env.NewHandler(env.GetMatches))
+	put("/matches/:id", env.NewHandler(env.PutMatch))
+	get("/matches/:id/leadership", env.NewHandler(env.GetMatchLeadership))
+	patch("/matches/:id", env.NewHandler(env.PatchMatch))
+
+	get("/match_maps/:id", env.NewHandler(env.GetMatchMap))
+	get("/match_maps", env.NewHandler(env.GetMatchMaps))
+
+	post("/match_reports", env.NewHandler(env.PostMatchReport, api.ScopeReport))
+	get("/match_reports/:id", env.NewHandler(env.GetMatchReport))
+	get("/match_reports", env.NewHandler(env.GetMatchReports))
+	patch("/match_reports/:id", env.NewHandler(env.PatchMatchReport, api.ScopeReport))
+
+	get("/match_rounds/:id", env.NewHandler(env.GetMatchRound))
+	get("/match_rounds", env.NewHandler(env.GetMatchRounds))
+	get("/match_penalties/:id", env.NewHandler(env.GetMatchPenalty))
+	get("/match_penalties", env.NewHandler(env.GetMatchPenalties))
+
+	post("/comments", env.NewHandler(env.PostComment))
+	get("/comments/:id", env.NewHandler(env.GetComment))
+	get("/comments", env.NewHandler(env.GetComments))
+	put("/comments/:id", env.NewHandler(env.PutComment))
+	patch("/comments/:id", env.NewHandler(env.PatchComment))
+
+	post("/attention_requests", env.NewHandler(env.PostAttentionRequest))
+	get("/attention_requests/:id", env.NewHandler(env.GetAttentionRequest))
+	get("/attention_requests", env.NewHandler(env.GetAttentionRequests))
+	put("/attention_requests/:id", env.NewHandler(env.PutAttentionRequest))
+	patch("/attention_requests/:id", env.NewHandler(env.PatchAttentionRequest))
+
+	post("/news_items", env.NewHandler(env.PostNewsItem))
+	get("/news_items/:id", env.NewHandler(env.GetNewsItem))
+	get("/news_items", env.NewHandler(env.GetNewsItems))
+	put("/news_items/:id", env.NewHandler(env.PutNewsItem))
+	patch("/news_items/:id", env.NewHandler(env.PatchNewsItem))
+
+	return routes
+}
+
+// setupWorkerRoutes is setupRoutes for the worker, which only has itself to
+// talk about, to admins.
+func setupWorkerRoutes(env *api.Env, pool *worker.Pool) {
+	goji.Use(env.NewMiddleware(env.Auth))
+
+	goji.Get("/status", env.NewHandler(pool.GetStatus))
+	goji.Get("/metrics", env.NewHandler(pool.GetMetrics))
 }
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/zenazn/goji/web"
+
+	"app/api"
+)
+
+func TestRoutesSpec(t *testing.T) {
+	err := api.CheckSpec(addRoutes(&api.Env{}, web.New()))
+	if err != nil {
+		t.Error(err)
+	}
+}
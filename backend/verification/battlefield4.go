@@ -0,0 +1,74 @@
+package verification
+
+import (
+	"app/utils"
+)
+
+const Battlefield4Handle = "battlefield-4"
+
+// Battlefield4 verifies players on RCON servers, which tell their EA and
+// PunkBuster GUIDs and IP, as eaId, pbId and ip, once they type their token
+// in the chat, and then looks them up in Stats, by name.
+type Battlefield4 struct {
+	Stats StatsProvider
+}
+
+func (*Battlefield4) Handle() string {
+	return Battlefield4Handle
+}
+
+func (*Battlefield4) Identifiers() []string {
+	return []string{"eaId", "pbId", "blUserId", "blPersonaId"}
+}
+
+func (*Battlefield4) Signals() []string {
+	return []string{"ip"}
+}
+
+func (*Battlefield4) IssueToken() (string, error) {
+	return utils.GenerateToken(5, true)
+}
+
+func (v *Battlefield4) Check(claim *Claim) (*Profile, error) {
+	personas, err := v.Stats.Search(claim.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, x := range personas {
+		if x.Name != claim.Name || x.Platform != "pc" {
+			continue
+		}
+
+		return &Profile{
+			Name: claim.Name,
+			Link: v.Stats.Link(x.Id),
+			Data: map[string]string{
+				"eaId":        claim.Data["eaId"],
+				"pbId":        claim.Data["pbId"],
+				"blUserId":    x.UserId,
+				"blPersonaId": x.Id,
+				"ip":          claim.Data["ip"],
+			},
+		}, nil
+	}
+
+	return nil, ErrNoProfile
+}
+
+// Refresh only keeps up with renames, since the rest never changes.
+func (v *Battlefield4) Refresh(data map[string]string) (*Profile, error) {
+	id, ok := data["blPersonaId"]
+	if !ok {
+		return nil, ErrNoProfile
+	}
+
+	persona, err := v.Stats.Persona(id)
+	if err == ErrNoPersona {
+		return nil, ErrNoProfile
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &Profile{Name: persona.Name, Link: v.Stats.Link(id)}, nil
+}
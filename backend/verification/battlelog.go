@@ -0,0 +1,123 @@
+package verification
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const bl = "http://battlelog.battlefield.com/bf4"
+
+var errBadBattlelog = errors.New("bad battlelog response")
+
+type searchResultItem struct {
+	UserId      string
+	PersonaId   string
+	PersonaName string
+	Namespace   string
+}
+
+type searchResult struct {
+	Type    string
+	Message string
+	Data    []searchResultItem
+}
+
+type soldierStats struct {
+	Template string
+	Context  struct {
+		PersonaName string
+	}
+}
+
+// Battlelog is the StatsProvider of Battlefield 4, as long as it lasts. The
+// most frequent it can be polled is 20 requests per 15 seconds, which is
+// north of one per second.
+type Battlelog struct {
+	HTTP *HTTPClient
+}
+
+func NewBattlelog() *Battlelog {
+	return &Battlelog{NewHTTPClient()}
+}
+
+func (b *Battlelog) Search(name string) ([]Persona, error) {
+	resp, err := b.HTTP.Do(func() (*http.Request, error) {
+		form := url.Values{"query": {name}}.Encode()
+		req, err := http.NewRequest(
+			"POST", bl+"/search/query/", strings.NewReader(form),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	var sr searchResult
+	err = json.NewDecoder(resp.Body).Decode(&sr)
+	if err != nil {
+		return nil, err
+	} else if sr.Type != "success" || sr.Message != "RESULT" {
+		return nil, errBadBattlelog
+	}
+
+	personas := make([]Persona, 0, len(sr.Data))
+	for _, x := range sr.Data {
+		platform := x.Namespace
+		if platform == "cem_ea_id" {
+			platform = "pc"
+		}
+
+		personas = append(personas, Persona{
+			UserId:   x.UserId,
+			Id:       x.PersonaId,
+			Name:     x.PersonaName,
+			Platform: platform,
+		})
+	}
+
+	return personas, nil
+}
+
+// Persona knows only the name, since that's all that the stats page has.
+func (b *Battlelog) Persona(id string) (*Persona, error) {
+	resp, err := b.HTTP.Do(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", b.Link(id), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("X-AjaxNavigation", "1")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNoPersona
+	}
+
+	var ss soldierStats
+	err = json.NewDecoder(resp.Body).Decode(&ss)
+	if err != nil {
+		return nil, err
+	} else if ss.Context.PersonaName == "" {
+		return nil, errBadBattlelog
+	}
+
+	return &Persona{Id: id, Name: ss.Context.PersonaName, Platform: "pc"}, nil
+}
+
+func (b *Battlelog) Link(id string) string {
+	return bl + "/soldier/-/stats/" + id + "/pc"
+}
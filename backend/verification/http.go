@@ -0,0 +1,187 @@
+package verification
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("too many failures in a row, try again later")
+
+// HTTPClient is for talking to sites that can't take much and go down every
+// now and then. Requests to the same host are spaced out by Interval, failed
+// ones are retried up to Retries times, Backoff apart, doubling every time,
+// and after MaxFailures requests fail in a row, the host isn't bothered for
+// Cooldown. A request fails if it can't be made at all, or gets a 5xx or a
+// 429, the rest are up to the caller. A Retry-After on a failed response
+// holds back every request to the host until then, and if it's further away
+// than Cooldown, the circuit is opened for that long instead of retrying.
+type HTTPClient struct {
+	Client      http.Client
+	Interval    time.Duration
+	Retries     int
+	Backoff     time.Duration
+	MaxFailures int
+	Cooldown    time.Duration
+
+	mutex sync.Mutex
+	hosts map[string]*host
+}
+
+type host struct {
+	next      time.Time // the earliest the next request can go out
+	failures  int
+	openUntil time.Time
+}
+
+func NewHTTPClient() *HTTPClient {
+	return &HTTPClient{
+		Client:      http.Client{Timeout: 10 * time.Second},
+		Interval:    time.Second,
+		Retries:     2,
+		Backoff:     time.Second,
+		MaxFailures: 5,
+		Cooldown:    time.Minute,
+	}
+}
+
+// Do sends the request that newRequest makes, making a new one for every
+// retry, since bodies can be read only once.
+func (c *HTTPClient) Do(
+	newRequest func() (*http.Request, error),
+) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := c.host(req.URL.Host)
+	if err != nil {
+		httpRequestsTotal.Inc(req.URL.Host, "circuit_open")
+		return nil, err
+	}
+
+	backoff := c.Backoff
+	var retryAfter time.Duration
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+			req, err = newRequest()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		c.wait(h)
+		var resp *http.Response
+		retryAfter = 0
+		resp, err = c.Client.Do(req)
+		if err == nil && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusTooManyRequests {
+			httpRequestsTotal.Inc(req.URL.Host, "ok")
+			c.succeeded(h)
+			return resp, nil
+		} else if err == nil {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			resp.Body.Close()
+			err = errors.New(req.URL.Host + " responded with " + resp.Status)
+		}
+
+		httpRequestsTotal.Inc(req.URL.Host, "error")
+		if attempt >= c.Retries || retryAfter > c.Cooldown {
+			break
+		}
+
+		c.delay(h, retryAfter)
+	}
+
+	c.failed(h, retryAfter)
+	return nil, err
+}
+
+// parseRetryAfter reads either form of Retry-After, delay-seconds or a date,
+// as a duration from now, which is 0 if there's none or it has passed.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+
+		return 0
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil || !at.After(now) {
+		return 0
+	}
+
+	return at.Sub(now)
+}
+
+// host finds the state of name, unless its circuit is open.
+func (c *HTTPClient) host(name string) (*host, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.hosts == nil {
+		c.hosts = make(map[string]*host)
+	}
+
+	h, ok := c.hosts[name]
+	if !ok {
+		h = &host{}
+		c.hosts[name] = h
+	}
+
+	if time.Now().Before(h.openUntil) {
+		return nil, ErrCircuitOpen
+	}
+
+	return h, nil
+}
+
+// wait takes the next slot for h and sleeps until then.
+func (c *HTTPClient) wait(h *host) {
+	c.mutex.Lock()
+	now := time.Now()
+	at := h.next
+	if at.Before(now) {
+		at = now
+	}
+
+	h.next = at.Add(c.Interval)
+	c.mutex.Unlock()
+	time.Sleep(at.Sub(now))
+}
+
+// delay holds back the next request to h for at least d.
+func (c *HTTPClient) delay(h *host, d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if at := time.Now().Add(d); at.After(h.next) {
+		h.next = at
+	}
+}
+
+func (c *HTTPClient) succeeded(h *host) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	h.failures = 0
+}
+
+// failed opens the circuit once there have been enough failures, or if the
+// host has asked to be left alone for longer than the cooldown. Failures
+// aren't reset, so that the first request after the cooldown opens it again
+// if it fails too.
+func (c *HTTPClient) failed(h *host, retryAfter time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	h.failures++
+	if retryAfter > c.Cooldown {
+		h.openUntil = time.Now().Add(retryAfter)
+	} else if h.failures >= c.MaxFailures {
+		h.openUntil = time.Now().Add(c.Cooldown)
+	}
+}
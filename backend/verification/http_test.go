@@ -0,0 +1,233 @@
+package verification_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"app/verification"
+)
+
+// server answers with whatever respond says, keeping track of when requests
+// come in.
+type server struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	times   []time.Time
+	respond func(w http.ResponseWriter, n int)
+}
+
+func newServer(
+	t *testing.T, respond func(w http.ResponseWriter, n int),
+) *server {
+	s := &server{respond: respond}
+	s.Server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s.mutex.Lock()
+			s.times = append(s.times, time.Now())
+			n := len(s.times)
+			s.mutex.Unlock()
+			s.respond(w, n)
+		},
+	))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *server) requests() []time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]time.Time{}, s.times...)
+}
+
+func (s *server) get(c *verification.HTTPClient) error {
+	resp, err := c.Do(func() (*http.Request, error) {
+		return http.NewRequest("GET", s.URL, nil)
+	})
+	if err == nil {
+		resp.Body.Close()
+	}
+
+	return err
+}
+
+func status(code int) func(w http.ResponseWriter, n int) {
+	return func(w http.ResponseWriter, n int) {
+		w.WriteHeader(code)
+	}
+}
+
+// client doesn't wait for anything, unless a test says otherwise.
+func client() *verification.HTTPClient {
+	return &verification.HTTPClient{
+		Retries:     2,
+		Backoff:     time.Millisecond,
+		MaxFailures: 100,
+		Cooldown:    time.Minute,
+	}
+}
+
+func TestRetries(t *testing.T) {
+	for _, test := range []struct {
+		code     int
+		requests int
+		fails    bool
+	}{
+		{http.StatusOK, 1, false},
+		{http.StatusNotFound, 1, false},
+		{http.StatusTooManyRequests, 3, true},
+		{http.StatusInternalServerError, 3, true},
+		{http.StatusServiceUnavailable, 3, true},
+	} {
+		s := newServer(t, status(test.code))
+		err := s.get(client())
+		if (err != nil) != test.fails {
+			t.Errorf("%d: got error %v", test.code, err)
+		}
+
+		if n := len(s.requests()); n != test.requests {
+			t.Errorf("%d: got %d requests, expected %d", test.code, n, test.requests)
+		}
+	}
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	s := newServer(t, func(w http.ResponseWriter, n int) {
+		if n == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	err := s.get(client())
+	if err != nil {
+		t.Fatal(err)
+	} else if n := len(s.requests()); n != 2 {
+		t.Errorf("got %d requests, expected 2", n)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	s := newServer(t, status(http.StatusOK))
+	c := client()
+	c.Interval = interval
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.get(c)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	times := s.requests()
+	if len(times) != 3 {
+		t.Fatalf("got %d requests, expected 3", len(times))
+	}
+
+	for i := 1; i < len(times); i++ {
+		// a little slack, for the server's clock being read a bit later
+		if gap := times[i].Sub(times[i-1]); gap < interval*9/10 {
+			t.Errorf("requests %d and %d were %v apart", i-1, i, gap)
+		}
+	}
+}
+
+func TestCircuit(t *testing.T) {
+	const cooldown = 100 * time.Millisecond
+	code := http.StatusInternalServerError
+	var mutex sync.Mutex
+	s := newServer(t, func(w http.ResponseWriter, n int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		w.WriteHeader(code)
+	})
+	c := client()
+	c.Retries = 0
+	c.MaxFailures = 2
+	c.Cooldown = cooldown
+	for i := 0; i < 2; i++ {
+		err := s.get(c)
+		if err == nil || err == verification.ErrCircuitOpen {
+			t.Fatalf("failure %d: got %v", i, err)
+		}
+	}
+
+	err := s.get(c)
+	if err != verification.ErrCircuitOpen {
+		t.Fatalf("got %v, expected ErrCircuitOpen", err)
+	} else if n := len(s.requests()); n != 2 {
+		t.Errorf("got %d requests with the circuit open, expected 2", n)
+	}
+
+	time.Sleep(cooldown)
+	err = s.get(c)
+	if err == nil || err == verification.ErrCircuitOpen {
+		t.Fatalf("after the cooldown: got %v", err)
+	} else if err = s.get(c); err != verification.ErrCircuitOpen {
+		t.Fatalf("got %v, expected the circuit to open again", err)
+	}
+
+	time.Sleep(cooldown)
+	mutex.Lock()
+	code = http.StatusOK
+	mutex.Unlock()
+	err = s.get(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a success resets the failures, so one more doesn't open the circuit
+	mutex.Lock()
+	code = http.StatusInternalServerError
+	mutex.Unlock()
+	err = s.get(c)
+	if err == nil || err == verification.ErrCircuitOpen {
+		t.Fatalf("got %v", err)
+	} else if n := len(s.requests()); n != 5 {
+		t.Errorf("got %d requests, expected 5", n)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	s := newServer(t, func(w http.ResponseWriter, n int) {
+		if n == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	})
+	err := s.get(client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	times := s.requests()
+	if len(times) != 2 {
+		t.Fatalf("got %d requests, expected 2", len(times))
+	} else if gap := times[1].Sub(times[0]); gap < 900*time.Millisecond {
+		t.Errorf("retried after %v, expected a second", gap)
+	}
+}
+
+func TestRetryAfterCooldown(t *testing.T) {
+	s := newServer(t, func(w http.ResponseWriter, n int) {
+		w.Header().Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(
+			http.TimeFormat,
+		))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c := client()
+	err := s.get(c)
+	if err == nil || err == verification.ErrCircuitOpen {
+		t.Fatalf("got %v", err)
+	} else if err = s.get(c); err != verification.ErrCircuitOpen {
+		t.Fatalf("got %v, expected ErrCircuitOpen", err)
+	} else if n := len(s.requests()); n != 1 {
+		t.Errorf("got %d requests, expected 1, without retries", n)
+	}
+}
@@ -0,0 +1,34 @@
+package verification
+
+import (
+	"time"
+
+	"app/metrics"
+)
+
+var (
+	stepSeconds = metrics.Default.Summary(
+		"verification_step_seconds",
+		"Time spent on each step of verifying a claim, in seconds.",
+		"handle", "step",
+	)
+	attemptsTotal = metrics.Default.Counter(
+		"verification_attempts_total",
+		"Verification attempts, by outcome.",
+		"handle", "outcome",
+	)
+	httpRequestsTotal = metrics.Default.Counter(
+		"verification_http_requests_total",
+		"Requests to stats sites like Battlelog, retries included, by result: "+
+			"ok, error or circuit_open.",
+		"host", "result",
+	)
+)
+
+// timeStep records how long step has taken since start, returning the time
+// it ended, which is when the next one starts.
+func timeStep(handle, step string, start time.Time) time.Time {
+	now := time.Now()
+	stepSeconds.Observe(now.Sub(start).Seconds(), handle, step)
+	return now
+}
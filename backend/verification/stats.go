@@ -0,0 +1,26 @@
+package verification
+
+import (
+	"errors"
+)
+
+var ErrNoPersona = errors.New("no such persona")
+
+// Persona is a player as a stats site knows them. UserId is the account the
+// persona belongs to, which can have other personas, and Platform is "pc",
+// or whatever else the site calls the rest.
+type Persona struct {
+	UserId   string `json:"userId"`
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Platform string `json:"platform"`
+}
+
+// StatsProvider is wherever verifiers look players up. Search matches names
+// in full or in part, and Persona gives ErrNoPersona for unknown ids. Link is
+// where people can go to see a persona for themselves.
+type StatsProvider interface {
+	Search(name string) ([]Persona, error)
+	Persona(id string) (*Persona, error)
+	Link(id string) string
+}
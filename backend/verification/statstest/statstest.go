@@ -0,0 +1,110 @@
+// Package statstest is a stats provider that knows only the personas it has
+// fixtures for, so that verification can be run without any stats site,
+// whether in tests or in development.
+package statstest
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"app/verification"
+)
+
+// Provider is a verification.StatsProvider over a fixed set of personas.
+type Provider struct {
+	mutex    sync.Mutex
+	personas []verification.Persona
+	err      error
+	calls    int
+}
+
+func New(personas ...verification.Persona) *Provider {
+	return &Provider{personas: personas}
+}
+
+// Load reads personas from a JSON file, an array of objects like
+// {"userId": "1", "id": "2", "name": "Name", "platform": "pc"}.
+func Load(path string) (*Provider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	var personas []verification.Persona
+	err = json.NewDecoder(f).Decode(&personas)
+	if err != nil {
+		return nil, err
+	}
+
+	return New(personas...), nil
+}
+
+// Add makes persona known, replacing whichever one had the same id.
+func (p *Provider) Add(persona verification.Persona) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, x := range p.personas {
+		if x.Id == persona.Id {
+			p.personas[i] = persona
+			return
+		}
+	}
+
+	p.personas = append(p.personas, persona)
+}
+
+// Fail makes every call return err, until it's called again with nil.
+func (p *Provider) Fail(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.err = err
+}
+
+// Calls tells how many times the provider has been asked anything.
+func (p *Provider) Calls() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.calls
+}
+
+func (p *Provider) Search(name string) ([]verification.Persona, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	found := make([]verification.Persona, 0)
+	for _, x := range p.personas {
+		if strings.Contains(strings.ToLower(x.Name), strings.ToLower(name)) {
+			found = append(found, x)
+		}
+	}
+
+	return found, nil
+}
+
+func (p *Provider) Persona(id string) (*verification.Persona, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	for _, x := range p.personas {
+		if x.Id == id {
+			return &x, nil
+		}
+	}
+
+	return nil, verification.ErrNoPersona
+}
+
+func (p *Provider) Link(id string) string {
+	return "http://stats.invalid/personas/" + id
+}
@@ -0,0 +1,303 @@
+// Package verification proves that users own the games they say they own,
+// the way that each game allows, keyed by Game.VerificationHandle. Users get
+// a token to show in the game, whatever sees it there makes a Claim out of
+// it, and a Verifier checks that claim against the outside world, turning it
+// into a Profile, which is kept fresh afterwards.
+package verification
+
+import (
+	"errors"
+	"time"
+
+	"app/models"
+	"app/utils"
+)
+
+var (
+	ErrUnknownHandle = errors.New("unknown verification handle")
+	ErrUnknownToken  = errors.New("token not found")
+	ErrNoProfile     = errors.New("no profile found for this player")
+	ErrDuplicate     = errors.New("identifiers taken by another user")
+)
+
+// Claim is what the game has told about a player who has shown a token: their
+// name there, and whatever identifies them, like GUIDs.
+type Claim struct {
+	Token string
+	Name  string
+	Data  map[string]string
+}
+
+// Profile is what's kept about a verified player. Data ends up in
+// UserGame.Data, and its keys listed in Identifiers are the ones that no two
+// user-game pairs of the same game can share, while those in Signals can be
+// shared, like IPs, but make admins look into it.
+type Profile struct {
+	Name string
+	Link string
+	Data map[string]string
+}
+
+// Verifier is a way of verifying a single game. Check gives ErrNoProfile when
+// the claim doesn't add up, and Refresh takes the data of a profile that Check
+// has made before, giving it back up to date.
+type Verifier interface {
+	Handle() string
+	Identifiers() []string
+	Signals() []string
+	IssueToken() (string, error)
+	Check(claim *Claim) (*Profile, error)
+	Refresh(data map[string]string) (*Profile, error)
+}
+
+type Env struct {
+	verifiers map[string]Verifier
+}
+
+func New(verifiers ...Verifier) *Env {
+	e := &Env{make(map[string]Verifier)}
+	for _, v := range verifiers {
+		e.verifiers[v.Handle()] = v
+	}
+
+	return e
+}
+
+func (e *Env) Verifier(handle string) (Verifier, error) {
+	v, ok := e.verifiers[handle]
+	if !ok {
+		return nil, ErrUnknownHandle
+	}
+
+	return v, nil
+}
+
+func (e *Env) Handles() []string {
+	handles := make([]string, 0, len(e.verifiers))
+	for handle := range e.verifiers {
+		handles = append(handles, handle)
+	}
+
+	return handles
+}
+
+// Verify finds the user-game pair that the claim's token was issued for,
+// checks the claim, and marks the pair as verified, unless another user's
+// pair has the same identifiers, which gives ErrDuplicate and an alert for
+// admins. Every attempt is recorded, along with its outcome, the error being
+// the reason.
+func (e *Env) Verify(
+	m *models.Env, handle string, claim *Claim,
+) (*models.UserGame, error) {
+	v, err := e.Verifier(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	game, err := m.GetGameByVerificationHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := &models.VerificationAttempt{
+		GameId: game.Id,
+		Name:   &claim.Name,
+		Token:  &claim.Token,
+		Data:   models.JSONMap(claim.Data),
+	}
+	userGame, err := e.verify(m, v, claim, attempt)
+	switch err {
+	case nil:
+		attempt.Outcome = models.VerificationVerified
+	case ErrUnknownToken:
+		attempt.Outcome = models.VerificationUnknownToken
+	case ErrNoProfile:
+		attempt.Outcome = models.VerificationNoProfile
+	case ErrDuplicate:
+		attempt.Outcome = models.VerificationDuplicate
+	default:
+		attempt.Outcome = models.VerificationFailed
+	}
+
+	if err != nil {
+		reason := err.Error()
+		attempt.Reason = &reason
+	}
+
+	start := time.Now()
+	attemptErr := m.CreateVerificationAttempt(attempt)
+	timeStep(handle, "attempt", start)
+	attemptsTotal.Inc(handle, attempt.Outcome)
+	if err != nil {
+		return nil, err
+	} else if attemptErr != nil {
+		return nil, attemptErr
+	}
+
+	return userGame, nil
+}
+
+// verify times each of its steps: finding the token, checking the claim, and
+// saving the pair.
+func (e *Env) verify(
+	m *models.Env, v Verifier, claim *Claim,
+	attempt *models.VerificationAttempt,
+) (*models.UserGame, error) {
+	start := time.Now()
+	userGame, err := m.GetUserGameByHandleToken(v.Handle(), claim.Token)
+	start = timeStep(v.Handle(), "token", start)
+	if err == utils.ErrNotFound {
+		return nil, ErrUnknownToken
+	} else if err != nil {
+		return nil, err
+	}
+
+	attempt.UserGameId = &userGame.Id
+	profile, err := v.Check(claim)
+	start = timeStep(v.Handle(), "check", start)
+	if err != nil {
+		return nil, err
+	}
+
+	defer timeStep(v.Handle(), "save", start)
+
+	// identifiers are claimed along with the pair itself, so that two users
+	// verifying with the same ones at the same time can't both succeed
+	var taken *models.UserGameIdentifier
+	now := time.Now()
+	err = m.Atomic(func(etx *models.Env) error {
+		for _, key := range v.Identifiers() {
+			if profile.Data[key] == "" {
+				continue
+			}
+
+			identifier := &models.UserGameIdentifier{
+				GameId:     userGame.GameId,
+				Key:        key,
+				Value:      profile.Data[key],
+				UserGameId: userGame.Id,
+			}
+			claimed, inerr := etx.ClaimUserGameIdentifier(identifier)
+			if inerr != nil {
+				return inerr
+			} else if !claimed {
+				taken = identifier
+				return ErrDuplicate
+			}
+		}
+
+		userGame.Token = nil
+		userGame.Data = models.JSONMap(profile.Data)
+		userGame.Name = &profile.Name
+		userGame.Link = &profile.Link
+		userGame.VerifiedAt = &now
+		userGame.DataUpdatedAt = &now
+		return etx.UpdateUserGame(userGame)
+	})
+	if err == ErrDuplicate {
+		return nil, alertDuplicate(m, userGame, taken)
+	} else if err != nil {
+		return nil, err
+	}
+
+	alertShared(m, v, userGame)
+	return userGame, nil
+}
+
+// alertDuplicate tells admins that userGame's user has tried to verify with
+// an identifier of somebody else's, returning ErrDuplicate unless something
+// else goes wrong.
+func alertDuplicate(
+	m *models.Env, userGame *models.UserGame, taken *models.UserGameIdentifier,
+) error {
+	holder, err := m.GetUserGameById(taken.UserGameId)
+	if err != nil {
+		return err
+	}
+
+	err = m.CreateAccountAlert(&models.AccountAlert{
+		Kind:          models.AccountAlertDuplicate,
+		GameId:        userGame.GameId,
+		UserId:        userGame.UserId,
+		RelatedUserId: holder.UserId,
+		Key:           taken.Key,
+		Value:         taken.Value,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ErrDuplicate
+}
+
+// alertShared tells admins about other users having the same signals as the
+// freshly verified userGame. It's only a hint, so it doesn't fail anything.
+func alertShared(m *models.Env, v Verifier, userGame *models.UserGame) {
+	for _, key := range v.Signals() {
+		value := userGame.Data[key]
+		if value == "" {
+			continue
+		}
+
+		userIds, err := m.GetUserIdsByGameData(
+			userGame.GameId, key, value, userGame.UserId,
+		)
+		if err != nil {
+			continue
+		}
+
+		for _, userId := range userIds {
+			m.CreateAccountAlert(&models.AccountAlert{
+				Kind:          models.AccountAlertShared,
+				GameId:        userGame.GameId,
+				UserId:        userGame.UserId,
+				RelatedUserId: userId,
+				Key:           key,
+				Value:         value,
+			})
+		}
+	}
+}
+
+// Refresh brings the profile of a verified user-game pair up to date.
+func (e *Env) Refresh(
+	m *models.Env, handle string, userGame *models.UserGame,
+) error {
+	v, err := e.Verifier(handle)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	profile, err := v.Refresh(userGame.Data)
+	if err == ErrNoProfile {
+		// like ones that admins have approved by hand, which there's nothing to
+		// refresh for, so no point in trying every minute
+		userGame.DataUpdatedAt = &now
+		userGame.DataUpdateRequestedAt = nil
+		updateErr := m.UpdateUserGame(userGame)
+		if updateErr != nil {
+			return updateErr
+		}
+
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	data := make(models.JSONMap)
+	for key, value := range userGame.Data {
+		data[key] = value
+	}
+
+	for key, value := range profile.Data {
+		data[key] = value
+	}
+
+	userGame.Data = data
+	userGame.Name = &profile.Name
+	userGame.Link = &profile.Link
+	userGame.DataUpdatedAt = &now
+	userGame.DataUpdateRequestedAt = nil
+	return m.UpdateUserGame(userGame)
+}
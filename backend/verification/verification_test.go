@@ -0,0 +1,214 @@
+package verification_test
+
+import (
+	"testing"
+
+	"app/models"
+	"app/models/modelstest"
+	"app/verification"
+	"app/verification/statstest"
+)
+
+// bf4 is Battlefield4 under a handle of its own, so that every test has a
+// game of its own, and identifiers that nobody else has taken.
+type bf4 struct {
+	*verification.Battlefield4
+	handle string
+}
+
+func (v *bf4) Handle() string {
+	return v.handle
+}
+
+type fixture struct {
+	m     *models.Env
+	env   *verification.Env
+	stats *statstest.Provider
+	game  *models.Game
+}
+
+func setup(t *testing.T) *fixture {
+	m := modelstest.New(t)
+	handle := "test-" + modelstest.Unique(t)
+	game := &models.Game{
+		GamePublic:         models.GamePublic{Slug: handle, Name: handle},
+		VerificationHandle: &handle,
+		CreatedBy:          modelstest.User(t, m).Id,
+	}
+	err := m.CreateGame(game)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := statstest.New()
+	v := &bf4{&verification.Battlefield4{Stats: stats}, handle}
+	return &fixture{m, verification.New(v), stats, game}
+}
+
+// userGame makes a user with an unverified pair, waiting for its token.
+func (f *fixture) userGame(t *testing.T) *models.UserGame {
+	token := modelstest.Unique(t)
+	userGame := &models.UserGame{
+		UserGamePublic: models.UserGamePublic{
+			UserId: modelstest.User(t, f.m).Id, GameId: f.game.Id,
+		},
+		Token: &token,
+		Data:  models.JSONMap{},
+	}
+	err := f.m.CreateUserGame(userGame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return userGame
+}
+
+func (f *fixture) verify(
+	userGame *models.UserGame, name, eaId string,
+) (*models.UserGame, error) {
+	return f.env.Verify(f.m, *f.game.VerificationHandle, &verification.Claim{
+		Token: *userGame.Token,
+		Name:  name,
+		Data:  map[string]string{"eaId": eaId, "ip": "127.0.0.1"},
+	})
+}
+
+// checkOutcome checks how the last attempt at verifying userGame went.
+func (f *fixture) checkOutcome(
+	t *testing.T, userGame *models.UserGame, expected string,
+) {
+	modifier := &models.QueryModifier{Sort: []string{"id DESC"}, Limit: 1}
+	modifier.SetColumnFilter("user_game_id", userGame.Id)
+	attempts, err := f.m.GetVerificationAttempts(modifier)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(attempts) == 0 {
+		t.Fatal("no attempts")
+	} else if attempts[0].Outcome != expected {
+		t.Errorf("got outcome %q, expected %q", attempts[0].Outcome, expected)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	f := setup(t)
+	f.stats.Add(verification.Persona{
+		UserId: "1", Id: "2", Name: "nelly", Platform: "pc",
+	})
+
+	userGame, err := f.verify(f.userGame(t), "nelly", "EA_1")
+	if err != nil {
+		t.Fatal(err)
+	} else if userGame.VerifiedAt == nil || userGame.Token != nil {
+		t.Errorf("not verified: %+v", userGame)
+	} else if userGame.Data["eaId"] != "EA_1" ||
+		userGame.Data["blPersonaId"] != "2" {
+		t.Errorf("got data %v", userGame.Data)
+	}
+
+	f.checkOutcome(t, userGame, models.VerificationVerified)
+}
+
+func TestVerifyNoProfile(t *testing.T) {
+	f := setup(t)
+	f.stats.Add(verification.Persona{
+		UserId: "1", Id: "2", Name: "nelly", Platform: "xbox",
+	})
+
+	userGame := f.userGame(t)
+	_, err := f.verify(userGame, "nelly", "EA_1")
+	if err != verification.ErrNoProfile {
+		t.Errorf("got %v, expected ErrNoProfile", err)
+	}
+
+	f.checkOutcome(t, userGame, models.VerificationNoProfile)
+
+	userGame, err = f.m.GetUserGameById(userGame.Id)
+	if err != nil {
+		t.Fatal(err)
+	} else if userGame.VerifiedAt != nil {
+		t.Error("verified without a profile")
+	}
+}
+
+func TestVerifyDuplicate(t *testing.T) {
+	f := setup(t)
+	f.stats.Add(verification.Persona{
+		UserId: "1", Id: "2", Name: "nelly", Platform: "pc",
+	})
+	f.stats.Add(verification.Persona{
+		UserId: "3", Id: "4", Name: "kora", Platform: "pc",
+	})
+
+	holder, err := f.verify(f.userGame(t), "nelly", "EA_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userGame := f.userGame(t)
+	_, err = f.verify(userGame, "kora", "EA_1")
+	if err != verification.ErrDuplicate {
+		t.Errorf("got %v, expected ErrDuplicate", err)
+	}
+
+	f.checkOutcome(t, userGame, models.VerificationDuplicate)
+
+	modifier := &models.QueryModifier{}
+	modifier.SetColumnFilter("game_id", f.game.Id)
+	modifier.SetColumnFilter("kind", models.AccountAlertDuplicate)
+	alerts, err := f.m.GetAccountAlerts(modifier)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(alerts) != 1 || alerts[0].UserId != userGame.UserId ||
+		alerts[0].RelatedUserId != holder.UserId || alerts[0].Key != "eaId" {
+		t.Errorf("got alerts %+v", alerts)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	f := setup(t)
+	f.stats.Add(verification.Persona{
+		UserId: "1", Id: "2", Name: "nelly", Platform: "pc",
+	})
+
+	userGame, err := f.verify(f.userGame(t), "nelly", "EA_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.stats.Add(verification.Persona{
+		UserId: "1", Id: "2", Name: "kora", Platform: "pc",
+	})
+	err = f.env.Refresh(f.m, *f.game.VerificationHandle, userGame)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userGame, err = f.m.GetUserGameById(userGame.Id)
+	if err != nil {
+		t.Fatal(err)
+	} else if *userGame.Name != "kora" || userGame.Data["eaId"] != "EA_1" {
+		t.Errorf("not refreshed: %+v, %v", userGame, userGame.Data)
+	}
+}
+
+// TestRefreshNoProfile makes sure that pairs without a profile, like ones
+// approved by hand, aren't asked about again and again.
+func TestRefreshNoProfile(t *testing.T) {
+	f := setup(t)
+	userGame := f.userGame(t)
+	err := f.env.Refresh(f.m, *f.game.VerificationHandle, userGame)
+	if err != verification.ErrNoProfile {
+		t.Errorf("got %v, expected ErrNoProfile", err)
+	}
+
+	userGame, err = f.m.GetUserGameById(userGame.Id)
+	if err != nil {
+		t.Fatal(err)
+	} else if userGame.DataUpdatedAt == nil {
+		t.Error("no DataUpdatedAt")
+	}
+
+	if f.stats.Calls() != 0 {
+		t.Errorf("stats asked %d times", f.stats.Calls())
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"app/metrics"
+)
+
+var (
+	rconUp = metrics.Default.Gauge(
+		"worker_rcon_up",
+		"Whether the RCON session is logged in.",
+		"server",
+	)
+	rconEventsTotal = metrics.Default.Counter(
+		"worker_rcon_events_total",
+		"RCON events, by type: the session's own, like connected, or the "+
+			"game's, like player.onJoin.",
+		"server", "event",
+	)
+	rconLastEventSeconds = metrics.Default.Gauge(
+		"worker_rcon_last_event_timestamp_seconds",
+		"When the last RCON event happened, as a Unix timestamp.",
+		"server",
+	)
+	verifyingPlayers = metrics.Default.Gauge(
+		"worker_players",
+		"Players on the server, all of whom are there to verify.",
+		"server",
+	)
+	queuedKicks = metrics.Default.Gauge(
+		"worker_queued_kicks",
+		"Players to be kicked for taking too long.",
+		"server",
+	)
+	updaterBacklog = metrics.Default.Gauge(
+		"worker_updater_backlog",
+		"User-game pairs due for an update, as of the last run of the updater.",
+		"handle",
+	)
+	updaterFailuresTotal = metrics.Default.Counter(
+		"worker_updater_failures_total",
+		"User-game pairs that failed to update.",
+		"handle",
+	)
+)
+
+// collect counts players and kicks, once per scrape.
+func (p *Pool) collect() {
+	for _, e := range p.envs {
+		e.pMutex.Lock()
+		verifyingPlayers.Set(float64(len(e.players)), e.server.Address)
+		e.pMutex.Unlock()
+
+		e.kcmMutex.Lock()
+		queuedKicks.Set(float64(len(e.kickCancelMap)), e.server.Address)
+		e.kcmMutex.Unlock()
+	}
+}
@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"app/api"
+	"app/metrics"
+	"app/models"
+	"app/verification"
+)
+
+// serverCheckDelay has to be well under api.GameServerStaleAfter, or servers
+// will keep going stale between checks.
+const serverCheckDelay = 30 * time.Second
+
+var (
+	errBadPassword = errors.New("wrong RCON password")
+	errBadRead     = errors.New("malformed packet received")
+)
+
+// ServerConfig is a game server to verify players on.
+type ServerConfig struct {
+	Address  string
+	Password string
+}
+
+// ParseServers reads a space-separated list of servers, as in
+// "password@host:port password2@host2:port2". Passwords can have @ in them,
+// but not spaces.
+func ParseServers(s string) ([]ServerConfig, error) {
+	servers := make([]ServerConfig, 0)
+	for n, x := range strings.Fields(s) {
+		i := strings.LastIndex(x, "@")
+		if i < 1 || i == len(x)-1 {
+			// no printing it, since it has a password in it
+			return nil, fmt.Errorf(
+				"server #%d isn't in the form of password@host:port", n+1,
+			)
+		}
+
+		servers = append(servers, ServerConfig{Address: x[i+1:], Password: x[:i]})
+	}
+
+	return servers, nil
+}
+
+// Pool is every server that players are verified on, each with a session and
+// players of its own, and the updater, which keeps the data of those verified
+// fresh.
+type Pool struct {
+	apiEnv    *api.Env
+	envs      []*Env
+	passwords []string
+	uMutex    sync.Mutex
+	backlog   map[string]int
+	updatedAt *time.Time
+}
+
+// NewPool registers the servers, so that they show up before they connect.
+func NewPool(servers []ServerConfig, apiEnv *api.Env) (*Pool, error) {
+	game, err := apiEnv.M.GetGameByVerificationHandle(
+		verification.Battlefield4Handle,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pool{apiEnv: apiEnv, backlog: make(map[string]int)}
+	for _, config := range servers {
+		gameServer := &models.GameServer{
+			GameServerPublic: models.GameServerPublic{
+				GameId:  game.Id,
+				Address: config.Address,
+			},
+		}
+		err = apiEnv.M.RegisterGameServer(gameServer)
+		if err != nil {
+			return nil, err
+		}
+
+		p.envs = append(p.envs, &Env{apiEnv: apiEnv, server: gameServer})
+		p.passwords = append(p.passwords, config.Password)
+	}
+
+	metrics.Default.Collect(p.collect)
+	return p, nil
+}
+
+// Run verifies players on all of the servers at once, never returning.
+func (p *Pool) Run() {
+	go p.runUpdater()
+	for i, e := range p.envs {
+		go e.run(p.passwords[i])
+	}
+
+	select {}
+}
+
+// setStatus records what's going on with the server, keeping the status as
+// is if it's empty, and err is what went wrong, if anything.
+func (e *Env) setStatus(status string, err error) {
+	e.sMutex.Lock()
+	defer e.sMutex.Unlock()
+	if status != "" {
+		e.server.Status = status
+	}
+
+	if status == models.GameServerConnecting {
+		now := time.Now()
+		e.server.ConnectedAt = &now
+	} else if status == models.GameServerOffline {
+		e.server.Players = 0
+	}
+
+	e.server.Error = nil
+	if err != nil {
+		message := err.Error()
+		e.server.Error = &message
+	}
+
+	up := 0.0
+	if e.server.Status == models.GameServerOnline {
+		up = 1
+	}
+
+	rconUp.Set(up, e.server.Address)
+	e.saveServer()
+}
+
+// check asks the server how full it is, and whatever else is worth showing.
+func (e *Env) check() {
+	info, err := e.srv.ServerInfo()
+	e.sMutex.Lock()
+	defer e.sMutex.Unlock()
+	if err != nil {
+		message := err.Error()
+		e.server.Error = &message
+		e.saveServer()
+		return
+	}
+
+	now := time.Now()
+	e.server.Name = &info.Name
+	e.server.Players = info.Players
+	e.server.MaxPlayers = info.MaxPlayers
+	e.server.CheckedAt = &now
+	e.server.Error = nil
+	e.saveServer()
+}
+
+// keepChecking checks the server every now and then, so that its status
+// doesn't go stale, while it's online.
+func (e *Env) keepChecking() {
+	cc := time.NewTicker(serverCheckDelay).C
+	for {
+		<-cc
+		e.sMutex.Lock()
+		online := e.server.Status == models.GameServerOnline
+		e.sMutex.Unlock()
+		if online {
+			e.check()
+		}
+	}
+}
+
+// saveServer has to be called with sMutex locked.
+func (e *Env) saveServer() {
+	err := e.apiEnv.M.UpdateGameServer(e.server)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"server": e.server.Address,
+			"err":    err,
+		}).Error("failed to save game server status")
+	}
+}
@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/zenazn/goji/web"
+
+	"app/api"
+	"app/frcon"
+	"app/metrics"
+	"app/models"
+)
+
+// eventsKept is how many of the last RCON events each server remembers.
+const eventsKept = 20
+
+// Event is an RCON event, with only the first two words kept, which are the
+// name of the event and, usually, of the player, since chat messages are
+// tokens.
+type Event struct {
+	Type      string    `json:"type"`
+	Words     []string  `json:"words"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ServerStatus lists VerifyingPlayers apart from Players, which only counts
+// everybody on the server.
+type ServerStatus struct {
+	models.GameServer
+	LastEvents       []Event  `json:"lastEvents"`
+	VerifyingPlayers []Player `json:"verifyingPlayers"`
+	QueuedKicks      []string `json:"queuedKicks"`
+}
+
+type Status struct {
+	Servers []ServerStatus `json:"servers"`
+	// Backlog is the number of pairs due for an update, by handle.
+	Backlog map[string]int `json:"backlog"`
+	// UpdatedAt is when the updater last ran.
+	UpdatedAt *time.Time `json:"updatedAt"`
+}
+
+// recordEvent counts x, and remembers it among the last few.
+func (e *Env) recordEvent(x frcon.Event) {
+	name := x.Type.String()
+	if x.Type == frcon.EWords && len(x.Words) > 0 {
+		name = x.Words[0]
+	}
+
+	rconEventsTotal.Inc(e.server.Address, name)
+	rconLastEventSeconds.Set(float64(x.Timestamp.Unix()), e.server.Address)
+
+	words := x.Words
+	if len(words) > 2 {
+		words = words[:2]
+	}
+
+	e.sMutex.Lock()
+	defer e.sMutex.Unlock()
+	e.events = append(e.events, Event{x.Type.String(), words, x.Timestamp})
+	if len(e.events) > eventsKept {
+		e.events = e.events[len(e.events)-eventsKept:]
+	}
+}
+
+func (e *Env) status() ServerStatus {
+	var status ServerStatus
+	e.sMutex.Lock()
+	status.GameServer = *e.server
+	status.LastEvents = append([]Event{}, e.events...)
+	e.sMutex.Unlock()
+
+	players := make([]Player, 0)
+	e.pMutex.Lock()
+	for _, player := range e.players {
+		players = append(players, *player)
+	}
+	e.pMutex.Unlock()
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Name < players[j].Name
+	})
+	status.VerifyingPlayers = players
+
+	status.QueuedKicks = make([]string, 0)
+	e.kcmMutex.Lock()
+	for name := range e.kickCancelMap {
+		status.QueuedKicks = append(status.QueuedKicks, name)
+	}
+	e.kcmMutex.Unlock()
+	sort.Strings(status.QueuedKicks)
+
+	return status
+}
+
+// Status is what every server is up to, and how far behind the updater is.
+func (p *Pool) Status() *Status {
+	status := &Status{Servers: make([]ServerStatus, 0, len(p.envs))}
+	for _, e := range p.envs {
+		status.Servers = append(status.Servers, e.status())
+	}
+
+	p.uMutex.Lock()
+	defer p.uMutex.Unlock()
+	status.Backlog = make(map[string]int)
+	for handle, n := range p.backlog {
+		status.Backlog[handle] = n
+	}
+
+	status.UpdatedAt = p.updatedAt
+	return status
+}
+
+func (p *Pool) GetStatus(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *api.Error {
+	_, apierr := p.apiEnv.Admin(c)
+	if apierr != nil {
+		return apierr
+	}
+
+	return api.OK(p.Status(), c, w)
+}
+
+// GetMetrics is for Prometheus, which has to scrape with an admin's API
+// token, granted admin-read.
+func (p *Pool) GetMetrics(
+	c web.C, w http.ResponseWriter, r *http.Request,
+) *api.Error {
+	_, apierr := p.apiEnv.Admin(c)
+	if apierr != nil {
+		return apierr
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	err := metrics.Default.Write(w)
+	if err != nil {
+		return &api.Error{E: err}
+	}
+
+	return nil
+}
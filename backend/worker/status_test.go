@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestStatusJSON makes sure that /status speaks camelCase like the rest of
+// the API, and that the players on a server don't clash with their count.
+func TestStatusJSON(t *testing.T) {
+	var server ServerStatus
+	server.Players = 3
+	server.VerifyingPlayers = []Player{{Name: "nelly", EAID: "EA_1"}}
+	data, err := json.Marshal(Status{Servers: []ServerStatus{server}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var status struct {
+		Servers []map[string]interface{} `json:"servers"`
+	}
+	err = json.Unmarshal(data, &status)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(status.Servers) != 1 {
+		t.Fatalf("got %s", data)
+	}
+
+	got := status.Servers[0]
+	if got["players"] != 3.0 {
+		t.Errorf("players: got %v, expected 3", got["players"])
+	}
+
+	players, ok := got["verifyingPlayers"].([]interface{})
+	if !ok || len(players) != 1 {
+		t.Fatalf("verifyingPlayers: got %v", got["verifyingPlayers"])
+	}
+
+	player, _ := players[0].(map[string]interface{})
+	if player["name"] != "nelly" || player["eaId"] != "EA_1" {
+		t.Errorf("got player %v", player)
+	}
+
+	for _, key := range []string{"Players", "LastEvents", "QueuedKicks"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("%s isn't camelCase", key)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+import callAPI from './api';
+
+// the ones with free slots, emptiest first
+export function loadFreeGameServers(gameId) {
+  return callAPI({
+    url: `/game_servers?free=true&filter[game_id]=${gameId}`,
+    type: 'GAME_SERVERS__LOAD',
+    storage: 'gameServers',
+  });
+}
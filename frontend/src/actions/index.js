@@ -7,6 +7,7 @@ export * from './bracket_rounds';
 export * from './brackets';
 export * from './comments';
 export * from './game_maps';
+export * from './game_servers';
 export * from './games';
 export * from './match_maps';
 export * from './match_penalties';
@@ -42,6 +42,20 @@ const myOwnershipSelector = createSelector(
   ),
 );
 
+// the worker keeps these up to date, and the API sorts them emptiest first,
+// but the cache doesn't remember the order
+const freeGameServerSelector = createSelector(
+  selectors.gameServers,
+  gameIdSelector,
+  (x, gameId) => x.toList()
+    .filter(y =>
+      y.get('gameId') === gameId &&
+      y.get('status') === 'online' &&
+      y.get('freeSlots') > 0,
+    )
+    .maxBy(y => y.get('freeSlots')),
+);
+
 @connect(createStructuredSelector({
   isLoading: selectors.isLoading,
   myId: selectors.myId,
@@ -52,6 +66,7 @@ const myOwnershipSelector = createSelector(
   game: gameSelector,
   gameTournaments: gameTournamentsSelector,
   myOwnership: myOwnershipSelector,
+  freeGameServer: freeGameServerSelector,
 }), actions)
 export default class Game extends Component {
   static propTypes = {
@@ -64,12 +79,14 @@ export default class Game extends Component {
     game: ImmutablePropTypes.map,
     gameTournaments: ImmutablePropTypes.list,
     myOwnership: ImmutablePropTypes.map,
+    freeGameServer: ImmutablePropTypes.map,
 
     loadGames: PropTypes.func.isRequired,
     loadTournaments: PropTypes.func.isRequired,
     createUserGame: PropTypes.func.isRequired,
     loadUserGame: PropTypes.func.isRequired,
     loadUserGames: PropTypes.func.isRequired,
+    loadFreeGameServers: PropTypes.func.isRequired,
     patchUserGame: PropTypes.func.isRequired,
   };
 
@@ -95,6 +112,7 @@ export default class Game extends Component {
     this.props.loadTournaments({ gameId: game.get('id') });
     if (!myId) return;
     this.props.loadUserGames({ userId: myId, gameId: game.get('id') });
+    this.props.loadFreeGameServers(game.get('id'));
   }
 
   handleAddClick = () => {
@@ -104,6 +122,7 @@ export default class Game extends Component {
   handleStatusClick = () => {
     if (!this.props.myOwnership) return;
     this.props.loadUserGame(this.props.myOwnership.get('id'));
+    this.props.loadFreeGameServers(this.props.game.get('id'));
   };
   handleNullifyClick = () => {
     if (!this.props.myOwnership) return;
@@ -114,6 +133,7 @@ export default class Game extends Component {
   render() {
     const {
       isLoading, myId, amAdmin, game: _game, gameTournaments, myOwnership,
+      freeGameServer,
     } = this.props;
 
     if (!_game) {
@@ -204,6 +224,12 @@ export default class Game extends Component {
                     >
                       verification game server
                     </a>
+                    {freeGameServer && <span>
+                      (<code className={this.cn({ d: 'token' })}>
+                        {freeGameServer.get('address')}
+                      </code>
+                      has room right now)
+                    </span>}
                     and write
                     <code className={this.cn({ d: 'token' })}>
                       {myOwnership.get('token')}
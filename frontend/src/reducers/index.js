@@ -65,6 +65,7 @@ const cacheInitial = fromJS({
   bracketStandings: {},
   comments: {},
   gameMaps: {},
+  gameServers: {},
   games: {},
   matches: {},
   matchLeaderships: {},
@@ -114,6 +115,7 @@ function cache(state = cacheInitial, action) {
     case 'GAME_MAP__LOAD__SUCCESS':
     case 'GAME_MAP__UPDATE__SUCCESS':
     case 'GAME_MAPS__LOAD__SUCCESS':
+    case 'GAME_SERVERS__LOAD__SUCCESS':
     case 'GAMES__LOAD__SUCCESS':
     case 'MATCH__LOAD__SUCCESS':
     case 'MATCH__UPDATE__SUCCESS':
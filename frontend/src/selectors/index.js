@@ -19,6 +19,7 @@ export const brackets = cache('brackets');
 export const bracketStandings = cache('bracketStandings');
 export const comments = cache('comments');
 export const gameMaps = cache('gameMaps');
+export const gameServers = cache('gameServers');
 export const games = cache('games');
 export const matches = cache('matches');
 export const matchLeaderships = cache('matchLeaderships');
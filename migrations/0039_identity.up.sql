@@ -0,0 +1,39 @@
+CREATE SEQUENCE identity_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+CREATE TABLE public.identity (
+  id int4 NOT NULL DEFAULT nextval('identity_id_seq'::regclass),
+  user_id int4 NOT NULL,
+  provider text NOT NULL,
+  subject text NOT NULL,
+  email text NOT NULL DEFAULT '',
+  name text NOT NULL DEFAULT '',
+  link text NOT NULL DEFAULT '',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  last_used_at timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT identity_pkey PRIMARY KEY (id),
+  CONSTRAINT identity_user_id_fkey FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE
+)
+WITH (
+  OIDS=FALSE
+);
+
+CREATE UNIQUE INDEX identity_provider_subject_idx ON public.identity (provider, subject);
+CREATE UNIQUE INDEX identity_user_id_provider_idx ON public.identity (user_id, provider);
+
+CREATE TABLE public.oauth_state (
+  token bytea NOT NULL,
+  provider text NOT NULL,
+  verifier text NOT NULL,
+  user_id int4 NULL,
+  remember boolean NOT NULL DEFAULT FALSE,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT oauth_state_pkey PRIMARY KEY (token),
+  CONSTRAINT oauth_state_user_id_fkey FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE
+);
+
+COMMENT ON TABLE oauth_state IS 'Pending logins through external identity providers.';
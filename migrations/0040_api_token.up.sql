@@ -0,0 +1,27 @@
+CREATE SEQUENCE api_token_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+CREATE TABLE public.api_token (
+  id int4 NOT NULL DEFAULT nextval('api_token_id_seq'::regclass),
+  token bytea NOT NULL,
+  user_id int4 NOT NULL,
+  name text NOT NULL,
+  scopes text[] NOT NULL DEFAULT '{}',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  last_used_at timestamptz NULL,
+  last_used_ip text NULL,
+  CONSTRAINT api_token_pkey PRIMARY KEY (id),
+  CONSTRAINT api_token_user_id_fkey FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE
+)
+WITH (
+  OIDS=FALSE
+);
+
+CREATE UNIQUE INDEX api_token_token_idx ON public.api_token (token);
+
+COMMENT ON TABLE api_token IS 'Long-lived personal access tokens, for bots and overlays.';
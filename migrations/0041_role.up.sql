@@ -0,0 +1,37 @@
+CREATE SEQUENCE role_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+CREATE TABLE public.role (
+  id int4 NOT NULL DEFAULT nextval('role_id_seq'::regclass),
+  user_id int4 NOT NULL,
+  name text NOT NULL,
+  tournament_id int4 NULL,
+  season_id int4 NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  created_by int4 NULL,
+  CONSTRAINT role_pkey PRIMARY KEY (id),
+  CONSTRAINT role_user_id_fkey FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE,
+  CONSTRAINT role_tournament_id_fkey FOREIGN KEY (tournament_id) REFERENCES public.tournament(id) ON UPDATE CASCADE,
+  CONSTRAINT role_season_id_fkey FOREIGN KEY (season_id) REFERENCES public.season(id) ON UPDATE CASCADE,
+  CONSTRAINT role_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(id) ON UPDATE CASCADE,
+  CONSTRAINT role_season_id_check CHECK (season_id IS NULL OR tournament_id IS NOT NULL),
+  CONSTRAINT role_admin_check CHECK (name <> 'admin' OR tournament_id IS NULL)
+)
+WITH (
+  OIDS=FALSE
+);
+
+CREATE UNIQUE INDEX role_user_id_name_scope_idx ON public.role (
+  user_id, name, COALESCE(tournament_id, 0), COALESCE(season_id, 0)
+);
+
+COMMENT ON TABLE role IS 'What users are allowed to do, globally, within a tournament, or within a season.';
+
+INSERT INTO role (user_id, name) SELECT id, 'admin' FROM "user" WHERE is_admin;
+
+DROP TRIGGER user_audit_is_admin ON "user";
+ALTER TABLE "user" DROP COLUMN is_admin;
@@ -0,0 +1,10 @@
+ALTER TABLE otp
+  ADD COLUMN purpose text NOT NULL DEFAULT 'login',
+  ADD COLUMN email text NULL,
+  ADD CONSTRAINT otp_email_check
+    CHECK ((purpose = 'email') = (email IS NOT NULL));
+
+CREATE INDEX otp_user_id_purpose_idx ON public.otp (user_id, purpose);
+
+COMMENT ON TABLE otp IS
+  'Used for email verification, email changing and password resetting.';
@@ -0,0 +1,4 @@
+ALTER TABLE "user" ADD COLUMN deleted_at timestamptz NULL;
+
+COMMENT ON COLUMN "user".deleted_at IS
+  'Deleted users are anonymised in place, so that match history stays intact.';
@@ -0,0 +1,41 @@
+ALTER TABLE match_report ADD COLUMN updated_at timestamptz;
+ALTER TABLE match_report ADD COLUMN updated_by int4;
+ALTER TABLE match_report ADD CONSTRAINT match_report_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES "user"(id) ON UPDATE CASCADE;
+
+CREATE TRIGGER match_report_updated_at
+  BEFORE UPDATE
+  ON match_report
+  FOR EACH ROW
+  EXECUTE PROCEDURE updated_at();
+
+CREATE TRIGGER match_report_audit_1
+  BEFORE UPDATE
+  ON match_report
+  FOR EACH ROW
+  EXECUTE PROCEDURE audit_1();
+
+CREATE TRIGGER match_report_audit_2
+  BEFORE UPDATE OF updated_by
+  ON match_report
+  FOR EACH ROW
+  EXECUTE PROCEDURE audit_2();
+
+CREATE TRIGGER match_report_audit_3
+  BEFORE UPDATE
+  ON match_report
+  FOR EACH ROW
+  EXECUTE PROCEDURE audit_3();
+
+CREATE TRIGGER match_report_audit_agreed_upon_at
+  AFTER UPDATE OF agreed_upon_at
+  ON match_report
+  FOR EACH ROW
+  WHEN (new.agreed_upon_at IS DISTINCT FROM old.agreed_upon_at)
+  EXECUTE PROCEDURE audit('agreed_upon_at');
+
+CREATE TRIGGER match_report_audit_agreed_upon_by
+  AFTER UPDATE OF agreed_upon_by
+  ON match_report
+  FOR EACH ROW
+  WHEN (new.agreed_upon_by IS DISTINCT FROM old.agreed_upon_by)
+  EXECUTE PROCEDURE audit('agreed_upon_by');
@@ -0,0 +1,62 @@
+CREATE TRIGGER match_audit_team_x
+  AFTER UPDATE OF team_x
+  ON match
+  FOR EACH ROW
+  WHEN (new.team_x IS DISTINCT FROM old.team_x)
+  EXECUTE PROCEDURE audit('team_x');
+
+CREATE TRIGGER match_audit_team_y
+  AFTER UPDATE OF team_y
+  ON match
+  FOR EACH ROW
+  WHEN (new.team_y IS DISTINCT FROM old.team_y)
+  EXECUTE PROCEDURE audit('team_y');
+
+CREATE TRIGGER match_audit_match_report_id
+  AFTER UPDATE OF match_report_id
+  ON match
+  FOR EACH ROW
+  WHEN (new.match_report_id IS DISTINCT FROM old.match_report_id)
+  EXECUTE PROCEDURE audit('match_report_id');
+
+CREATE TRIGGER match_audit_score_x
+  AFTER UPDATE OF score_x
+  ON match
+  FOR EACH ROW
+  WHEN (new.score_x IS DISTINCT FROM old.score_x)
+  EXECUTE PROCEDURE audit('score_x');
+
+CREATE TRIGGER match_audit_score_y
+  AFTER UPDATE OF score_y
+  ON match
+  FOR EACH ROW
+  WHEN (new.score_y IS DISTINCT FROM old.score_y)
+  EXECUTE PROCEDURE audit('score_y');
+
+CREATE TRIGGER match_audit_raw_score_x
+  AFTER UPDATE OF raw_score_x
+  ON match
+  FOR EACH ROW
+  WHEN (new.raw_score_x IS DISTINCT FROM old.raw_score_x)
+  EXECUTE PROCEDURE audit('raw_score_x');
+
+CREATE TRIGGER match_audit_raw_score_y
+  AFTER UPDATE OF raw_score_y
+  ON match
+  FOR EACH ROW
+  WHEN (new.raw_score_y IS DISTINCT FROM old.raw_score_y)
+  EXECUTE PROCEDURE audit('raw_score_y');
+
+CREATE TRIGGER match_audit_is_overridden
+  AFTER UPDATE OF is_overridden
+  ON match
+  FOR EACH ROW
+  WHEN (new.is_overridden IS DISTINCT FROM old.is_overridden)
+  EXECUTE PROCEDURE audit('is_overridden');
+
+CREATE TRIGGER match_audit_is_penalized
+  AFTER UPDATE OF is_penalized
+  ON match
+  FOR EACH ROW
+  WHEN (new.is_penalized IS DISTINCT FROM old.is_penalized)
+  EXECUTE PROCEDURE audit('is_penalized');
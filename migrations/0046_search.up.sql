@@ -0,0 +1,236 @@
+CREATE TABLE search
+(
+  target text NOT NULL,
+  target_id integer NOT NULL,
+  title text NOT NULL,
+  document tsvector NOT NULL,
+  visible_from timestamp with time zone,
+  CONSTRAINT search_pkey PRIMARY KEY (target, target_id)
+)
+WITH (
+  OIDS=FALSE
+);
+ALTER TABLE search OWNER TO postgres;
+
+COMMENT ON TABLE search IS
+  'Public text only, kept up to date by triggers. Hidden until visible_from.';
+
+CREATE INDEX search_document_idx
+  ON search
+  USING gin
+  (document);
+
+CREATE FUNCTION search_upsert(
+  _target text, _target_id integer, _title text, _document tsvector,
+  _visible_from timestamp with time zone
+)
+  RETURNS void AS
+$BODY$
+BEGIN
+  INSERT INTO search (target, target_id, title, document, visible_from)
+  VALUES (_target, _target_id, _title, _document, _visible_from)
+  ON CONFLICT (target, target_id) DO UPDATE
+  SET title = EXCLUDED.title,
+      document = EXCLUDED.document,
+      visible_from = EXCLUDED.visible_from;
+END;
+$BODY$
+  LANGUAGE plpgsql VOLATILE SECURITY DEFINER
+  COST 100;
+ALTER FUNCTION search_upsert(
+  text, integer, text, tsvector, timestamp with time zone
+) OWNER TO postgres;
+
+CREATE FUNCTION search_delete()
+  RETURNS trigger AS
+$BODY$
+BEGIN
+  DELETE FROM search WHERE target = TG_ARGV[0] AND target_id = OLD.id;
+  RETURN OLD;
+END;
+$BODY$
+  LANGUAGE plpgsql VOLATILE SECURITY DEFINER
+  COST 100;
+ALTER FUNCTION search_delete() OWNER TO postgres;
+
+-- Only the nickname, never the email or the full name.
+CREATE FUNCTION search_user()
+  RETURNS trigger AS
+$BODY$
+BEGIN
+  PERFORM search_upsert(
+    'user', NEW.id, NEW.nickname,
+    setweight(to_tsvector('simple', NEW.nickname), 'A'),
+    CASE WHEN NEW.deleted_at IS NULL THEN NEW.created_at END
+  );
+  RETURN NEW;
+END;
+$BODY$
+  LANGUAGE plpgsql VOLATILE SECURITY DEFINER
+  COST 100;
+ALTER FUNCTION search_user() OWNER TO postgres;
+
+CREATE TRIGGER search_user
+  AFTER INSERT OR UPDATE OF nickname, deleted_at
+  ON "user"
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_user();
+
+CREATE TRIGGER search_user_delete
+  AFTER DELETE
+  ON "user"
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_delete('user');
+
+CREATE FUNCTION search_team()
+  RETURNS trigger AS
+$BODY$
+BEGIN
+  PERFORM search_upsert(
+    'team', NEW.id, NEW.name,
+    setweight(to_tsvector('simple', NEW.name), 'A') ||
+      setweight(to_tsvector('simple', NEW.abbr), 'A'),
+    NEW.created_at
+  );
+  RETURN NEW;
+END;
+$BODY$
+  LANGUAGE plpgsql VOLATILE SECURITY DEFINER
+  COST 100;
+ALTER FUNCTION search_team() OWNER TO postgres;
+
+CREATE TRIGGER search_team
+  AFTER INSERT OR UPDATE OF name, abbr
+  ON team
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_team();
+
+CREATE TRIGGER search_team_delete
+  AFTER DELETE
+  ON team
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_delete('team');
+
+CREATE FUNCTION search_tournament()
+  RETURNS trigger AS
+$BODY$
+BEGIN
+  PERFORM search_upsert(
+    'tournament', NEW.id, NEW.name,
+    setweight(to_tsvector('simple', NEW.name), 'A') ||
+      setweight(to_tsvector('simple', NEW.abbr), 'A'),
+    '-infinity'
+  );
+  RETURN NEW;
+END;
+$BODY$
+  LANGUAGE plpgsql VOLATILE SECURITY DEFINER
+  COST 100;
+ALTER FUNCTION search_tournament() OWNER TO postgres;
+
+CREATE TRIGGER search_tournament
+  AFTER INSERT OR UPDATE OF name, abbr
+  ON tournament
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_tournament();
+
+CREATE TRIGGER search_tournament_delete
+  AFTER DELETE
+  ON tournament
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_delete('tournament');
+
+CREATE FUNCTION search_season()
+  RETURNS trigger AS
+$BODY$
+BEGIN
+  PERFORM search_upsert(
+    'season', NEW.id, NEW.name,
+    setweight(to_tsvector('simple', NEW.name), 'A') ||
+      setweight(to_tsvector('simple', NEW.abbr), 'A'),
+    NEW.published_at
+  );
+  RETURN NEW;
+END;
+$BODY$
+  LANGUAGE plpgsql VOLATILE SECURITY DEFINER
+  COST 100;
+ALTER FUNCTION search_season() OWNER TO postgres;
+
+CREATE TRIGGER search_season
+  AFTER INSERT OR UPDATE OF name, abbr, published_at
+  ON season
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_season();
+
+CREATE TRIGGER search_season_delete
+  AFTER DELETE
+  ON season
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_delete('season');
+
+CREATE FUNCTION search_news_item()
+  RETURNS trigger AS
+$BODY$
+BEGIN
+  PERFORM search_upsert(
+    'news_item', NEW.id, NEW.title,
+    setweight(to_tsvector('simple', NEW.title), 'A') ||
+      setweight(to_tsvector('simple', NEW.preview), 'B') ||
+      setweight(to_tsvector('simple', NEW.body), 'C'),
+    CASE WHEN NOT NEW.is_deleted THEN NEW.published_at END
+  );
+  RETURN NEW;
+END;
+$BODY$
+  LANGUAGE plpgsql VOLATILE SECURITY DEFINER
+  COST 100;
+ALTER FUNCTION search_news_item() OWNER TO postgres;
+
+CREATE TRIGGER search_news_item
+  AFTER INSERT OR UPDATE OF title, preview, body, published_at, is_deleted
+  ON news_item
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_news_item();
+
+CREATE TRIGGER search_news_item_delete
+  AFTER DELETE
+  ON news_item
+  FOR EACH ROW
+  EXECUTE PROCEDURE search_delete('news_item');
+
+-- Same as the triggers, for everything that's already there.
+INSERT INTO search (target, target_id, title, document, visible_from)
+SELECT 'user', id, nickname,
+  setweight(to_tsvector('simple', nickname), 'A'),
+  CASE WHEN deleted_at IS NULL THEN created_at END
+FROM "user";
+
+INSERT INTO search (target, target_id, title, document, visible_from)
+SELECT 'team', id, name,
+  setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', abbr), 'A'),
+  created_at
+FROM team;
+
+INSERT INTO search (target, target_id, title, document, visible_from)
+SELECT 'tournament', id, name,
+  setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', abbr), 'A'),
+  '-infinity'
+FROM tournament;
+
+INSERT INTO search (target, target_id, title, document, visible_from)
+SELECT 'season', id, name,
+  setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', abbr), 'A'),
+  published_at
+FROM season;
+
+INSERT INTO search (target, target_id, title, document, visible_from)
+SELECT 'news_item', id, title,
+  setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', preview), 'B') ||
+    setweight(to_tsvector('simple', body), 'C'),
+  CASE WHEN NOT is_deleted THEN published_at END
+FROM news_item;
@@ -0,0 +1,16 @@
+CREATE TABLE public.idempotency_key (
+  user_id int4 NOT NULL,
+  key text NOT NULL,
+  method text NOT NULL,
+  path text NOT NULL,
+  status int4 NULL,
+  body bytea NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  CONSTRAINT idempotency_key_pkey PRIMARY KEY (user_id, key),
+  CONSTRAINT idempotency_key_user_id_fkey FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE
+)
+WITH (
+  OIDS=FALSE
+);
+
+COMMENT ON TABLE idempotency_key IS 'Responses to POSTs, replayed when clients retry them. No status means still in progress.';
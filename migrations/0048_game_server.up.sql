@@ -0,0 +1,32 @@
+CREATE SEQUENCE game_server_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+CREATE TABLE public.game_server (
+  id int4 NOT NULL DEFAULT nextval('game_server_id_seq'::regclass),
+  game_id int4 NOT NULL,
+  address text NOT NULL,
+  name text NULL,
+  status text NOT NULL DEFAULT 'offline',
+  players int4 NOT NULL DEFAULT 0,
+  max_players int4 NOT NULL DEFAULT 0,
+  free_slots int4 NOT NULL DEFAULT 0,
+  error text NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  connected_at timestamptz NULL,
+  checked_at timestamptz NULL,
+  CONSTRAINT game_server_pkey PRIMARY KEY (id),
+  CONSTRAINT game_server_game_id_fkey FOREIGN KEY (game_id) REFERENCES public.game(id),
+  CONSTRAINT game_server_status_check CHECK (status IN ('offline', 'connecting', 'online'))
+)
+WITH (
+  OIDS=FALSE
+);
+
+CREATE UNIQUE INDEX game_server_address_idx ON public.game_server (address);
+CREATE INDEX game_server_game_id_idx ON public.game_server (game_id);
+
+COMMENT ON TABLE game_server IS 'Verification servers, as last seen by the worker. checked_at going stale means the worker is gone.';
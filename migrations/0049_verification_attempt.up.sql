@@ -0,0 +1,32 @@
+CREATE SEQUENCE verification_attempt_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+CREATE TABLE public.verification_attempt (
+  id int4 NOT NULL DEFAULT nextval('verification_attempt_id_seq'::regclass),
+  game_id int4 NOT NULL,
+  user_game_id int4 NULL,
+  name text NULL,
+  token text NULL,
+  data jsonb NULL,
+  outcome text NOT NULL,
+  reason text NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  created_by int4 NULL,
+  CONSTRAINT verification_attempt_pkey PRIMARY KEY (id),
+  CONSTRAINT verification_attempt_game_id_fkey FOREIGN KEY (game_id) REFERENCES public.game(id),
+  CONSTRAINT verification_attempt_user_game_id_fkey FOREIGN KEY (user_game_id) REFERENCES public.user_game(id),
+  CONSTRAINT verification_attempt_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(id) ON UPDATE CASCADE,
+  CONSTRAINT verification_attempt_outcome_check CHECK (outcome IN ('verified', 'unknown_token', 'no_profile', 'failed', 'approved', 'rejected'))
+)
+WITH (
+  OIDS=FALSE
+);
+
+CREATE INDEX verification_attempt_game_id_idx ON public.verification_attempt (game_id);
+CREATE INDEX verification_attempt_user_game_id_idx ON public.verification_attempt (user_game_id);
+
+COMMENT ON TABLE verification_attempt IS 'Every time somebody tried to verify a game, made by the worker, or by an admin deciding by hand, which is when created_by is set.';
@@ -0,0 +1,85 @@
+CREATE TABLE public.user_game_identifier (
+  game_id int4 NOT NULL,
+  key text NOT NULL,
+  value text NOT NULL,
+  user_game_id int4 NOT NULL,
+  CONSTRAINT user_game_identifier_pkey PRIMARY KEY (game_id, key, value),
+  CONSTRAINT user_game_identifier_game_id_fkey FOREIGN KEY (game_id) REFERENCES public.game(id),
+  CONSTRAINT user_game_identifier_user_game_id_fkey FOREIGN KEY (user_game_id) REFERENCES public.user_game(id) ON DELETE CASCADE
+)
+WITH (
+  OIDS=FALSE
+);
+
+CREATE INDEX user_game_identifier_user_game_id_idx ON public.user_game_identifier (user_game_id);
+
+COMMENT ON TABLE user_game_identifier IS 'Identifiers of valid verified user-game pairs, which no two pairs of the same game can share. Released once a pair is nullified.';
+
+-- the newest pair wins, like it used to when the worker nullified the rest
+INSERT INTO user_game_identifier (game_id, key, value, user_game_id)
+SELECT user_game.game_id, kv.key, kv.value, user_game.id
+FROM user_game
+CROSS JOIN LATERAL jsonb_each_text(user_game.data) kv
+JOIN game ON game.id=user_game.game_id
+WHERE
+  game.verification_handle='battlefield-4' AND
+  kv.key IN ('eaId', 'pbId', 'blUserId', 'blPersonaId') AND
+  kv.value<>'' AND
+  user_game.verified_at IS NOT NULL AND
+  user_game.nullified_at IS NULL
+ORDER BY user_game.verified_at DESC
+ON CONFLICT DO NOTHING;
+
+CREATE FUNCTION user_game_identifier_release()
+  RETURNS trigger AS
+$BODY$
+BEGIN
+  DELETE FROM user_game_identifier WHERE user_game_id=NEW.id;
+  RETURN NULL;
+END;
+$BODY$
+  LANGUAGE plpgsql VOLATILE;
+
+CREATE TRIGGER user_game_identifier_release
+  AFTER UPDATE OF nullified_at
+  ON user_game
+  FOR EACH ROW
+  WHEN (new.nullified_at IS NOT NULL)
+  EXECUTE PROCEDURE user_game_identifier_release();
+
+CREATE SEQUENCE account_alert_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+CREATE TABLE public.account_alert (
+  id int4 NOT NULL DEFAULT nextval('account_alert_id_seq'::regclass),
+  kind text NOT NULL,
+  game_id int4 NOT NULL,
+  user_id int4 NOT NULL,
+  related_user_id int4 NOT NULL,
+  key text NOT NULL,
+  value text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  resolved_at timestamptz NULL,
+  resolved_by int4 NULL,
+  CONSTRAINT account_alert_pkey PRIMARY KEY (id),
+  CONSTRAINT account_alert_game_id_fkey FOREIGN KEY (game_id) REFERENCES public.game(id),
+  CONSTRAINT account_alert_user_id_fkey FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE,
+  CONSTRAINT account_alert_related_user_id_fkey FOREIGN KEY (related_user_id) REFERENCES public."user"(id) ON UPDATE CASCADE,
+  CONSTRAINT account_alert_resolved_by_fkey FOREIGN KEY (resolved_by) REFERENCES public."user"(id) ON UPDATE CASCADE,
+  CONSTRAINT account_alert_kind_check CHECK (kind IN ('duplicate', 'shared'))
+)
+WITH (
+  OIDS=FALSE
+);
+
+CREATE UNIQUE INDEX account_alert_unresolved_idx ON public.account_alert (kind, game_id, user_id, related_user_id, key, value) WHERE resolved_at IS NULL;
+CREATE INDEX account_alert_user_id_idx ON public.account_alert (user_id);
+
+COMMENT ON TABLE account_alert IS 'Users who look like the same person. Duplicates tried to verify a game with identifiers that another user has, shared ones just have something like an IP in common.';
+
+ALTER TABLE verification_attempt DROP CONSTRAINT verification_attempt_outcome_check;
+ALTER TABLE verification_attempt ADD CONSTRAINT verification_attempt_outcome_check CHECK (outcome IN ('verified', 'unknown_token', 'no_profile', 'duplicate', 'failed', 'approved', 'rejected'));
@@ -0,0 +1,34 @@
+-- pairs that lost an identifier to a newer one in 0050's backfill would
+-- otherwise stay verified with nothing claimed, so they get the alert the
+-- worker now raises for duplicates, and are nullified like it used to do
+INSERT INTO account_alert (kind, game_id, user_id, related_user_id, key, value)
+SELECT DISTINCT
+  'duplicate', loser.game_id, loser.user_id, winner.user_id, kv.key, kv.value
+FROM user_game loser
+CROSS JOIN LATERAL jsonb_each_text(loser.data) kv
+JOIN user_game_identifier identifier ON
+  identifier.game_id=loser.game_id AND
+  identifier.key=kv.key AND
+  identifier.value=kv.value AND
+  identifier.user_game_id<>loser.id
+JOIN user_game winner ON winner.id=identifier.user_game_id
+WHERE
+  loser.verified_at IS NOT NULL AND
+  loser.nullified_at IS NULL AND
+  winner.user_id<>loser.user_id
+ON CONFLICT DO NOTHING;
+
+UPDATE user_game loser
+SET nullified_at=now()
+WHERE
+  loser.verified_at IS NOT NULL AND
+  loser.nullified_at IS NULL AND
+  EXISTS (
+    SELECT 1
+    FROM jsonb_each_text(loser.data) kv
+    JOIN user_game_identifier identifier ON
+      identifier.game_id=loser.game_id AND
+      identifier.key=kv.key AND
+      identifier.value=kv.value AND
+      identifier.user_game_id<>loser.id
+  );
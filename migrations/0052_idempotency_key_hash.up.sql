@@ -0,0 +1,7 @@
+-- keys from before can't be told apart from reuses with other bodies, and are
+-- a day old at most anyway
+DELETE FROM idempotency_key;
+
+ALTER TABLE idempotency_key ADD COLUMN request_hash bytea NOT NULL;
+
+COMMENT ON COLUMN idempotency_key.request_hash IS 'Blake2b of the request body, which a retry has to repeat.';